@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultRequestIDHeader is the header --request-id uses when given
+// without a name.
+const defaultRequestIDHeader = "X-Request-Id"
+
+var (
+	accessLogPath   string // Write a line per request to this file, "-" for stderr
+	requestIDHeader string // Tag requests with an ID in this header
+
+	// accessLogFile is the open --access-log file, or nil
+	accessLogFile *os.File
+)
+
+// addAccessLogFlags registers the access log flags on cmd.
+func addAccessLogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&accessLogPath, "access-log", "", "Append a line per request to this file (- for stderr)")
+	cmd.Flags().StringVar(&requestIDHeader, "request-id", "", "Tag each request with an ID, kept from the client or generated, in this header and the access log (default "+defaultRequestIDHeader+"; requires --access-log)")
+	cmd.Flags().Lookup("request-id").NoOptDefVal = defaultRequestIDHeader
+}
+
+// validateAccessLogFlags checks the access log flags before anything
+// starts.
+func validateAccessLogFlags() error {
+	if requestIDHeader != "" && accessLogPath == "" {
+		return fmt.Errorf("--request-id requires --access-log")
+	}
+	return nil
+}
+
+// openAccessLog opens the --access-log, if any, for the server to write
+// to.
+func openAccessLog() (io.Writer, error) {
+	switch accessLogPath {
+	case "":
+		return nil, nil
+	case "-":
+		return os.Stderr, nil
+	}
+	f, err := os.OpenFile(accessLogPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open access log: %w", err)
+	}
+	accessLogFile = f
+	return f, nil
+}
+
+// closeAccessLog closes the --access-log file, if open.
+func closeAccessLog() {
+	if accessLogFile == nil {
+		return
+	}
+	accessLogFile.Close()
+	accessLogFile = nil
+}
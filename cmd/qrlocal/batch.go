@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/hash/qrlocal/pkg/network"
+	"github.com/hash/qrlocal/pkg/qr"
+)
+
+// maxBatchPorts caps how many ports a --range may span.
+const maxBatchPorts = 64
+
+var (
+	batchRange   string // Range of ports to show QR codes for
+	sheetPath    string // Contact sheet PNG of the batch's QR codes
+	sheetColumns int    // QR codes per row on the contact sheet
+)
+
+// batchConflicts are the flags that act on a single URL, so they can't
+// be combined with --range.
+var batchConflicts = []string{
+	"public", "tcp", "target", "password", "short", "wait-for-port",
+	"copy", "open", "png", "svg", "data-uri", "png-fd", "qr-text-fd",
+	"show-image", "copy-qr-ascii",
+}
+
+// runBatch shows a labeled QR code with the local URL of each port in
+// --range that has a listener, skipping the others.
+func runBatch(cmd *cobra.Command) error {
+	ports, err := parsePortRange(batchRange)
+	if err != nil {
+		return err
+	}
+	if count := ports[1] - ports[0] + 1; count > maxBatchPorts {
+		return fmt.Errorf("--range spans %d ports; the limit is %d", count, maxBatchPorts)
+	}
+	for _, name := range batchConflicts {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--range can't be combined with --%s", name)
+		}
+	}
+	if sheetColumns < 1 {
+		return fmt.Errorf("--sheet-columns must be at least 1")
+	}
+	if err := validateExportFlags(); err != nil {
+		return err
+	}
+
+	applyConfigDefaults(cmd)
+	renderer := newRenderer()
+
+	var sheet []qr.SheetItem
+	for port := ports[0]; port <= ports[1]; port++ {
+		host, ok := network.LocalListener(port)
+		if !ok {
+			renderer.PrintDebug(fmt.Sprintf("Skipping port %d: nothing is listening", port))
+			continue
+		}
+
+		serviceIPv6Only = strings.Contains(host, ":")
+		url, err := localURL(localScheme(cmd, host, port, renderer), port)
+		if err != nil {
+			renderer.PrintError("Failed to generate local URL: " + err.Error())
+			return err
+		}
+
+		prepareQR(url, renderer)
+		renderer.SetTitle(batchTitle(port))
+		if renderTerminalQR() {
+			if err := renderer.RenderOutput(url, false); err != nil {
+				renderer.PrintError("Failed to generate QR code")
+				return err
+			}
+		}
+		if onelineOutput {
+			printStatusLine(url)
+		}
+		if err := exportQR(url, renderer); err != nil {
+			return err
+		}
+		sheet = append(sheet, qr.SheetItem{Content: url, Label: batchTitle(port)})
+	}
+
+	shown := len(sheet)
+	if shown == 0 {
+		renderer.PrintError(fmt.Sprintf("No service is listening on ports %d-%d", ports[0], ports[1]))
+		return fmt.Errorf("no active ports in %s", batchRange)
+	}
+	renderer.PrintSuccess(fmt.Sprintf("%d of %d ports in %d-%d are active", shown, ports[1]-ports[0]+1, ports[0], ports[1]))
+
+	if sheetPath != "" {
+		if err := qr.WriteSheet(sheetPath, sheet, sheetColumns, pngOptions("")); err != nil {
+			renderer.PrintError("Failed to save sheet: " + err.Error())
+			return err
+		}
+		renderer.PrintSuccess(fmt.Sprintf("Sheet of %d QR codes saved to %s", shown, sheetPath))
+	}
+	return nil
+}
+
+// batchTitle returns the heading of port's QR code in a batch, keeping
+// any --qr-title.
+func batchTitle(port int) string {
+	if qrTitle != "" {
+		return fmt.Sprintf("%s (port %d)", qrTitle, port)
+	}
+	return fmt.Sprintf("Port %d", port)
+}
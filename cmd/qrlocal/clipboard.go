@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+)
+
+// errNoClipboard is returned when there is no clipboard to write to, such
+// as over SSH or in a container without a display server.
+var errNoClipboard = errors.New("no clipboard available (no display server found)")
+
+// maxClipboardSize is the most text, in bytes, put on the clipboard. Some
+// platforms' clipboards truncate or reject larger text without saying so.
+const maxClipboardSize = 256 << 10
+
+// errClipboardTooLarge is returned for text over maxClipboardSize.
+var errClipboardTooLarge = fmt.Errorf("too large for the clipboard (over %d KB)", maxClipboardSize>>10)
+
+// clipboardAttempts is how many times a clipboard write is tried before
+// giving up. The X clipboard manager sometimes isn't ready on the first try.
+const clipboardAttempts = 3
+
+// copyToClipboard writes text to the system clipboard, retrying transient
+// failures with a short backoff. Text over maxClipboardSize is refused
+// rather than risk a silently truncated copy.
+func copyToClipboard(text string) error {
+	if len(text) > maxClipboardSize {
+		return errClipboardTooLarge
+	}
+	if headless() || clipboard.Unsupported {
+		return errNoClipboard
+	}
+
+	delay := 100 * time.Millisecond
+	var err error
+	for attempt := 1; attempt <= clipboardAttempts; attempt++ {
+		if err = clipboard.WriteAll(text); err == nil {
+			return nil
+		}
+		if attempt < clipboardAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// headless reports whether this is a Unix-like desktop session without an
+// X11 or Wayland display, where no clipboard can exist.
+func headless() bool {
+	switch runtime.GOOS {
+	case "windows", "darwin", "android", "ios":
+		return false
+	}
+	return os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == ""
+}
+
+// copyAndReport copies text to the clipboard and reports the outcome.
+// what names the copied value in messages, e.g. "URL".
+func copyAndReport(renderer *qr.Renderer, text, what string) {
+	err := copyToClipboard(text)
+	switch {
+	case errors.Is(err, errNoClipboard):
+		renderer.PrintWarning("No clipboard available (no display server found); " + what + " was not copied")
+	case errors.Is(err, errClipboardTooLarge):
+		renderer.PrintWarning(fmt.Sprintf("%s is %s, %s; use --png or --data-uri instead", what, server.FormatFileSize(int64(len(text))), err))
+	case err != nil:
+		renderer.PrintError("Failed to copy " + what + " to clipboard: " + err.Error())
+	default:
+		renderer.PrintSuccess(what + " copied to clipboard!")
+	}
+}
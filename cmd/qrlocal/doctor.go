@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// providerDialTimeout bounds each provider reachability check.
+const providerDialTimeout = 3 * time.Second
+
+// check is the outcome of a single doctor check.
+type check struct {
+	name string
+	ok   bool
+	warn bool   // Failure that doesn't prevent normal use
+	info string // Detail shown after the name
+	hint string // Remediation shown on failure
+}
+
+// doctorCmd checks the environment for common problems
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment for common problems",
+	Long: `Run diagnostic checks for ssh, internet access, the clipboard, the config
+file and provider reachability, and print how to fix anything that fails.
+Include this output when reporting a bug.`,
+	Args: cobra.NoArgs,
+	// Config problems are reported as a check instead of aborting
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks := []check{checkSSH(), checkClipboard()}
+
+		loaded, configCheck := checkConfig()
+		checks = append(checks, configCheck)
+
+		online := checkOnline()
+		checks = append(checks, online)
+		if online.ok && loaded != nil {
+			checks = append(checks, checkProviders(loaded)...)
+		}
+
+		info := currentBuildInfo()
+		fmt.Printf("qrlocal %s (%s)\n\n", info.Version, info.Platform)
+
+		failed := 0
+		for _, c := range checks {
+			mark := statusMark(true)
+			switch {
+			case c.ok:
+			case c.warn:
+				mark = "!"
+			default:
+				mark = statusMark(false)
+				failed++
+			}
+			line := fmt.Sprintf("%s %s", mark, c.name)
+			if c.info != "" {
+				line += ": " + c.info
+			}
+			fmt.Println(line)
+			if !c.ok && c.hint != "" {
+				for _, hint := range strings.Split(c.hint, "\n") {
+					fmt.Println("    " + hint)
+				}
+			}
+		}
+
+		fmt.Println()
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		fmt.Println("No problems found.")
+		return nil
+	},
+}
+
+// checkSSH reports whether the ssh client needed for --public is installed.
+func checkSSH() check {
+	c := check{name: "ssh", ok: tunnel.HasSSH()}
+	if c.ok {
+		c.info = "found"
+		return c
+	}
+	c.info = "not found (needed for --public)"
+	c.hint = tunnel.SSHInstallHint()
+	if tools := tunnel.AlternativeTunnelTools(); len(tools) > 0 {
+		c.hint += "\nAlternatively, share ports with " + strings.Join(tools, " or ")
+	}
+	return c
+}
+
+// checkOnline reports whether the internet is reachable.
+func checkOnline() check {
+	c := check{name: "internet", ok: tunnel.IsOnline()}
+	if c.ok {
+		c.info = "online"
+		return c
+	}
+	c.info = "offline (needed for --public)"
+	c.hint = "Check your network connection; without it only local network sharing works."
+	return c
+}
+
+// checkClipboard reports whether --copy can work. It doesn't write to the
+// clipboard, so the user's clipboard contents are left alone.
+func checkClipboard() check {
+	c := check{name: "clipboard", warn: true}
+	switch {
+	case headless():
+		c.info = "no display server found (--copy won't work)"
+		c.hint = "The clipboard needs an X11 or Wayland session; over SSH, copy the printed URL instead."
+	case clipboard.Unsupported:
+		c.info = "no clipboard tool found (--copy won't work)"
+		if runtime.GOOS == "linux" {
+			c.hint = "Install xclip, xsel or wl-clipboard."
+		}
+	default:
+		c.ok = true
+		c.info = "available"
+	}
+	return c
+}
+
+// checkConfig loads and validates the config files, returning the loaded
+// config when it could be read.
+func checkConfig() (*config.Config, check) {
+	c := check{name: "config"}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		c.info = err.Error()
+		c.hint = "Fix the file, or run 'qrlocal config init' to write a fresh one."
+		return nil, c
+	}
+
+	var problems []string
+	if err := loaded.Validate(); err != nil {
+		problems = append(problems, strings.Split(err.Error(), "\n")...)
+	}
+	if _, err := tunnel.GetProvider(loaded.DefaultProvider, loaded); err != nil {
+		problem := fmt.Sprintf("default_provider: %v", err)
+		if suggestion, ok := tunnel.SuggestProvider(loaded.DefaultProvider, loaded); ok {
+			problem += fmt.Sprintf("; did you mean '%s'?", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	for _, level := range []string{loaded.QRLevel, loaded.QRLevelFloor} {
+		if _, err := qr.ParseLevel(level); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		c.info = "invalid"
+		c.hint = strings.Join(problems, "\n")
+		return loaded, c
+	}
+
+	c.ok = true
+	c.info = "valid"
+	if len(loaded.Sources) > 0 {
+		c.info += " (" + strings.Join(loaded.Sources, ", ") + ")"
+	} else {
+		c.info += " (defaults, no config file)"
+	}
+	return loaded, c
+}
+
+// checkProviders reports whether each provider's ssh endpoint accepts
+// connections. Only the default provider failing counts as an error.
+func checkProviders(loaded *config.Config) []check {
+	var checks []check
+	for _, info := range loaded.ProviderInfos() {
+		addr := net.JoinHostPort(info.Host, fmt.Sprint(info.Port))
+		c := check{
+			name: "provider " + info.Name,
+			warn: !info.Default,
+		}
+
+		conn, err := net.DialTimeout("tcp", addr, providerDialTimeout)
+		if err != nil {
+			c.info = addr + " unreachable"
+			c.hint = "The service may be down or blocked by a firewall; try another --provider."
+		} else {
+			conn.Close()
+			c.ok = true
+			c.info = addr + " reachable"
+		}
+		if info.Default {
+			c.info += " (default)"
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/qr"
+)
+
+// savedDownloadCounts returns the download counts saved by earlier
+// sessions when persist_downloads is set, and nil otherwise.
+func savedDownloadCounts(renderer *qr.Renderer) map[string]uint64 {
+	if !cfg.PersistDownloads {
+		return nil
+	}
+	state, err := config.LoadState("")
+	if err != nil {
+		renderer.PrintWarning("Starting download counts from zero: " + err.Error())
+		return nil
+	}
+	return state.Downloads
+}
+
+// saveDownloadCounts stores the server's download counts in the state
+// file when persist_downloads is set.
+func saveDownloadCounts(renderer *qr.Renderer) {
+	if !cfg.PersistDownloads || activeServer == nil {
+		return
+	}
+	state, err := config.LoadState("")
+	if err == nil {
+		state.RecordDownloads(activeServer.DownloadCounts())
+		err = state.Save("")
+	}
+	if err != nil {
+		renderer.PrintWarning("Failed to save download counts: " + err.Error())
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/hash/qrlocal/pkg/notify"
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+	"github.com/hash/qrlocal/pkg/tunnel"
+)
+
+// watchTunnelEvents consumes tunnel lifecycle events and reacts to them
+// until the tunnel's event channel is closed.
+func watchTunnelEvents(t *tunnel.Tunnel, renderer *qr.Renderer) {
+	for ev := range t.Events() {
+		switch ev.Type {
+		case tunnel.EventEstablished:
+			logTunnelEvent("tunnel established via %s: %s", ev.Provider, ev.URL)
+			sendNotification(renderer, "qrlocal: tunnel ready", ev.URL)
+			runUpHook(ev, renderer)
+		case tunnel.EventDropped:
+			logTunnelEvent("tunnel via %s dropped: %v", ev.Provider, ev.Err)
+			renderer.PrintError("Tunnel connection dropped")
+			sendNotification(renderer, "qrlocal: tunnel dropped", "Lost connection to "+ev.Provider)
+			runDownHook("dropped", ev.URL, ev.Provider, renderer)
+		}
+	}
+}
+
+// watchServerRoot reports when the served directory becomes unavailable,
+// e.g. because its drive was unplugged, and with --exit-if-missing ends
+// the session.
+func watchServerRoot(s *server.Server, renderer *qr.Renderer) {
+	err := <-s.RootLost()
+	renderer.PrintError(err.Error())
+	sendNotification(renderer, "qrlocal: directory unavailable", s.Directory())
+	if !exitIfMissing {
+		renderer.PrintInfo("Visitors get a 503 error until it is back; press Ctrl+C to stop")
+		return
+	}
+	select {
+	case shutdownRequests <- err:
+	default:
+	}
+}
+
+// sendNotification shows a desktop notification if --notify is set.
+// Missing notifiers are not an error; they are only reported in debug output.
+func sendNotification(renderer *qr.Renderer, title, message string) {
+	if !notifyFlag {
+		return
+	}
+	if err := notify.Send(title, message); err != nil {
+		if errors.Is(err, notify.ErrUnavailable) {
+			renderer.PrintDebug("Desktop notifications unavailable on this system")
+			return
+		}
+		renderer.PrintDebug("Notification failed: " + err.Error())
+	}
+}
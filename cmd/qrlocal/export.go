@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/spf13/cobra"
+)
+
+// Export flags
+var (
+	pngPath   string // Write QR code as PNG to this path
+	svgPath   string // Write QR code as SVG to this path
+	qrWidth   int    // Absolute image size in pixels
+	qrScale   int    // Pixels per QR module
+	dataURI   bool   // Print the PNG as a data URI on stdout
+	verifyQR  bool   // Decode the rendered QR code to check it scans
+	asciiQR   bool   // Draw the terminal QR code with ASCII characters
+	qrFormat  string // Characters the terminal QR code is drawn with
+	invertQR  bool   // Swap dark and light modules in the terminal QR code
+	copyText  bool   // Copy the terminal QR code text to the clipboard
+	levelFlag string // Error correction level override
+	sixelQR   bool   // Draw the terminal QR code as a sixel image if supported
+	caption   bool   // Print the URL beneath the QR code in PNG output
+	termScale int    // Terminal cells per QR module
+	termPad   int    // Blank lines and columns around the terminal output
+	labelText string // Label printed above the URL caption
+	qrTitle   string // Heading shown above the terminal QR code
+	pngFD     int    // Write the PNG to this inherited file descriptor
+	pngStyle  string // Shape of the modules in PNG output
+	pngLogo   string // Image drawn in the center of PNG output
+	qrTextFD  int    // Write the plain QR code text to this inherited file descriptor
+
+	// forceUnicode keeps Unicode output when the locale says otherwise
+	forceUnicode bool
+
+	// qrLevel is the error correction level chosen for the current URL
+	qrLevel qr.Level
+
+	// logoImage is the --png-logo image, loaded by validateExportFlags
+	logoImage image.Image
+)
+
+// addExportFlags registers the image export flags on cmd.
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&pngPath, "png", "", "Save the QR code as a PNG image")
+	cmd.Flags().IntVar(&pngFD, "png-fd", -1, "Write the QR code PNG to this inherited file descriptor, e.g. a pipe from a parent process")
+	cmd.Flags().IntVar(&qrTextFD, "qr-text-fd", -1, "Write the plain Unicode QR code text, without styling or URL, to this inherited file descriptor")
+	cmd.Flags().StringVar(&svgPath, "svg", "", "Save the QR code as an SVG image")
+	cmd.Flags().StringVar(&pngStyle, "png-style", "square", "Shape of the QR modules in PNG output: square, dots or rounded")
+	cmd.Flags().StringVar(&pngLogo, "png-logo", "", "Draw this image (PNG, JPEG or GIF) in the center of PNG output; uses the highest error correction")
+	cmd.Flags().BoolVar(&caption, "png-caption", false, "Print the URL as text beneath the QR code in PNG output")
+	cmd.Flags().StringVar(&labelText, "label", "", "Label printed above the URL caption (with --png-caption)")
+	cmd.Flags().IntVar(&qrWidth, "qr-width", 0, "Exported image size in pixels, including the quiet zone")
+	cmd.Flags().StringVar(&levelFlag, "level", "", "QR error correction level: low, medium, high or highest (default from config)")
+	cmd.Flags().IntVar(&termScale, "qr-scale", 1, "Repeat each terminal QR module this many times, for large or high-DPI screens")
+	cmd.Flags().IntVar(&termPad, "pad", 0, "Surround the terminal output with this many blank lines and columns in the QR code's background color")
+	cmd.Flags().BoolVar(&sixelQR, "sixel", false, "Draw the QR code as a sixel image on terminals that support it")
+	cmd.Flags().BoolVar(&asciiQR, "ascii", false, "Draw the QR code with ASCII characters instead of Unicode blocks")
+	cmd.Flags().StringVar(&qrFormat, "format", "", "Terminal QR characters: half, full, ascii or custom:<dark><light>")
+	cmd.Flags().StringVar(&qrTitle, "qr-title", "", "Heading shown above the QR code instead of the default")
+	cmd.Flags().BoolVar(&invertQR, "invert", false, "Invert the terminal QR code colors")
+	cmd.Flags().BoolVar(&copyText, "copy-qr-ascii", false, "Copy the text QR code, as shown, to the clipboard")
+	cmd.Flags().BoolVar(&verifyQR, "verify-qr", false, "Scan the rendered QR code and any --png file and warn if they don't match the URL")
+	cmd.Flags().BoolVar(&showImage, "show-image", false, "Open the QR code as an image in the default viewer instead of drawing it here")
+	cmd.Flags().BoolVar(&dataURI, "data-uri", false, "Print the QR code PNG as a base64 data URI to stdout")
+	cmd.Flags().IntVar(&qrScale, "scale", 0, fmt.Sprintf("Exported image pixels per module (default %d)", qr.DefaultScale))
+}
+
+// asciiOutput reports whether terminal output should be limited to ASCII
+// because the locale doesn't look like UTF-8.
+func asciiOutput() bool {
+	return !forceUnicode && !qr.UnicodeSupported()
+}
+
+// statusMark returns the check or cross printed before a line of plain
+// output, in ASCII when the locale isn't UTF-8.
+func statusMark(ok bool) string {
+	switch {
+	case asciiOutput() && ok:
+		return "+"
+	case asciiOutput():
+		return "x"
+	case ok:
+		return "✓"
+	}
+	return "✗"
+}
+
+// textOptions returns the terminal QR text options from the flags.
+// An explicit --format draws text even with --sixel, and is honored even
+// when the locale isn't UTF-8.
+func textOptions() qr.TextOptions {
+	dark, light, _ := qr.ParseFormat(qrFormat)
+	return qr.TextOptions{
+		ASCII:  asciiQR || (qrFormat == "" && asciiOutput()),
+		Invert: invertQR,
+		Level:  qrLevel,
+		Scale:  termScale,
+		Dark:   dark,
+		Light:  light,
+		Sixel:  sixelQR && !asciiQR && qrFormat == "" && qr.SixelSupported(),
+		Pad:    termPad,
+	}
+}
+
+// validateFormat checks --format and that it agrees with --ascii.
+func validateFormat() error {
+	if _, _, err := qr.ParseFormat(qrFormat); err != nil {
+		return err
+	}
+	if asciiQR && qrFormat != "" && !strings.EqualFold(qrFormat, "ascii") {
+		return errors.New("--ascii and --format cannot be used together")
+	}
+	return nil
+}
+
+// imageOptions returns the image options from the export flags.
+func imageOptions() qr.ImageOptions {
+	return qr.ImageOptions{Size: qrWidth, Scale: qrScale, Level: qrLevel}
+}
+
+// pngOptions returns the image options for PNG output of url, with the
+// --png-style module shape, the --png-logo logo and the caption when
+// --png-caption is set.
+func pngOptions(url string) qr.ImageOptions {
+	opts := imageOptions()
+	opts.Style, _ = qr.ParseModuleStyle(pngStyle)
+	// The logo makes the PNG use the highest level
+	opts.Logo = logoImage
+	if caption {
+		opts.Caption = url
+		opts.Label = labelText
+	}
+	return opts
+}
+
+// validateExportFlags checks the export flags before any work is done.
+func validateExportFlags() error {
+	if qrWidth != 0 && qrScale != 0 {
+		return errors.New("--qr-width and --scale cannot be used together")
+	}
+	if qrWidth < 0 || qrScale < 0 {
+		return errors.New("--qr-width and --scale must be positive")
+	}
+	if termScale < 1 {
+		return errors.New("--qr-scale must be at least 1")
+	}
+	if termPad < 0 {
+		return errors.New("--pad must not be negative")
+	}
+	if pngFD == 0 || pngFD < -1 {
+		return errors.New("--png-fd must be 1 or a descriptor opened by the parent process")
+	}
+	if qrTextFD == 0 || qrTextFD < -1 {
+		return errors.New("--qr-text-fd must be 1 or a descriptor opened by the parent process")
+	}
+	if pngFD >= 0 && pngFD == qrTextFD {
+		return errors.New("--png-fd and --qr-text-fd must be different descriptors")
+	}
+	if _, err := qr.ParseModuleStyle(pngStyle); err != nil {
+		return err
+	}
+	if labelText != "" && !caption {
+		return errors.New("--label requires --png-caption")
+	}
+	if pngLogo != "" {
+		// Loaded now so a bad image fails before anything is shared
+		logo, err := qr.LoadLogo(pngLogo)
+		if err != nil {
+			return err
+		}
+		logoImage = logo
+	}
+	if _, err := levelPolicy(); err != nil {
+		return err
+	}
+	return validateFormat()
+}
+
+// levelPolicy builds the error correction policy from the config and --level.
+// An explicit --level is used as-is, without lowering it for long URLs.
+func levelPolicy() (qr.LevelPolicy, error) {
+	if levelFlag != "" {
+		level, err := qr.ParseLevel(levelFlag)
+		if err != nil {
+			return qr.LevelPolicy{}, err
+		}
+		return qr.LevelPolicy{Level: level}, nil
+	}
+
+	level, err := qr.ParseLevel(cfg.QRLevel)
+	if err != nil {
+		return qr.LevelPolicy{}, fmt.Errorf("qr_level: %w", err)
+	}
+	floor, err := qr.ParseLevel(cfg.QRLevelFloor)
+	if err != nil {
+		return qr.LevelPolicy{}, fmt.Errorf("qr_level_floor: %w", err)
+	}
+	return qr.LevelPolicy{Level: level, Threshold: cfg.QRLevelThreshold, Floor: floor}, nil
+}
+
+// prepareQR chooses the error correction level for url and applies the
+// text options to the renderer. It must run before the QR code is drawn.
+func prepareQR(url string, renderer *qr.Renderer) {
+	policy, _ := levelPolicy()
+	level, lowered := policy.LevelFor(url)
+	if lowered {
+		renderer.PrintInfo(fmt.Sprintf("Long URL: using %s error correction to keep the QR code small", level))
+	}
+	qrLevel = level
+	if logoImage != nil && levelFlag != "" && level != qr.LevelHighest {
+		renderer.PrintInfo(fmt.Sprintf("--png-logo hides part of the code, so the PNG uses highest error correction; --level %s applies to the other output", level))
+	}
+	if sixelQR && !qr.SixelSupported() {
+		renderer.PrintDebug("Terminal doesn't appear to support sixel; using text QR code")
+	}
+	renderer.SetTextOptions(textOptions())
+
+	if showImage && !canShowImage() {
+		renderer.PrintInfo("No display for --show-image; showing the QR code here instead")
+	}
+
+	if strings.HasPrefix(qrFormat, "custom:") {
+		renderer.PrintWarning("Custom QR characters may not scan reliably; check with a phone or --verify-qr")
+	}
+	if termScale > 1 && renderTerminalQR() && !textOptions().Sixel {
+		warnIfTooWide(url, renderer)
+	}
+}
+
+// warnIfTooWide warns when the terminal QR code is wider than the
+// terminal, since wrapped lines make it unscannable.
+func warnIfTooWide(url string, renderer *qr.Renderer) {
+	columns, _ := terminalSize(os.Stderr)
+	if columns <= 0 {
+		return
+	}
+	text, err := renderer.QRText(url)
+	if err != nil {
+		return
+	}
+	line, _, _ := strings.Cut(text, "\n")
+	if width := utf8.RuneCountInString(line); width > columns {
+		renderer.PrintWarning(fmt.Sprintf("QR code is %d columns wide but the terminal has %d; lower --qr-scale or widen the window", width, columns))
+	}
+}
+
+// renderTerminalQR reports whether the QR should be drawn in the terminal.
+// In quiet mode a data URI replaces the terminal output entirely, and an
+// image shown with --show-image or the --oneline status line always does.
+func renderTerminalQR() bool {
+	return !onelineOutput && !(quietFlag && dataURI) && !(showImage && canShowImage())
+}
+
+// verifyPNGFile reads back the --png file and warns if it doesn't scan.
+func verifyPNGFile(url string, renderer *qr.Renderer) {
+	data, err := os.ReadFile(pngPath)
+	if err == nil {
+		err = qr.VerifyPNG(data, url)
+	}
+	if err != nil {
+		renderer.PrintWarning(err.Error())
+		return
+	}
+	renderer.PrintDebug("PNG QR code verified")
+}
+
+// exportQR runs the steps that follow rendering: verifying the terminal
+// QR code and writing any requested image files.
+// With --verify-qr, the terminal QR code and the --png file are scanned
+// back to check that they hold url.
+func exportQR(url string, renderer *qr.Renderer) error {
+	if verifyQR {
+		if err := renderer.VerifyQR(url); err != nil {
+			renderer.PrintWarning(err.Error())
+		} else {
+			renderer.PrintDebug("Rendered QR code verified")
+		}
+	}
+
+	if pngPath != "" {
+		if err := qr.WritePNG(url, pngPath, pngOptions(url)); err != nil {
+			renderer.PrintError("Failed to save PNG: " + err.Error())
+			return err
+		}
+		renderer.PrintSuccess("QR code saved to " + pngPath)
+		if verifyQR {
+			verifyPNGFile(url, renderer)
+		}
+	}
+
+	if showImage && canShowImage() {
+		if err := openQRImage(url, renderer); err != nil {
+			renderer.PrintError("Failed to show QR image: " + err.Error())
+			return err
+		}
+	}
+
+	if pngFD >= 0 {
+		if err := writePNGToFD(url, pngFD); err != nil {
+			renderer.PrintError("Failed to write PNG: " + err.Error())
+			return err
+		}
+		renderer.PrintDebug(fmt.Sprintf("QR code PNG written to file descriptor %d", pngFD))
+	}
+
+	if qrTextFD >= 0 {
+		if err := writeTextToFD(url, qrTextFD); err != nil {
+			renderer.PrintError("Failed to write QR code text: " + err.Error())
+			return err
+		}
+		renderer.PrintDebug(fmt.Sprintf("QR code text written to file descriptor %d", qrTextFD))
+	}
+
+	if copyText {
+		text, err := renderer.QRText(url)
+		if err != nil {
+			renderer.PrintError("Failed to generate QR code: " + err.Error())
+			return err
+		}
+		copyAndReport(renderer, text, "QR code text")
+	}
+
+	if dataURI {
+		uri, err := qr.DataURI(url, pngOptions(url))
+		if err != nil {
+			renderer.PrintError("Failed to encode data URI: " + err.Error())
+			return err
+		}
+		fmt.Println(uri)
+	}
+
+	if svgPath != "" {
+		if err := qr.WriteSVG(url, svgPath, imageOptions()); err != nil {
+			renderer.PrintError("Failed to save SVG: " + err.Error())
+			return err
+		}
+		renderer.PrintSuccess("QR code saved to " + svgPath)
+	}
+
+	return nil
+}
+
+// writePNGToFD writes the PNG for url to the inherited file descriptor fd
+// and closes it, so a parent process reading from a pipe sees EOF. The
+// standard streams are left open.
+func writePNGToFD(url string, fd int) error {
+	data, err := qr.EncodePNG(url, pngOptions(url))
+	if err != nil {
+		return err
+	}
+	return writeToFD(fd, data)
+}
+
+// writeTextToFD writes the QR code for url as plain Unicode block text to
+// the inherited file descriptor fd and closes it like writePNGToFD. The
+// text has no styling, border or URL, whatever the terminal output looks
+// like, but uses the same error correction level.
+func writeTextToFD(url string, fd int) error {
+	text, err := qr.GenerateQRText(url, qr.TextOptions{Level: qrLevel})
+	if err != nil {
+		return err
+	}
+	return writeToFD(fd, []byte(text))
+}
+
+// writeToFD writes data to the inherited file descriptor fd, closing it
+// afterwards unless it is one of the standard streams.
+func writeToFD(fd int, data []byte) error {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if f == nil {
+		return fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return fmt.Errorf("file descriptor %d is not open", fd)
+	}
+
+	if fd <= 2 {
+		_, err := f.Write(data)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/qr"
+)
+
+// offerConfigInit asks first-time users on an interactive terminal whether
+// to create a config file, since many never find 'qrlocal config init'.
+// It asks once: the answer is remembered in the state file either way.
+func offerConfigInit(renderer *qr.Renderer) {
+	if configPath != "" || len(cfg.Sources) > 0 || quietFlag || noBanner || !stdinIsTerminal() {
+		return
+	}
+	path, err := config.DefaultConfigPath()
+	if err != nil || config.Exists(path) {
+		return
+	}
+	state, err := config.LoadState("")
+	if err != nil || state.ConfigOffered {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "No config file found. Create %s with the default settings? [y/N] ", path)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	state.ConfigOffered = true
+	if err := state.Save(""); err != nil {
+		renderer.PrintDebug("Not remembering the answer: " + err.Error())
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		if err := config.InitConfig(path); err != nil {
+			renderer.PrintWarning("Failed to create config: " + err.Error())
+			return
+		}
+		renderer.PrintSuccess("Config file created at " + path)
+	default:
+		renderer.PrintInfo("Run 'qrlocal config init' if you want one later.")
+	}
+}
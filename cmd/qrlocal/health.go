@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// resetHealthCmd forgets which providers failed recently
+var resetHealthCmd = &cobra.Command{
+	Use:   "reset-health",
+	Short: "Forget recent provider failures",
+	Long: `Clears the provider health recorded in ~/.qrlocal/state.yaml, so providers
+that failed recently are no longer tried last.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := config.LoadState("")
+		if err != nil {
+			return err
+		}
+		state.ResetHealth()
+		if err := state.Save(""); err != nil {
+			return err
+		}
+		fmt.Println("Provider health reset")
+		return nil
+	},
+}
+
+// tunnelProviders returns the providers to try, in order: --provider
+// alone, or the default provider followed by fallback_providers, with the
+// ones that failed repeatedly of late moved to the end. Providers that
+// can't honor the flags, such as --tcp, are left out. extraArgs are added
+// to each provider's ssh options.
+func tunnelProviders(extraArgs []string, renderer *qr.Renderer) ([]tunnel.Provider, error) {
+	if cfg.SharedProvidersWarning != "" {
+		renderer.PrintWarning(cfg.SharedProvidersWarning)
+	}
+
+	names := []string{providerFlag}
+	if providerFlag == "" {
+		names = []string{cfg.DefaultProvider}
+		for _, name := range cfg.FallbackProviders {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		names = healthOrder(names, renderer)
+	}
+
+	var providers []tunnel.Provider
+	var unsupported error
+	for _, name := range names {
+		provider, err := tunnel.GetProvider(name, cfg)
+		if errors.Is(err, tunnel.ErrUnknownProvider) {
+			if suggestion, ok := tunnel.SuggestProvider(name, cfg); ok {
+				err = fmt.Errorf("%w '%s'; did you mean '%s'?", tunnel.ErrUnknownProvider, name, suggestion)
+				renderer.PrintError(err.Error())
+			} else {
+				renderer.PrintError(fmt.Sprintf("Unknown provider: %s", name))
+			}
+			renderer.PrintInfo("Use 'qrlocal providers' to see available providers.")
+			return nil, err
+		} else if err != nil {
+			renderer.PrintError(err.Error())
+			return nil, err
+		}
+
+		// Refuse flags the provider can't honor before connecting
+		if tcpFlag {
+			if err := provider.Require(tunnel.CapTCP, "TCP tunnels"); err != nil {
+				if unsupported == nil {
+					unsupported = err
+				}
+				renderer.PrintDebug(err.Error())
+				continue
+			}
+		}
+
+		if clientLabel != "" {
+			provider.ClientLabel = clientLabel
+		}
+		provider.ExtraArgs = append(slices.Clip(provider.ExtraArgs), extraArgs...)
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 0 {
+		renderer.PrintError(unsupported.Error())
+		renderer.PrintInfo("Try --provider serveo, or set tcp_url_regex for the provider in your config.")
+		return nil, unsupported
+	}
+	return providers, nil
+}
+
+// healthOrder moves providers that failed repeatedly within the health
+// cooldown behind the others, keeping the order otherwise.
+func healthOrder(names []string, renderer *qr.Renderer) []string {
+	if len(names) < 2 {
+		return names
+	}
+	state, err := config.LoadState("")
+	if err != nil {
+		renderer.PrintDebug("Ignoring provider health: " + err.Error())
+		return names
+	}
+
+	now := time.Now()
+	sort.SliceStable(names, func(i, j int) bool {
+		return !state.Unhealthy(names[i], now) && state.Unhealthy(names[j], now)
+	})
+	for _, name := range names {
+		if state.Unhealthy(name, now) {
+			renderer.PrintInfo(fmt.Sprintf("%s failed %d times recently; trying it last", name, state.Providers[name].Failures))
+		}
+	}
+	return names
+}
+
+// recordProviderHealth remembers whether connecting to the named provider
+// worked. Failing to save the state file is not an error.
+func recordProviderHealth(name string, ok bool, renderer *qr.Renderer) {
+	state, err := config.LoadState("")
+	if err != nil {
+		renderer.PrintDebug("Not recording provider health: " + err.Error())
+		return
+	}
+	if ok {
+		state.RecordSuccess(name, time.Now())
+	} else {
+		state.RecordFailure(name, time.Now())
+	}
+	if err := state.Save(""); err != nil {
+		renderer.PrintDebug("Not recording provider health: " + err.Error())
+	}
+}
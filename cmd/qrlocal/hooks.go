@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/tunnel"
+)
+
+// hookTimeout bounds how long an --on-up or --on-down command may run
+// before it is killed.
+const hookTimeout = 30 * time.Second
+
+var (
+	onUpHook   string // Command run when the tunnel comes up
+	onDownHook string // Command run when the tunnel goes down
+
+	// hooksRunning tracks hooks still running, so shutdown can wait for them
+	hooksRunning sync.WaitGroup
+
+	// downHookOnce runs the on-down hook once per session, whether the
+	// tunnel dropped or was closed
+	downHookOnce sync.Once
+)
+
+// validateHooks checks that --on-up and --on-down are only given for
+// public tunnels, the only ones with up and down events.
+func validateHooks(changed func(string) bool) error {
+	if (changed("on-up") || changed("on-down")) && !publicFlag {
+		return fmt.Errorf("--on-up and --on-down require --public")
+	}
+	return nil
+}
+
+// runUpHook starts the --on-up command for an established tunnel.
+func runUpHook(ev tunnel.Event, renderer *qr.Renderer) {
+	if onUpHook == "" {
+		return
+	}
+	hooksRunning.Add(1)
+	go func() {
+		defer hooksRunning.Done()
+		runHook("on-up", onUpHook, hookEnv("up", ev.URL, ev.Provider, ""), renderer)
+	}()
+}
+
+// runDownHook starts the --on-down command the first time the tunnel goes
+// down, with reason "dropped" or "closed".
+func runDownHook(reason, url, provider string, renderer *qr.Renderer) {
+	if onDownHook == "" {
+		return
+	}
+	downHookOnce.Do(func() {
+		hooksRunning.Add(1)
+		go func() {
+			defer hooksRunning.Done()
+			runHook("on-down", onDownHook, hookEnv("down", url, provider, reason), renderer)
+		}()
+	})
+}
+
+// finishHooks runs the on-down hook for a tunnel closed at shutdown, then
+// waits for every hook still running, so none is cut off by the exit.
+func finishHooks(renderer *qr.Renderer) {
+	if activeTunnel != nil {
+		runDownHook("closed", activeTunnel.PublicURL(), activeTunnel.ProviderName(), renderer)
+	}
+	hooksRunning.Wait()
+}
+
+// hookEnv returns the environment for a hook: qrlocal's own, plus the
+// tunnel's details.
+func hookEnv(event, url, provider, reason string) []string {
+	env := append(os.Environ(),
+		"QRLOCAL_EVENT="+event,
+		"QRLOCAL_URL="+url,
+		"QRLOCAL_PROVIDER="+provider,
+	)
+	if reason != "" {
+		env = append(env, "QRLOCAL_REASON="+reason)
+	}
+	return env
+}
+
+// runHook runs command through the shell, reporting a failure or timeout
+// as a warning. The command's output is captured rather than mixed into
+// the QR code display, and shown with the failure or in debug output.
+func runHook(name, command string, env []string, renderer *qr.Renderer) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = env
+	// Don't wait forever on output pipes held open by the command's children
+	cmd.WaitDelay = 2 * time.Second
+
+	output, err := cmd.CombinedOutput()
+	trimmed := strings.TrimSpace(string(output))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", hookTimeout)
+		}
+		message := fmt.Sprintf("%s hook failed: %v", name, err)
+		if trimmed != "" {
+			message += ": " + trimmed
+		}
+		renderer.PrintWarning(message)
+		return
+	}
+	renderer.PrintDebug(fmt.Sprintf("%s hook finished", name))
+	if trimmed != "" {
+		renderer.PrintDebug(trimmed)
+	}
+}
+
+// shellCommand returns a command running command through the system shell.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/spf13/cobra"
+)
+
+// Kiosk command flags
+var (
+	kioskURLs     []string      // Entries to cycle through
+	kioskLabels   []string      // Optional label per entry
+	kioskInterval time.Duration // Time each entry stays on screen
+	kioskShuffle  bool          // Randomize the order on every pass
+)
+
+// kioskEntry is a single screen in kiosk mode.
+type kioskEntry struct {
+	content string
+	label   string
+}
+
+// kioskCmd cycles full-screen QR codes until interrupted
+var kioskCmd = &cobra.Command{
+	Use:   "kiosk",
+	Short: "Cycle through several QR codes full-screen",
+	Long: `Display a rotating set of QR codes, one per screen, for booths and big displays.
+Each entry stays on screen for --interval. Press Ctrl+C to exit.`,
+	Example: `  qrlocal kiosk --urls https://example.com,https://example.org --interval 10s
+  qrlocal kiosk --urls https://a.dev,https://b.dev --labels "Docs,Demo" --shuffle`,
+	Args: cobra.NoArgs,
+	RunE: runKiosk,
+}
+
+func init() {
+	kioskCmd.Flags().StringSliceVar(&kioskURLs, "urls", nil, "Comma-separated URLs or text to display")
+	kioskCmd.Flags().StringSliceVar(&kioskLabels, "labels", nil, "Comma-separated labels, one per URL")
+	kioskCmd.Flags().DurationVar(&kioskInterval, "interval", 10*time.Second, "How long each QR code stays on screen")
+	kioskCmd.Flags().BoolVar(&kioskShuffle, "shuffle", false, "Randomize the order of entries")
+	kioskCmd.Flags().BoolVar(&asciiQR, "ascii", false, "Draw the QR code with ASCII characters instead of Unicode blocks")
+	kioskCmd.Flags().StringVar(&qrFormat, "format", "", "QR characters: half, full, ascii or custom:<dark><light>")
+	kioskCmd.Flags().BoolVar(&invertQR, "invert", false, "Invert the terminal QR code colors")
+	kioskCmd.Flags().StringVar(&levelFlag, "level", "", "QR error correction level: low, medium, high or highest (default from config)")
+}
+
+// kioskEntries pairs the --urls and --labels flags.
+func kioskEntries() ([]kioskEntry, error) {
+	if len(kioskURLs) == 0 {
+		return nil, errors.New("--urls is required")
+	}
+	if len(kioskLabels) > 0 && len(kioskLabels) != len(kioskURLs) {
+		return nil, fmt.Errorf("got %d labels for %d URLs", len(kioskLabels), len(kioskURLs))
+	}
+
+	entries := make([]kioskEntry, 0, len(kioskURLs))
+	for i, u := range kioskURLs {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			return nil, errors.New("--urls contains an empty entry")
+		}
+		entry := kioskEntry{content: u}
+		if len(kioskLabels) > 0 {
+			entry.label = strings.TrimSpace(kioskLabels[i])
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// screenSize returns the terminal size, falling back to $COLUMNS/$LINES
+// and then to 80x24.
+func screenSize() (int, int) {
+	width, height := terminalSize(os.Stdout)
+	if width <= 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if height <= 0 {
+		height, _ = strconv.Atoi(os.Getenv("LINES"))
+	}
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+	return width, height
+}
+
+// runKiosk renders each entry in turn until SIGINT or SIGTERM.
+func runKiosk(cmd *cobra.Command, args []string) error {
+	renderer := newRenderer()
+
+	entries, err := kioskEntries()
+	if err != nil {
+		renderer.PrintError(err.Error())
+		return err
+	}
+	if kioskInterval <= 0 {
+		err := errors.New("--interval must be positive")
+		renderer.PrintError(err.Error())
+		return err
+	}
+	if err := validateFormat(); err != nil {
+		renderer.PrintError(err.Error())
+		return err
+	}
+	policy, err := levelPolicy()
+	if err != nil {
+		renderer.PrintError(err.Error())
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	defer qr.ResetScreen(os.Stdout)
+
+	ticker := time.NewTicker(kioskInterval)
+	defer ticker.Stop()
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+
+	for {
+		if kioskShuffle {
+			rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		}
+
+		for _, i := range order {
+			entry := entries[i]
+			qrLevel, _ = policy.LevelFor(entry.content)
+			renderer.SetTextOptions(textOptions())
+
+			width, height := screenSize()
+			if err := renderer.RenderScreen(os.Stdout, entry.content, entry.label, width, height); err != nil {
+				qr.ResetScreen(os.Stdout)
+				renderer.PrintError(fmt.Sprintf("Failed to render %q: %v", entry.content, err))
+				return err
+			}
+
+			select {
+			case <-sigChan:
+				return nil
+			case <-ticker.C:
+			}
+		}
+	}
+}
@@ -53,47 +53,98 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"reflect"
 	"runtime"
-	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/atotto/clipboard"
 	"github.com/hash/qrlocal/pkg/config"
 	"github.com/hash/qrlocal/pkg/network"
 	"github.com/hash/qrlocal/pkg/qr"
 	"github.com/hash/qrlocal/pkg/server"
 	"github.com/hash/qrlocal/pkg/tunnel"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var (
 	version = "0.0.1-alpha"
 
 	// Flags
-	publicFlag   bool
-	copyFlag     bool
-	quietFlag    bool
-	providerFlag string
-	configPath   string
-	openFlag     bool          // Open URL in browser automatically
-	durationFlag time.Duration // Auto-close after duration
+	publicFlag    bool
+	copyFlag      bool
+	quietFlag     bool
+	noBanner      bool // Hide informational and success messages
+	providerFlag  string
+	configPath    string
+	setFlags      []string      // Config overrides for this run, as key=value
+	openFlag      bool          // Open URL in browser automatically
+	durationFlag  time.Duration // Auto-close after duration
+	maxAge        time.Duration // Hard limit on how long a share stays up
+	exitIfMissing bool          // Stop serving when the served directory disappears
+	notifyFlag    bool          // Desktop notifications on tunnel events
+	clientLabel   string        // Identifies this client to the tunnel provider
+	waitForPort   time.Duration // How long to wait for the port to come up
+	hostFlag      string        // Host to advertise instead of the local IP
+	targetFlag    string        // Host the public tunnel forwards to
+	tcpFlag       bool          // Forward raw TCP instead of HTTP
+	serveDir      string        // Serve this directory instead of sharing a running service
+	httpsFlag     bool          // Use https:// in the local URL instead of detecting it
+	ifaceFlag     string        // Use this network interface's address in the local URL
+	sshOpts       []string      // Extra ssh options for the tunnel, passed verbatim
+	verifyTunnel  bool          // Wait for the public URL to answer before showing it
+	debugFlag     bool          // Print debug messages
+	noCenter      bool          // Left-align the QR output instead of centering it
+	themeFlag     string        // Preset color scheme for the terminal output
 
 	// Serve command flags
-	servePort    int
-	spaMode      bool   // SPA mode: fallback to index.html for missing routes
-	showListing  bool   // Show directory listing instead of serving index.html
-	passwordFlag string // Basic auth password
+	servePort     int
+	portRange     string        // Range of ports to pick the first free one from
+	spaMode       bool          // SPA mode: fallback to index.html for missing routes
+	showListing   bool          // Show directory listing instead of serving index.html
+	markdownFlag  bool          // Render README.md or index.md as the landing page
+	indexFlag     []string      // Directory index file names, in order of preference
+	passwordFlag  string        // Basic auth password
+	templateFlag  string        // Custom directory listing template
+	tlsCert       string        // TLS certificate file
+	tlsKey        string        // TLS private key file
+	h2cFlag       bool          // Allow HTTP/2 over cleartext
+	splashFlag    string        // Splash page shown before content
+	keepAliveFlag time.Duration // TCP keepalive interval for served connections
+	uploadFlag    bool          // Accept file uploads
+	rejectTypes   []string      // Upload content types to refuse
+	excludeFlag   []string      // Glob patterns to hide and block
+	gitignoreFlag bool          // Also exclude paths ignored by .gitignore
+	mountFlags    []string      // More directories to serve, as /prefix=dir
+	cacheMB       int           // In-memory cache size for small files
+
+	// Providers command flags
+	providersJSON bool
+
+	// Config show command flags
+	configShowJSON bool
+	configShowYAML bool
 
 	// Loaded config
 	cfg *config.Config
 
+	// serviceIPv6Only is set when the shared port only answers over IPv6
+	serviceIPv6Only bool
+
+	// quietExplicit records whether --quiet was passed, so a config
+	// reload doesn't override it
+	quietExplicit bool
+
 	// Active resources for cleanup
 	activeTunnel *tunnel.Tunnel
 	activeServer *server.Server
@@ -110,19 +161,40 @@ var rootCmd = &cobra.Command{
 	Short:   "Generate QR codes for sharing local services",
 	Long:    `qrlocal is a CLI tool that generates QR codes for local network addresses or public URLs via SSH tunnels.`,
 	Version: version,
-	Args:    cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// With --serve the port is optional; the server picks one
+		if cmd.Flags().Changed("serve") || cmd.Flags().Changed("serve-stdin") {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		if cmd.Flags().Changed("range") {
+			if len(args) > 0 {
+				return fmt.Errorf("--range replaces the port argument")
+			}
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// Load config file
 		var err error
-		cfg, err = config.Load(configPath)
+		cfg, err = loadConfig()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
+		if _, err := qr.ParseTheme(themeFlag); err != nil {
+			return err
+		}
 		return nil
 	},
 	RunE: runQRLocal,
 }
 
+// loadConfig loads the config files and environment with the --set
+// overrides on top.
+func loadConfig() (*config.Config, error) {
+	return config.LoadWithOverrides(configPath, setFlags)
+}
+
 // configCmd is the parent command for config-related subcommands
 var configCmd = &cobra.Command{
 	Use:   "config",
@@ -160,7 +232,7 @@ var configInitCmd = &cobra.Command{
 			return fmt.Errorf("failed to create config: %w", err)
 		}
 
-		fmt.Printf("✓ Config file created at %s\n", path)
+		fmt.Printf("%s Config file created at %s\n", statusMark(true), path)
 		return nil
 	},
 }
@@ -179,26 +251,70 @@ var configShowCmd = &cobra.Command{
 			}
 		}
 
+		switch {
+		case configShowJSON && configShowYAML:
+			return fmt.Errorf("--json and --yaml cannot be used together")
+		case configShowJSON:
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(cfg)
+		case configShowYAML:
+			for _, source := range cfg.Sources {
+				fmt.Printf("# Loaded from %s\n", source)
+			}
+			for _, env := range cfg.EnvOverrides {
+				fmt.Printf("# Overridden by $%s\n", env)
+			}
+			for _, key := range cfg.SetOverrides {
+				fmt.Printf("# %s overridden by --set\n", key)
+			}
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			defer enc.Close()
+			return enc.Encode(cfg)
+		}
+
 		fmt.Printf("Config file: %s\n", path)
 		if !config.Exists(path) {
 			fmt.Println("(using defaults, no config file found)")
 		}
+		for _, source := range cfg.Sources {
+			if source != path {
+				fmt.Printf("Project config: %s\n", source)
+			}
+		}
 		fmt.Println()
 
 		fmt.Printf("Default Provider: %s\n", cfg.DefaultProvider)
 		fmt.Printf("Copy to Clipboard: %v\n", cfg.CopyToClipboard)
 		fmt.Printf("Quiet Mode: %v\n", cfg.QuietMode)
+		if len(cfg.EnvOverrides) > 0 {
+			fmt.Printf("Environment Overrides: %s\n", strings.Join(cfg.EnvOverrides, ", "))
+		}
+		if len(cfg.SetOverrides) > 0 {
+			fmt.Printf("--set Overrides: %s\n", strings.Join(cfg.SetOverrides, ", "))
+		}
 		fmt.Println()
 
 		fmt.Println("Built-in Providers:")
-		for name := range cfg.Providers {
-			fmt.Printf("  - %s\n", name)
+		for name, p := range cfg.Providers {
+			printProviderName(name, p)
 		}
 
 		if len(cfg.CustomProviders) > 0 {
 			fmt.Println("\nCustom Providers:")
-			for name := range cfg.CustomProviders {
-				fmt.Printf("  - %s\n", name)
+			for name, p := range cfg.CustomProviders {
+				printProviderName(name, p)
+			}
+		}
+
+		if cfg.ProvidersURL != "" {
+			fmt.Printf("\nShared Providers (from %s):\n", cfg.ProvidersURL)
+			for name, p := range cfg.SharedProviders {
+				printProviderName(name, p)
+			}
+			if cfg.SharedProvidersWarning != "" {
+				fmt.Printf("Warning: %s\n", cfg.SharedProvidersWarning)
 			}
 		}
 
@@ -206,50 +322,67 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+// printProviderName prints a provider's name for config show, followed by
+// its description if it has one.
+func printProviderName(name string, p config.ProviderConfig) {
+	if p.Description == "" {
+		fmt.Printf("  - %s\n", name)
+		return
+	}
+	fmt.Printf("  - %s: %s\n", name, oneLine(p.Description))
+}
+
+// oneLine collapses the line breaks and runs of spaces in a multi-line
+// YAML description.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 // providersCmd lists all available providers
 var providersCmd = &cobra.Command{
 	Use:   "providers",
 	Short: "List available tunnel providers",
 	Long:  `Displays all available tunnel providers including built-in and custom providers.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Available Tunnel Providers:")
-		fmt.Println()
+		infos := cfg.ProviderInfos()
 
-		// Get provider names and sort them
-		names := make([]string, 0, len(cfg.Providers))
-		for name := range cfg.Providers {
-			names = append(names, name)
+		if providersJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(infos)
 		}
-		sort.Strings(names)
+
+		fmt.Println("Available Tunnel Providers:")
+		fmt.Println()
 
 		fmt.Println("Built-in Providers:")
-		for _, name := range names {
-			p := cfg.Providers[name]
+		printedCustomHeader, printedSharedHeader := false, false
+		for _, p := range infos {
+			if !p.Builtin && !p.Shared && !printedCustomHeader {
+				fmt.Println("\nCustom Providers:")
+				printedCustomHeader = true
+			}
+			if p.Shared && !printedSharedHeader {
+				fmt.Printf("\nShared Providers (from %s):\n", cfg.ProvidersURL)
+				printedSharedHeader = true
+			}
 			marker := ""
-			if name == cfg.DefaultProvider {
+			if p.Default {
 				marker = " (default)"
 			}
-			fmt.Printf("  %-15s %s@%s:%d%s\n", name, p.User, p.Host, p.Port, marker)
-		}
-
-		if len(cfg.CustomProviders) > 0 {
-			customNames := make([]string, 0, len(cfg.CustomProviders))
-			for name := range cfg.CustomProviders {
-				customNames = append(customNames, name)
+			fmt.Printf("  %-15s %s@%s:%d%s\n", p.Name, p.User, p.Host, p.Port, marker)
+			if p.Description != "" {
+				fmt.Printf("  %-15s %s\n", "", oneLine(p.Description))
 			}
-			sort.Strings(customNames)
-
-			fmt.Println("\nCustom Providers:")
-			for _, name := range customNames {
-				p := cfg.CustomProviders[name]
-				marker := ""
-				if name == cfg.DefaultProvider {
-					marker = " (default)"
-				}
-				fmt.Printf("  %-15s %s@%s:%d%s\n", name, p.User, p.Host, p.Port, marker)
+			if len(p.Capabilities) > 0 {
+				fmt.Printf("  %-15s supports: %s\n", "", strings.Join(p.Capabilities, ", "))
 			}
 		}
 
+		if cfg.SharedProvidersWarning != "" {
+			fmt.Printf("\nWarning: %s\n", cfg.SharedProvidersWarning)
+		}
+
 		fmt.Println("\nUsage: qrlocal <port> --public --provider <name>")
 		return nil
 	},
@@ -268,60 +401,245 @@ If no directory is specified, the current directory is used.`,
 func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to config file (default: ~/.qrlocal/config.yaml)")
+	rootCmd.PersistentFlags().StringArrayVar(&setFlags, "set", nil, "Override a config setting for this run, e.g. quiet_mode=true or providers.serveo.port=2222 (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "Print debug messages")
+	rootCmd.PersistentFlags().StringVar(&themeFlag, "theme", qr.DefaultTheme, "Color scheme for the output: "+strings.Join(qr.ThemeNames(), ", "))
+	rootCmd.PersistentFlags().BoolVar(&noCenter, "no-center", false, "Left-align the QR code instead of centering it in 80 columns")
+	rootCmd.PersistentFlags().BoolVar(&forceUnicode, "force-unicode", false, "Use Unicode blocks and symbols even if the locale isn't UTF-8")
 
 	// Root command flags
 	rootCmd.Flags().BoolVar(&publicFlag, "public", false, "Create a public URL via SSH tunnel")
 	rootCmd.Flags().StringVar(&providerFlag, "provider", "", "Tunnel provider (default from config)")
 	rootCmd.Flags().BoolVar(&copyFlag, "copy", false, "Copy the generated URL to system clipboard")
 	rootCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Suppress all output except URL and QR code")
+	rootCmd.Flags().BoolVar(&summaryJSON, "json", false, "Print the session summary as JSON on stdout when the tunnel closes")
+	rootCmd.Flags().BoolVar(&onelineOutput, "oneline", false, "Print one status line with the URL instead of the QR code and banner, e.g. for CI logs")
+	rootCmd.Flags().BoolVar(&noBanner, "no-banner", false, "Hide informational messages and prompts, but keep the QR code, warnings and errors")
 	rootCmd.Flags().BoolVarP(&openFlag, "open", "o", false, "Open URL in browser automatically")
 	rootCmd.Flags().DurationVarP(&durationFlag, "duration", "d", 0, "Auto-close after duration (e.g., 30m, 1h)")
+	rootCmd.Flags().DurationVar(&maxAge, "max-age", 0, "Close the share after this long at the latest, whatever --duration says (default from config)")
+	rootCmd.Flags().DurationVar(&waitForPort, "wait-for-port", 0, "Wait up to this long for the port to start listening (e.g., 30s)")
+	rootCmd.Flags().BoolVar(&httpsFlag, "https", false, "Use https:// in the local URL (detected automatically; --https=false forces http://)")
+	rootCmd.Flags().StringVar(&hostFlag, "host", "", "Hostname or IP to use in the URL instead of the local IP")
+	rootCmd.Flags().StringVar(&ifaceFlag, "interface", "", "Use this network interface's address in the URL (e.g. eth0, en0)")
+	rootCmd.MarkFlagsMutuallyExclusive("host", "interface")
+	rootCmd.Flags().BoolVar(&exposeAnyway, "i-know-what-im-doing", false, "With --serve --public, share even if the directory looks sensitive")
+	rootCmd.Flags().StringVar(&batchRange, "range", "", "Show a QR code for each active port in this range instead of one port, e.g. 3000-3005")
+	rootCmd.Flags().StringVar(&sheetPath, "sheet", "", "With --range, also save all the QR codes, labeled, in one PNG for printing")
+	rootCmd.Flags().IntVar(&sheetColumns, "sheet-columns", 3, "QR codes per row on the --sheet image")
+	rootCmd.Flags().StringVar(&serveDir, "serve", "", "Serve files from this directory, then share it (like 'qrlocal serve')")
+	rootCmd.Flags().BoolVar(&serveStdin, "serve-stdin", false, "Serve what is piped to standard input at the root, then share it")
+	rootCmd.Flags().IntVar(&stdinLimitMB, "stdin-limit-mb", 100, "Largest input --serve-stdin accepts, in MB")
+	rootCmd.MarkFlagsMutuallyExclusive("range", "serve", "serve-stdin")
+	rootCmd.Flags().BoolVar(&tcpFlag, "tcp", false, "Forward raw TCP instead of HTTP (requires --public and a provider with TCP support)")
+	rootCmd.Flags().StringVar(&targetFlag, "target", tunnel.DefaultLocalHost, "Host on your network the public tunnel forwards to (requires --public)")
+	rootCmd.Flags().BoolVar(&notifyFlag, "notify", false, "Show a desktop notification when the tunnel is ready or drops")
+	rootCmd.Flags().StringVar(&onUpHook, "on-up", "", "Shell command to run when the tunnel comes up, with $QRLOCAL_URL and $QRLOCAL_PROVIDER set")
+	rootCmd.Flags().StringVar(&onDownHook, "on-down", "", "Shell command to run when the tunnel drops or is closed")
+	rootCmd.Flags().StringVar(&clientLabel, "client-label", "", "Label identifying this tunnel to the provider")
+	rootCmd.Flags().StringArrayVar(&sshOpts, "ssh-opt", nil, "Extra ssh option for the tunnel, passed verbatim (repeatable), e.g. \"-J bastion\" or ServerAliveInterval=30")
+	rootCmd.Flags().BoolVar(&verifyTunnel, "verify-tunnel", false, "Wait until the public URL answers without a server error before showing it")
+	rootCmd.Flags().StringVar(&passwordFlag, "password", "", "Require a basic auth password, via a proxy that also passes WebSockets")
+	rootCmd.Flags().StringArrayVar(&injectHeaders, "inject-header", nil, "Add this header to each request forwarded to the service, via a local proxy, e.g. \"X-Forwarded-Proto: https\" (repeatable)")
+	addExportFlags(rootCmd)
+	addShortFlag(rootCmd)
+	addLogFlags(rootCmd)
 
 	// Serve command flags
 	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "Port to serve on")
+	serveCmd.Flags().StringVar(&portRange, "port-range", "", "Serve on the first free port in this range, e.g. 8000-8099")
+	serveCmd.MarkFlagsMutuallyExclusive("port", "port-range")
 	serveCmd.Flags().BoolVar(&spaMode, "spa", false, "SPA mode: serve index.html for all routes (for React, Vue, etc.)")
 	serveCmd.Flags().BoolVar(&showListing, "listing", false, "Show directory listing instead of index.html")
+	serveCmd.Flags().BoolVar(&exitIfMissing, "exit-if-missing", false, "Stop and exit with an error if the served directory disappears, e.g. an unplugged drive")
+	serveCmd.Flags().StringSliceVar(&indexFlag, "index", nil, "Serve the first of these files found in a directory as its page (default index.html), e.g. default.html,index.html")
+	serveCmd.Flags().BoolVar(&markdownFlag, "markdown", false, "Show a directory's README.md or index.md, rendered, when it has no index.html")
 	serveCmd.Flags().BoolVar(&publicFlag, "public", false, "Create a public URL via SSH tunnel")
 	serveCmd.Flags().StringVar(&providerFlag, "provider", "", "Tunnel provider (default from config)")
 	serveCmd.Flags().BoolVar(&copyFlag, "copy", false, "Copy the generated URL to system clipboard")
 	serveCmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "Suppress all output except URL and QR code")
+	serveCmd.Flags().BoolVar(&summaryJSON, "json", false, "Print the session summary as JSON on stdout when the tunnel closes")
+	serveCmd.Flags().BoolVar(&onelineOutput, "oneline", false, "Print one status line with the URL instead of the QR code and banner, e.g. for CI logs")
+	serveCmd.Flags().BoolVar(&noBanner, "no-banner", false, "Hide informational messages and prompts, but keep the QR code, warnings and errors")
 	serveCmd.Flags().BoolVarP(&openFlag, "open", "o", false, "Open URL in browser automatically")
 	serveCmd.Flags().DurationVarP(&durationFlag, "duration", "d", 0, "Auto-close after duration (e.g., 30m, 1h)")
+	serveCmd.Flags().DurationVar(&maxAge, "max-age", 0, "Close the share after this long at the latest, whatever --duration says (default from config)")
 	serveCmd.Flags().StringVar(&passwordFlag, "password", "", "Require password for basic auth")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file (enables HTTPS and HTTP/2)")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file")
+	serveCmd.Flags().BoolVar(&h2cFlag, "h2c", false, "Allow HTTP/2 over cleartext (h2c) connections")
+	serveCmd.Flags().StringVar(&splashFlag, "splash", "", "HTML splash page shown once per visitor before the files")
+	serveCmd.Flags().StringArrayVar(&excludeFlag, "exclude", nil, "Hide and block paths matching this glob, e.g. .git or '*.key' (repeatable)")
+	serveCmd.Flags().StringArrayVar(&mountFlags, "mount", nil, "Also serve a directory under a URL path, e.g. /docs=./docs (repeatable)")
+	serveCmd.Flags().BoolVar(&gitignoreFlag, "respect-gitignore", false, "Hide and block files ignored by the served directory's .gitignore")
+	serveCmd.Flags().BoolVar(&exposeAnyway, "i-know-what-im-doing", false, "Share publicly even if the directory looks sensitive (home directory, / or credential files)")
+	serveCmd.Flags().IntVar(&cacheMB, "cache-mb", 0, "Cache small files in memory, up to this many MB in total (0 disables)")
+	serveCmd.Flags().BoolVar(&uploadFlag, "upload", false, "Allow uploading files into served directories")
+	serveCmd.Flags().StringSliceVar(&rejectTypes, "reject-types", nil, "Refuse uploads whose content is one of these types (e.g. application/x-executable,application/x-msdownload)")
+	serveCmd.Flags().BoolVar(&tokenLink, "token-link", false, "Put a one-time login token in the QR code, so scanning it skips the password or access code")
+	serveCmd.Flags().DurationVar(&tokenTTL, "token-ttl", defaultTokenTTL, "How long the --token-link token stays valid")
+	serveCmd.Flags().BoolVar(&totpFlag, "totp", false, "Require a 6-digit time-based access code, printed here, before serving")
+	serveCmd.Flags().StringVar(&totpSecret, "totp-secret", "", "Base32 secret for --totp codes, e.g. to use an authenticator app (implies --totp)")
+	serveCmd.Flags().DurationVar(&keepAliveFlag, "keepalive", 0, "TCP keepalive interval for dead-peer detection (default 30s, negative disables)")
+	serveCmd.Flags().StringVar(&templateFlag, "template", "", "Custom HTML template file for directory listings")
+	serveCmd.Flags().StringVar(&hostFlag, "host", "", "Hostname or IP to use in the URL instead of the local IP")
+	serveCmd.Flags().StringVar(&ifaceFlag, "interface", "", "Use this network interface's address in the URL (e.g. eth0, en0)")
+	serveCmd.MarkFlagsMutuallyExclusive("host", "interface")
+	serveCmd.Flags().BoolVar(&notifyFlag, "notify", false, "Show a desktop notification when the tunnel is ready or drops")
+	serveCmd.Flags().StringVar(&onUpHook, "on-up", "", "Shell command to run when the tunnel comes up, with $QRLOCAL_URL and $QRLOCAL_PROVIDER set")
+	serveCmd.Flags().StringVar(&onDownHook, "on-down", "", "Shell command to run when the tunnel drops or is closed")
+	serveCmd.Flags().StringVar(&clientLabel, "client-label", "", "Label identifying this tunnel to the provider")
+	serveCmd.Flags().StringArrayVar(&sshOpts, "ssh-opt", nil, "Extra ssh option for the tunnel, passed verbatim (repeatable), e.g. \"-J bastion\" or ServerAliveInterval=30")
+	serveCmd.Flags().BoolVar(&verifyTunnel, "verify-tunnel", false, "Wait until the public URL answers without a server error before showing it")
+	addExportFlags(serveCmd)
+	addShortFlag(serveCmd)
+	addLogFlags(serveCmd)
+	addAccessLogFlags(serveCmd)
+
+	// Providers command flags
+	providersCmd.Flags().BoolVar(&providersJSON, "json", false, "Output providers as JSON")
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Output the effective config as JSON")
+	configShowCmd.Flags().BoolVar(&configShowYAML, "yaml", false, "Output the effective config as YAML")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Output build information as JSON")
 
 	// Add subcommands
 	configCmd.AddCommand(configInitCmd)
 	configCmd.AddCommand(configShowCmd)
 	rootCmd.AddCommand(configCmd)
+	providersCmd.AddCommand(resetHealthCmd)
+	probeCmd.Flags().BoolVar(&probeNoSave, "no-save", false, "Only report which spec works; don't write it to the config file")
+	providersCmd.AddCommand(probeCmd)
 	rootCmd.AddCommand(providersCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(kioskCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(doctorCmd)
 }
 
 func runQRLocal(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("serve") {
+		return runQRLocalServe(cmd, args)
+	}
+	if serveStdin {
+		return runQRLocalStdin(cmd, args)
+	}
+	if cmd.Flags().Changed("stdin-limit-mb") {
+		return fmt.Errorf("--stdin-limit-mb requires --serve-stdin")
+	}
+	if cmd.Flags().Changed("range") {
+		return runBatch(cmd)
+	}
+	if cmd.Flags().Changed("sheet") || cmd.Flags().Changed("sheet-columns") {
+		return fmt.Errorf("--sheet requires --range")
+	}
+
 	// Parse port number
 	port, err := strconv.Atoi(args[0])
 	if err != nil || port < 1 || port > 65535 {
 		return fmt.Errorf("invalid port number: %s (must be 1-65535)", args[0])
 	}
 
-	// Apply config defaults if flags not explicitly set
-	if !cmd.Flags().Changed("quiet") && cfg.QuietMode {
-		quietFlag = true
+	if err := validateExportFlags(); err != nil {
+		return err
 	}
-	if !cmd.Flags().Changed("copy") && cfg.CopyToClipboard {
-		copyFlag = true
+	if err := resolveMaxAge(cmd); err != nil {
+		return err
+	}
+
+	if tcpFlag {
+		if !publicFlag {
+			return fmt.Errorf("--tcp requires --public")
+		}
+		if shortWord != "" || openFlag || passwordFlag != "" || len(injectHeaders) > 0 {
+			return fmt.Errorf("--tcp can't be combined with --short, --open, --password or --inject-header")
+		}
+	}
+	if _, err := parseInjectHeaders(injectHeaders); err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("target") {
+		if !publicFlag {
+			return fmt.Errorf("--target requires --public")
+		}
+		var err error
+		if targetFlag, err = network.NormalizeHost(targetFlag); err != nil {
+			return fmt.Errorf("invalid --target: %w", err)
+		}
+		if err := network.ValidateHost(targetFlag); err != nil {
+			return fmt.Errorf("invalid --target: %w", err)
+		}
+	}
+
+	if err := validateHooks(cmd.Flags().Changed); err != nil {
+		return err
+	}
+	if err := validateLogFlags(cmd.Flags().Changed); err != nil {
+		return err
 	}
 
+	applyConfigDefaults(cmd)
+
 	// Create renderer
-	renderer := qr.NewRenderer(quietFlag)
+	renderer := newRenderer()
+	offerConfigInit(renderer)
+	if cfg.ProjectConfigWarning != "" {
+		renderer.PrintWarning(cfg.ProjectConfigWarning)
+	}
 
-	// Check if port is active
-	if !network.IsPortActive(port) {
+	// Check if port is active, optionally waiting for it to come up
+	if waitForPort < 0 {
+		return fmt.Errorf("--wait-for-port must be positive")
+	}
+	checkHost := targetFlag
+	active := false
+	if targetFlag != tunnel.DefaultLocalHost {
+		if waitForPort > 0 && !network.IsAddrActive(checkHost, port) {
+			renderer.PrintInfo(fmt.Sprintf("Waiting up to %s for port %d...", waitForPort, port))
+		}
+		active = network.WaitForAddr(checkHost, port, waitForPort)
+	} else {
+		if waitForPort > 0 && !network.IsPortActive(port) {
+			renderer.PrintInfo(fmt.Sprintf("Waiting up to %s for port %d...", waitForPort, port))
+		}
+		checkHost, active = network.WaitForLocalListener(port, waitForPort)
+	}
+	if !active {
+		if targetFlag != tunnel.DefaultLocalHost {
+			renderer.PrintError(fmt.Sprintf("No service is reachable at %s", net.JoinHostPort(checkHost, strconv.Itoa(port))))
+			renderer.PrintInfo("Check that the target host is up and on your network.")
+			return fmt.Errorf("%s:%d is not reachable", checkHost, port)
+		}
 		renderer.PrintError(fmt.Sprintf("No service is listening on port %d", port))
 		renderer.PrintInfo("Make sure your server is running before sharing it.")
 		return fmt.Errorf("port %d is not active", port)
 	}
 
+	// A service that only listens on IPv6 is shared over IPv6: the tunnel
+	// forwards to the address it answered on, unless that is loopback,
+	// which localhost covers, and the local URL uses an IPv6 address
+	if targetFlag == tunnel.DefaultLocalHost && strings.Contains(checkHost, ":") {
+		renderer.PrintDebug(fmt.Sprintf("Port %d only answers over IPv6, on %s", port, checkHost))
+		if checkHost != "::1" {
+			targetFlag = checkHost
+		}
+		serviceIPv6Only = true
+	}
+
+	// Share the proxy instead of the port itself. The proxy runs on this
+	// machine, so a tunnel forwards to it rather than to --target.
+	if passwordFlag != "" || len(injectHeaders) > 0 {
+		scheme := serviceScheme(cmd, checkHost, port, renderer)
+		port, err = startAuthProxy(checkHost, scheme, port, renderer)
+		if err != nil {
+			return err
+		}
+		targetFlag = tunnel.DefaultLocalHost
+		if host := proxyHost(); host != "" {
+			targetFlag = host
+		}
+	}
+
 	var url string
 	var isPublic bool
 
@@ -334,21 +652,21 @@ func runQRLocal(cmd *cobra.Command, args []string) error {
 		isPublic = true
 	} else {
 		// Generate local URL
-		url, err = network.GenerateLocalURL(port)
+		url, err = localURL(localScheme(cmd, checkHost, port, renderer), port)
 		if err != nil {
-			renderer.PrintError("Failed to determine local IP address")
+			renderer.PrintError("Failed to generate local URL: " + err.Error())
 			return err
 		}
 		isPublic = false
 	}
 
+	if err := startShortLink(url, renderer); err != nil {
+		return err
+	}
+
 	// Copy to clipboard if requested
 	if copyFlag {
-		if err := clipboard.WriteAll(url); err != nil {
-			renderer.PrintError("Failed to copy URL to clipboard: " + err.Error())
-		} else {
-			renderer.PrintSuccess("URL copied to clipboard!")
-		}
+		copyAndReport(renderer, url, "URL")
 	}
 
 	// Open in browser if requested
@@ -360,27 +678,238 @@ func runQRLocal(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	prepareQR(url, renderer)
+
 	// Render QR code
-	if err := renderer.RenderOutput(url, isPublic); err != nil {
-		renderer.PrintError("Failed to generate QR code")
+	if renderTerminalQR() {
+		if err := renderer.RenderOutput(url, isPublic); err != nil {
+			renderer.PrintError("Failed to generate QR code")
+			return err
+		}
+	}
+	if onelineOutput {
+		printStatusLine(url)
+	}
+
+	if err := exportQR(url, renderer); err != nil {
 		return err
 	}
 
-	// If we have a tunnel, wait for shutdown signal
-	if activeTunnel != nil {
+	// If we have a tunnel, short link or image on screen, wait for shutdown signal
+	if activeTunnel != nil || activeRedirector != nil || activeProxy != nil || shownImage != "" {
 		if durationFlag > 0 {
 			renderer.PrintInfo(fmt.Sprintf("Tunnel will auto-close in %s...", durationFlag))
-			waitForShutdownWithTimeout(renderer, durationFlag)
+			waitForShutdown(renderer, durationFlag, cleanupTunnel)
 		} else {
 			renderer.PrintInfo("Press Ctrl+C to stop the tunnel and exit...")
-			waitForShutdown(renderer)
+			waitForShutdown(renderer, 0, cleanupTunnel)
+		}
+	}
+
+	return nil
+}
+
+// localURL returns the URL for port on this machine, using the advertised
+// host from --host or the config instead of the local IP when set. The
+// local IP is the best ranked address, or that of --interface.
+func localURL(scheme string, port int) (string, error) {
+	host := hostFlag
+	if host == "" && ifaceFlag == "" {
+		host = cfg.Host
+	}
+	if host == "" {
+		ip, err := localIP()
+		if err != nil {
+			return "", err
+		}
+		host = ip
+	} else {
+		var err error
+		if host, err = network.NormalizeHost(host); err != nil {
+			return "", err
+		}
+		if err := network.ValidateHost(host); err != nil {
+			return "", err
+		}
+	}
+	return network.GenerateURL(scheme, host, port), nil
+}
+
+// localIP returns the address of --interface, or else the best ranked
+// local address, IPv6 when the shared service only listens on IPv6.
+func localIP() (string, error) {
+	if ifaceFlag != "" {
+		return network.GetInterfaceIP(ifaceFlag)
+	}
+	if serviceIPv6Only {
+		return network.GetLocalIPv6()
+	}
+	return network.GetLocalIP()
+}
+
+// localScheme returns the scheme of the local URL for port. The proxy
+// always speaks http, whatever the service behind it does.
+func localScheme(cmd *cobra.Command, host string, port int, renderer *qr.Renderer) string {
+	if activeProxy != nil {
+		return "http"
+	}
+	return serviceScheme(cmd, host, port, renderer)
+}
+
+// serviceScheme returns the scheme the service on port speaks: https with
+// --https, http with --https=false, and otherwise whether it completes a
+// TLS handshake.
+func serviceScheme(cmd *cobra.Command, host string, port int, renderer *qr.Renderer) string {
+	if cmd.Flags().Changed("https") {
+		if httpsFlag {
+			return "https"
 		}
+		return "http"
 	}
 
+	scheme := network.DetectScheme(host, port)
+	if scheme == "https" {
+		renderer.PrintDebug(fmt.Sprintf("Port %d speaks TLS; using an https:// URL", port))
+	}
+	return scheme
+}
+
+// newRenderer creates a renderer configured from the global flags.
+func newRenderer() *qr.Renderer {
+	renderer := qr.NewRenderer(quietFlag)
+	renderer.SetNoBanner(noBanner || onelineOutput)
+	renderer.SetDebug(debugFlag)
+	renderer.SetASCII(asciiOutput())
+	renderer.SetMessages(qr.Messages(cfg.Messages))
+	renderer.SetTitle(qrTitle)
+	renderer.SetTextOptions(textOptions())
+	renderer.SetCentered(!noCenter)
+	if theme, err := qr.ParseTheme(themeFlag); err == nil {
+		renderer.SetTheme(theme)
+	}
+	columns, _ := terminalSize(os.Stderr)
+	renderer.SetWidth(columns)
+	return renderer
+}
+
+// alternativeCommand returns the command that shares port with a
+// non-SSH tunneling tool.
+func alternativeCommand(tool string, port int) string {
+	switch tool {
+	case "cloudflared":
+		return fmt.Sprintf("cloudflared tunnel --url http://localhost:%d", port)
+	case "ngrok":
+		return fmt.Sprintf("ngrok http %d", port)
+	}
+	return tool
+}
+
+// runQRLocalServe handles "qrlocal [port] --serve <dir>", and
+// --serve-stdin once standard input is read: the built-in
+// server is started first and, with --public, the tunnel is opened to the
+// port it actually bound, so a single QR code for the final URL is shown.
+func runQRLocalServe(cmd *cobra.Command, args []string) error {
+	if tcpFlag || cmd.Flags().Changed("target") || waitForPort != 0 || len(injectHeaders) > 0 {
+		return fmt.Errorf("--serve and --serve-stdin can't be combined with --tcp, --target, --wait-for-port or --inject-header")
+	}
+	if len(args) > 0 {
+		port, err := strconv.Atoi(args[0])
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port number: %s (must be 1-65535)", args[0])
+		}
+		servePort = port
+	}
+	return runServe(cmd, []string{serveDir})
+}
+
+// resolveMaxAge checks --max-age, or takes max_age from the config when
+// the flag isn't given.
+func resolveMaxAge(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("max-age") {
+		if maxAge <= 0 {
+			return fmt.Errorf("--max-age must be positive")
+		}
+		return nil
+	}
+	age, err := cfg.MaxAgeDuration()
+	if err != nil {
+		return err
+	}
+	maxAge = age
 	return nil
 }
 
+// parsePortRange parses a --port-range value such as "8000-8099". An
+// empty value means no range.
+func parsePortRange(value string) ([2]int, error) {
+	if value == "" {
+		return [2]int{}, nil
+	}
+	lo, hi, ok := strings.Cut(value, "-")
+	first, err1 := strconv.Atoi(strings.TrimSpace(lo))
+	last, err2 := strconv.Atoi(strings.TrimSpace(hi))
+	if !ok || err1 != nil || err2 != nil || first < 1 || last > 65535 || first > last {
+		return [2]int{}, fmt.Errorf("invalid port range: %s (must be like 8000-8099, within 1-65535)", value)
+	}
+	return [2]int{first, last}, nil
+}
+
+// parseMounts parses --mount values of the form /prefix=dir into a map of
+// URL prefix to directory.
+func parseMounts(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	mounts := make(map[string]string, len(values))
+	for _, value := range values {
+		prefix, dir, ok := strings.Cut(value, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") || dir == "" {
+			return nil, fmt.Errorf("invalid mount: %s (must be like /docs=./docs)", value)
+		}
+		if _, dup := mounts[prefix]; dup {
+			return nil, fmt.Errorf("%s is mounted twice", prefix)
+		}
+		mounts[prefix] = dir
+	}
+	return mounts, nil
+}
+
+// applyConfigDefaults fills in flags that weren't set on the command line
+// from the loaded config, so that flags > env > config files > defaults.
+func applyConfigDefaults(cmd *cobra.Command) {
+	quietExplicit = cmd.Flags().Changed("quiet")
+	if !quietExplicit && cfg.QuietMode {
+		quietFlag = true
+	}
+	if !cmd.Flags().Changed("no-banner") && cfg.NoBanner {
+		noBanner = true
+	}
+	if !cmd.Flags().Changed("copy") && cfg.CopyToClipboard {
+		copyFlag = true
+	}
+	if !cmd.Flags().Changed("on-up") {
+		onUpHook = cfg.OnUp
+	}
+	if !cmd.Flags().Changed("on-down") {
+		onDownHook = cfg.OnDown
+	}
+}
+
+// tunnelVerifyWindow is how long --verify-tunnel waits for the public URL
+// to answer without a server error.
+const tunnelVerifyWindow = 20 * time.Second
+
 func createPublicTunnel(port int, renderer *qr.Renderer) (string, error) {
+	// Tunnels need the system ssh client
+	if !tunnel.HasSSH() {
+		renderer.PrintError("ssh was not found on this system.")
+		renderer.PrintInfo(tunnel.SSHInstallHint())
+		for _, tool := range tunnel.AlternativeTunnelTools() {
+			renderer.PrintInfo(fmt.Sprintf("Or share the port with %s, which doesn't need ssh: %s", tool, alternativeCommand(tool, port)))
+		}
+		return "", fmt.Errorf("ssh not found")
+	}
+
 	// Check internet connectivity
 	if !tunnel.IsOnline() {
 		renderer.PrintError("You appear to be offline.")
@@ -389,59 +918,173 @@ func createPublicTunnel(port int, renderer *qr.Renderer) (string, error) {
 		return "", fmt.Errorf("no internet connection")
 	}
 
-	// Determine provider name
-	providerName := providerFlag
-	if providerName == "" {
-		providerName = cfg.DefaultProvider
+	if clientLabel != "" {
+		if err := tunnel.ValidateClientLabel(clientLabel); err != nil {
+			renderer.PrintError(err.Error())
+			return "", err
+		}
+	}
+
+	var extraArgs []string
+	for _, opt := range sshOpts {
+		args, err := tunnel.ParseSSHOption(opt)
+		if err != nil {
+			renderer.PrintError(err.Error())
+			return "", err
+		}
+		extraArgs = append(extraArgs, args...)
 	}
 
-	// Get provider from config or built-in
-	provider, err := tunnel.GetProvider(providerName, cfg)
+	providers, err := tunnelProviders(extraArgs, renderer)
 	if err != nil {
-		renderer.PrintError(fmt.Sprintf("Unknown provider: %s", providerName))
-		renderer.PrintInfo("Use 'qrlocal providers' to see available providers.")
 		return "", err
 	}
 
-	renderer.PrintInfo(fmt.Sprintf("Creating public tunnel via %s...", providerName))
-
-	// Create tunnel
-	tunnelCfg := tunnel.Config{
-		LocalPort: port,
-		Provider:  provider,
+	if err := openTunnelLog(); err != nil {
+		renderer.PrintError(err.Error())
+		return "", err
+	}
+	var providerLog io.Writer
+	if tunnelLog != nil {
+		providerLog = tunnelLog
 	}
 
-	t, err := tunnel.NewTunnel(tunnelCfg)
-	if err != nil {
+	// Try each provider in turn until one connects
+	var t *tunnel.Tunnel
+	for i, provider := range providers {
+		renderer.PrintInfo(fmt.Sprintf("Creating public tunnel via %s...", provider.Name))
+		logTunnelEvent("connecting to %s, forwarding to %s:%d", provider.Name, targetFlag, port)
+
+		t, err = tunnel.NewTunnel(tunnel.Config{
+			LocalHost: targetFlag,
+			LocalPort: port,
+			Provider:  provider,
+			TCP:       tcpFlag,
+			Log:       providerLog,
+		})
+		if err == nil {
+			recordProviderHealth(provider.Name, true, renderer)
+			break
+		}
+		logTunnelEvent("%s failed: %v", provider.Name, err)
+
+		// Hitting the local max_concurrent limit says nothing about the provider
+		if !errors.Is(err, tunnel.ErrProviderLimit) {
+			recordProviderHealth(provider.Name, false, renderer)
+		}
+		if i < len(providers)-1 {
+			renderer.PrintWarning(fmt.Sprintf("%s failed: %v", provider.Name, err))
+			continue
+		}
 		renderer.PrintError("Failed to create tunnel: " + err.Error())
 		renderer.PrintInfo("This might be a temporary issue. Please try again in a moment.")
 		return "", err
 	}
 
 	activeTunnel = t
+	if verifyTunnel && !tcpFlag {
+		renderer.PrintInfo("Checking that the public URL responds...")
+		if err := tunnel.WaitHealthy(context.Background(), t.PublicURL(), tunnelVerifyWindow); err != nil {
+			renderer.PrintWarning(fmt.Sprintf("%s isn't responding properly yet (%v); the service behind it may not be ready", t.PublicURL(), err))
+		}
+	}
 	renderer.PrintSuccess("Tunnel established!")
+	go watchTunnelEvents(t, renderer)
 
 	return t.PublicURL(), nil
 }
 
-func waitForShutdown(renderer *qr.Renderer) {
-	// Set up signal handling
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// rootWatchInterval is how often serve checks that the served directory
+// is still there.
+const rootWatchInterval = 5 * time.Second
 
+// shutdownRequests ends the session for reasons other than a signal or
+// timer, such as the served directory disappearing with --exit-if-missing.
+var shutdownRequests = make(chan error, 1)
+
+// waitForShutdown blocks until an interrupt is received, until duration
+// or --max-age elapses when non-zero, or until a shutdown is requested,
+// and then runs cleanup. It returns the error of a shutdown request.
+// SIGHUP reloads the config file without stopping.
+func waitForShutdown(renderer *qr.Renderer, duration time.Duration, cleanup func(*qr.Renderer)) error {
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	// A nil channel blocks forever, so no timer means no timeout
+	var timeout <-chan time.Time
+	if duration > 0 {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var expired <-chan time.Time
+	if maxAge > 0 {
+		timer := time.NewTimer(maxAge)
+		defer timer.Stop()
+		expired = timer.C
+		renderer.PrintInfo(fmt.Sprintf("Max age %s: closing at %s at the latest", maxAge, time.Now().Add(maxAge).Format("15:04")))
+	}
 
-	select {
-	case <-sigChan:
-		renderer.PrintInfo("\nShutting down gracefully...")
-		cleanupTunnel(renderer)
-	case <-ctx.Done():
-		cleanupTunnel(renderer)
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reloadConfig(renderer)
+				continue
+			}
+			renderer.PrintInfo("\nShutting down gracefully...")
+		case <-timeout:
+			renderer.PrintInfo("\nDuration expired, shutting down...")
+		case <-expired:
+			renderer.PrintWarning(fmt.Sprintf("Max age of %s reached, shutting down...", maxAge))
+		case err := <-shutdownRequests:
+			renderer.PrintInfo("Shutting down...")
+			cleanup(renderer)
+			return err
+		}
+		break
 	}
+
+	cleanup(renderer)
+	return nil
+}
+
+// reloadConfig re-reads the config file and applies the settings that can
+// change without disrupting the running tunnel or server.
+func reloadConfig(renderer *qr.Renderer) {
+	newCfg, err := loadConfig()
+	if err != nil {
+		renderer.PrintError("Failed to reload config: " + err.Error())
+		return
+	}
+
+	if !quietExplicit {
+		renderer.SetQuiet(newCfg.QuietMode)
+	}
+	renderer.SetMessages(qr.Messages(newCfg.Messages))
+
+	// The active tunnel keeps its provider until it reconnects
+	if activeTunnel != nil {
+		if newCfg.DefaultProvider != cfg.DefaultProvider ||
+			!reflect.DeepEqual(newCfg.Providers, cfg.Providers) ||
+			!reflect.DeepEqual(newCfg.CustomProviders, cfg.CustomProviders) ||
+			!reflect.DeepEqual(newCfg.SharedProviders, cfg.SharedProviders) {
+			renderer.PrintInfo("Provider settings changed; will apply on reconnect")
+		}
+	}
+
+	cfg = newCfg
+	renderer.PrintSuccess("Configuration reloaded")
 }
 
 func cleanupTunnel(renderer *qr.Renderer) {
+	defer closeTunnelLog()
+	defer printSessionSummary(newSessionSummary(), renderer)
+
+	removeShownImage()
+	stopShortLink(renderer)
+	stopAuthProxy(renderer)
 	if activeTunnel != nil {
 		if err := activeTunnel.Close(); err != nil {
 			renderer.PrintError("Error during cleanup: " + err.Error())
@@ -449,6 +1092,7 @@ func cleanupTunnel(renderer *qr.Renderer) {
 			renderer.PrintSuccess("Tunnel closed. Goodbye!")
 		}
 	}
+	finishHooks(renderer)
 }
 
 // runServe handles the serve command
@@ -459,30 +1103,107 @@ func runServe(cmd *cobra.Command, args []string) error {
 		dir = args[0]
 	}
 
-	// Apply config defaults if flags not explicitly set
-	if !cmd.Flags().Changed("quiet") && cfg.QuietMode {
-		quietFlag = true
+	if err := validateExportFlags(); err != nil {
+		return err
 	}
-	if !cmd.Flags().Changed("copy") && cfg.CopyToClipboard {
-		copyFlag = true
+	if err := resolveMaxAge(cmd); err != nil {
+		return err
+	}
+	if cacheMB < 0 {
+		return fmt.Errorf("--cache-mb must not be negative")
 	}
+	if tokenLink && passwordFlag == "" && !totpFlag && totpSecret == "" {
+		return fmt.Errorf("--token-link requires --password or --totp")
+	}
+	if tokenLink && tokenTTL <= 0 {
+		return fmt.Errorf("--token-ttl must be positive")
+	}
+	ports, err := parsePortRange(portRange)
+	if err != nil {
+		return err
+	}
+	mounts, err := parseMounts(mountFlags)
+	if err != nil {
+		return err
+	}
+	listenPort := servePort
+	if portRange != "" {
+		listenPort = 0
+	}
+	if err := validateHooks(cmd.Flags().Changed); err != nil {
+		return err
+	}
+	if err := validateLogFlags(cmd.Flags().Changed); err != nil {
+		return err
+	}
+	if err := validateAccessLogFlags(); err != nil {
+		return err
+	}
+
+	applyConfigDefaults(cmd)
 
 	// Create renderer
-	renderer := qr.NewRenderer(quietFlag)
+	renderer := newRenderer()
+	offerConfigInit(renderer)
+	if cfg.ProjectConfigWarning != "" {
+		renderer.PrintWarning(cfg.ProjectConfigWarning)
+	}
+
+	secret, err := resolveTOTPSecret()
+	if err != nil {
+		renderer.PrintError(err.Error())
+		return err
+	}
+
+	accessLog, err := openAccessLog()
+	if err != nil {
+		renderer.PrintError(err.Error())
+		return err
+	}
 
 	// Create and start HTTP server
 	srv, err := server.New(server.Config{
-		Port:          servePort,
-		Directory:     dir,
-		SPAMode:       spaMode,
-		ShowListing:   showListing,
-		BasicAuthPass: passwordFlag,
+		Port:            listenPort,
+		PortRange:       ports,
+		Directory:       dir,
+		Mounts:          mounts,
+		SPAMode:         spaMode,
+		ShowListing:     showListing,
+		RenderMarkdown:  markdownFlag,
+		IndexFiles:      indexFlag,
+		BasicAuthPass:   passwordFlag,
+		ListingTemplate: templateFlag,
+		TLSCertFile:     tlsCert,
+		TLSKeyFile:      tlsKey,
+		H2C:             h2cFlag,
+		Content:         stdinContent,
+		Splash:          splashFlag,
+		KeepAlive:       keepAliveFlag,
+
+		EnableUpload:       uploadFlag,
+		RejectContentTypes: rejectTypes,
+		TOTPSecret:         secret,
+		Exclude:            excludeFlag,
+		RespectGitignore:   gitignoreFlag,
+		CacheBytes:         int64(cacheMB) << 20,
+		DownloadCounts:     savedDownloadCounts(renderer),
+		WatchRoot:          rootWatchInterval,
+		AccessLog:          accessLog,
+		RequestIDHeader:    requestIDHeader,
 	})
 	if err != nil {
+		closeAccessLog()
 		renderer.PrintError("Failed to create server: " + err.Error())
 		return err
 	}
 
+	// Checked before anything is served, against the directories the
+	// server resolved
+	if err := confirmPublicExposure(srv, renderer); err != nil {
+		srv.Stop()
+		return err
+	}
+
 	if err := srv.Start(); err != nil {
 		renderer.PrintError("Failed to start server: " + err.Error())
 		return err
@@ -490,11 +1211,12 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	activeServer = srv
 	port := srv.Port()
+	go watchServerRoot(srv, renderer)
 
 	if passwordFlag != "" {
-		renderer.PrintSuccess(fmt.Sprintf("Serving %s on port %d (password protected)", srv.Directory(), port))
+		renderer.PrintSuccess(fmt.Sprintf("Serving %s on port %d (password protected)", servedName(srv), port))
 	} else {
-		renderer.PrintSuccess(fmt.Sprintf("Serving %s on port %d", srv.Directory(), port))
+		renderer.PrintSuccess(fmt.Sprintf("Serving %s on port %d", servedName(srv), port))
 	}
 
 	var url string
@@ -510,22 +1232,23 @@ func runServe(cmd *cobra.Command, args []string) error {
 		isPublic = true
 	} else {
 		// Generate local URL
-		url, err = network.GenerateLocalURL(port)
+		url, err = localURL(srv.Scheme(), port)
 		if err != nil {
-			renderer.PrintError("Failed to determine local IP address")
+			renderer.PrintError("Failed to generate local URL: " + err.Error())
 			srv.Stop()
 			return err
 		}
 		isPublic = false
 	}
 
+	if err := startShortLink(url, renderer); err != nil {
+		cleanupServeResources(renderer)
+		return err
+	}
+
 	// Copy to clipboard if requested
 	if copyFlag {
-		if err := clipboard.WriteAll(url); err != nil {
-			renderer.PrintError("Failed to copy URL to clipboard: " + err.Error())
-		} else {
-			renderer.PrintSuccess("URL copied to clipboard!")
-		}
+		copyAndReport(renderer, url, "URL")
 	}
 
 	// Open in browser if requested
@@ -537,86 +1260,81 @@ func runServe(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	qrURL, err := qrTokenURL(srv, url, renderer)
+	if err != nil {
+		cleanupServeResources(renderer)
+		return err
+	}
+
+	prepareQR(qrURL, renderer)
+
 	// Render QR code
-	if err := renderer.RenderOutput(url, isPublic); err != nil {
-		renderer.PrintError("Failed to generate QR code")
+	if renderTerminalQR() {
+		if err := renderer.RenderOutput(qrURL, isPublic); err != nil {
+			renderer.PrintError("Failed to generate QR code")
+			return err
+		}
+	}
+	if onelineOutput {
+		printStatusLine(url)
+	}
+
+	if err := exportQR(qrURL, renderer); err != nil {
 		return err
 	}
 
+	if secret != "" {
+		startCodeDisplay(secret, renderer)
+	}
+
 	// Wait for shutdown
 	if durationFlag > 0 {
 		renderer.PrintInfo(fmt.Sprintf("Server will auto-close in %s...", durationFlag))
-		waitForServeShutdownWithTimeout(renderer, durationFlag)
+		err = waitForShutdown(renderer, durationFlag, cleanupServeResources)
 	} else {
 		renderer.PrintInfo("Press Ctrl+C to stop the server and exit...")
-		waitForServeShutdown(renderer)
+		err = waitForShutdown(renderer, 0, cleanupServeResources)
 	}
-
-	return nil
-}
-
-func waitForServeShutdown(renderer *qr.Renderer) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	<-sigChan
-	renderer.PrintInfo("\nShutting down gracefully...")
-
-	cleanupServeResources(renderer)
-}
-
-func waitForServeShutdownWithTimeout(renderer *qr.Renderer, duration time.Duration) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	timer := time.NewTimer(duration)
-	defer timer.Stop()
-
-	select {
-	case <-sigChan:
-		renderer.PrintInfo("\nShutting down gracefully...")
-	case <-timer.C:
-		renderer.PrintInfo("\nDuration expired, shutting down...")
+	if err != nil {
+		// Shutdown requests are reported by whoever made them
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
 	}
-
-	cleanupServeResources(renderer)
+	return err
 }
 
 func cleanupServeResources(renderer *qr.Renderer) {
+	defer closeTunnelLog()
+	defer closeAccessLog()
+	defer printSessionSummary(newSessionSummary(), renderer)
+
+	stopAccessCodes()
+	stopShortLink(renderer)
+	removeShownImage()
+
 	// Cleanup tunnel first
 	if activeTunnel != nil {
 		if err := activeTunnel.Close(); err != nil {
 			renderer.PrintError("Error closing tunnel: " + err.Error())
 		}
 	}
+	finishHooks(renderer)
 
 	// Then stop server
 	if activeServer != nil {
 		if err := activeServer.Stop(); err != nil {
 			renderer.PrintError("Error stopping server: " + err.Error())
 		}
+		saveDownloadCounts(renderer)
+		if cacheMB > 0 {
+			hits, misses := activeServer.CacheStats()
+			renderer.PrintInfo(fmt.Sprintf("File cache: %d hits, %d misses", hits, misses))
+		}
 	}
 
 	renderer.PrintSuccess("Server stopped. Goodbye!")
 }
 
-func waitForShutdownWithTimeout(renderer *qr.Renderer, duration time.Duration) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	timer := time.NewTimer(duration)
-	defer timer.Stop()
-
-	select {
-	case <-sigChan:
-		renderer.PrintInfo("\nShutting down gracefully...")
-	case <-timer.C:
-		renderer.PrintInfo("\nDuration expired, shutting down...")
-	}
-
-	cleanupTunnel(renderer)
-}
-
 // openURL opens the specified URL in the default browser
 func openURL(url string) error {
 	var cmd string
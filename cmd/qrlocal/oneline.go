@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+// onelineOutput replaces the QR code with a single status line, for CI
+// logs.
+var onelineOutput bool
+
+// printStatusLine prints the --oneline status of the share on stdout:
+//
+//	qrlocal: url=https://abc123.lhr.life provider=localhost.run status=up
+//
+// Shares without a tunnel report provider=local.
+func printStatusLine(url string) {
+	provider := "local"
+	if activeTunnel != nil {
+		provider = activeTunnel.ProviderName()
+	}
+	fmt.Printf("qrlocal: url=%s provider=%s status=up\n", url, provider)
+}
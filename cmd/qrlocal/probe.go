@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// probeNoSave keeps "providers probe" from writing to the config file
+var probeNoSave bool
+
+// probeCmd finds the remote forward spec that works with a provider
+var probeCmd = &cobra.Command{
+	Use:   "probe <provider>",
+	Short: "Find the remote forward spec a provider needs",
+	Long: `Open a tunnel through the provider with each of the usual ssh -R forward
+specs (port 80, a port the provider picks, and 443) and check which one
+gives a public URL that reaches this machine. The first one that works is
+saved as the provider's remote_forward in the config file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		provider, err := tunnel.GetProvider(args[0], cfg)
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Probing %s (this takes up to a minute per spec)...\n\n", provider.Name)
+		results, err := tunnel.ProbeRemoteForward(ctx, provider, func(spec string) {
+			fmt.Printf("  trying %s\n", spec)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		working := ""
+		for _, r := range results {
+			line := fmt.Sprintf("%s %s", statusMark(r.Err == nil), r.RemoteForward)
+			if r.Err == nil {
+				line += ": " + r.URL
+				if working == "" {
+					working = r.RemoteForward
+				}
+			} else {
+				line += ": " + r.Err.Error()
+			}
+			fmt.Println(line)
+		}
+		fmt.Println()
+
+		if working == "" {
+			return fmt.Errorf("no remote forward spec worked with %s", provider.Name)
+		}
+		return saveRemoteForward(provider.Name, working)
+	},
+}
+
+// saveRemoteForward records spec as the remote_forward of the named
+// provider in the config file, or says how to when it can't.
+func saveRemoteForward(name, spec string) error {
+	section := "custom_providers"
+	for _, info := range cfg.ProviderInfos() {
+		if info.Name != name {
+			continue
+		}
+		if info.Builtin {
+			section = "providers"
+		} else if info.Shared {
+			fmt.Printf("%s comes from %s; ask its maintainer to set remote_forward: '%s'\n", name, cfg.ProvidersURL, spec)
+			return nil
+		}
+	}
+
+	key := config.KeyPath(section, name, "remote_forward")
+	if probeNoSave {
+		fmt.Printf("Use it with --set '%s=%s', or add remote_forward: '%s' to the provider's config\n", key, spec, spec)
+		return nil
+	}
+	if err := config.SaveSetting(configPath, key, spec); err != nil {
+		return fmt.Errorf("failed to save remote_forward: %w", err)
+	}
+	path := configPath
+	if path == "" {
+		path, _ = config.DefaultConfigPath()
+	}
+	fmt.Printf("Saved %s = '%s' in %s\n", key, spec, path)
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hash/qrlocal/pkg/config"
+)
+
+func TestSaveRemoteForwardDottedName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	t.Cleanup(func() { configPath = "" })
+	cfg = config.DefaultConfig()
+	cfg.CustomProviders = map[string]config.ProviderConfig{
+		"my.tunnel": {Host: "tunnel.example", Port: 22, URLRegex: `https://\S+`},
+	}
+
+	for _, name := range []string{"localhost.run", "my.tunnel"} {
+		if err := saveRemoteForward(name, "0:localhost:{{.Port}}"); err != nil {
+			t.Fatalf("saveRemoteForward(%s): %v", name, err)
+		}
+	}
+
+	saved, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := saved.Providers["localhost.run"].RemoteForward; got != "0:localhost:{{.Port}}" {
+		t.Errorf("localhost.run remote_forward = %q", got)
+	}
+	if got := saved.CustomProviders["my.tunnel"].RemoteForward; got != "0:localhost:{{.Port}}" {
+		t.Errorf("my.tunnel remote_forward = %q", got)
+	}
+	if _, ok := saved.Providers["localhost"]; ok {
+		t.Error("saved under a provider named localhost")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+)
+
+var (
+	// activeProxy guards the shared port with a password, or adds the
+	// --inject-header headers, if any.
+	activeProxy *server.AuthProxy
+
+	// injectHeaders are the --inject-header values, as "Name: value"
+	injectHeaders []string
+)
+
+// parseInjectHeaders parses --inject-header values of the form
+// "Name: value", as curl's -H takes them.
+func parseInjectHeaders(values []string) (http.Header, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	headers := make(http.Header)
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header: %s (must be like \"X-Forwarded-Proto: https\")", value)
+		}
+		headers.Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(v))
+	}
+	return headers, nil
+}
+
+// proxyListenHost is where the proxy listens when only the tunnel
+// connects to it.
+const proxyListenHost = "127.0.0.1"
+
+// startAuthProxy starts a reverse proxy to host:port, which speaks
+// scheme, that asks for --password and adds the --inject-header headers,
+// and returns the port to share instead. With --public the proxy only
+// listens on loopback for the tunnel, so nobody on the network can reach
+// it without going through the tunnel; otherwise it is what the network
+// is given.
+func startAuthProxy(host, scheme string, port int, renderer *qr.Renderer) (int, error) {
+	headers, err := parseInjectHeaders(injectHeaders)
+	if err != nil {
+		return 0, err
+	}
+	p, err := server.NewAuthProxy(server.ProxyConfig{
+		TargetHost:    host,
+		TargetPort:    port,
+		TargetScheme:  scheme,
+		Password:      passwordFlag,
+		InjectHeaders: headers,
+		ListenHost:    proxyHost(),
+	})
+	if err != nil {
+		renderer.PrintError("Failed to start proxy: " + err.Error())
+		return 0, err
+	}
+	p.Start()
+	activeProxy = p
+
+	if passwordFlag != "" {
+		renderer.PrintSuccess(fmt.Sprintf("Port %d is password protected (via proxy port %d)", port, p.Port()))
+	} else {
+		renderer.PrintSuccess(fmt.Sprintf("Port %d is shared via proxy port %d, which adds request headers", port, p.Port()))
+	}
+	return p.Port(), nil
+}
+
+// stopAuthProxy stops the proxy if one is running.
+func stopAuthProxy(renderer *qr.Renderer) {
+	if activeProxy == nil {
+		return
+	}
+	if err := activeProxy.Stop(); err != nil {
+		renderer.PrintError("Error stopping proxy: " + err.Error())
+	}
+	activeProxy = nil
+}
+
+// proxyHost returns the address the proxy listens on: loopback when it
+// only fronts the tunnel, and all interfaces for local sharing.
+func proxyHost() string {
+	if publicFlag {
+		return proxyListenHost
+	}
+	return ""
+}
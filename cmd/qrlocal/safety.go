@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+	"github.com/mattn/go-isatty"
+)
+
+// exposeAnyway skips the check for sensitive directories before sharing
+// them publicly.
+var exposeAnyway bool
+
+// confirmPublicExposure guards against publishing a home directory, a
+// filesystem root or a folder with credentials by mistake. It asks for
+// confirmation on an interactive terminal and refuses otherwise, unless
+// --i-know-what-im-doing was passed. It must run before srv is started,
+// so nothing is served while it waits for an answer.
+func confirmPublicExposure(srv *server.Server, renderer *qr.Renderer) error {
+	if !publicFlag || exposeAnyway {
+		return nil
+	}
+	risks := srv.ExposureRisks()
+	if len(risks) == 0 {
+		return nil
+	}
+
+	renderer.PrintWarning(fmt.Sprintf("Sharing %s publicly may expose private files:", srv.Directory()))
+	for _, risk := range risks {
+		renderer.PrintWarning("  " + risk)
+	}
+
+	if !stdinIsTerminal() {
+		renderer.PrintInfo("Use --exclude to hide sensitive files, or pass --i-know-what-im-doing to share anyway.")
+		return fmt.Errorf("refusing to share %s publicly", srv.Directory())
+	}
+
+	fmt.Fprintf(os.Stderr, "Share %s publicly anyway? [y/N] ", srv.Directory())
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("not sharing %s", srv.Directory())
+}
+
+// stdinIsTerminal reports whether standard input is an interactive terminal.
+func stdinIsTerminal() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+// shortRandom is the --short value that picks a random word.
+const shortRandom = "random"
+
+// shortWord is the alias for the short link (empty = disabled).
+var shortWord string
+
+// activeRedirector serves the short link, if any.
+var activeRedirector *server.Redirector
+
+// addShortFlag registers the --short flag on cmd.
+func addShortFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&shortWord, "short", "", "Serve a short, typeable link http://<ip>/<word> that redirects to the URL")
+	cmd.Flags().Lookup("short").NoOptDefVal = shortRandom
+}
+
+// startShortLink starts a local redirect server mapping a short word to url.
+func startShortLink(url string, renderer *qr.Renderer) error {
+	if shortWord == "" {
+		return nil
+	}
+
+	word := shortWord
+	if word == shortRandom {
+		word = server.RandomAliasWord()
+	}
+
+	r, err := server.NewRedirector(0)
+	if err != nil {
+		renderer.PrintError("Failed to start short link: " + err.Error())
+		return err
+	}
+	if err := r.Add(word, url); err != nil {
+		r.Stop()
+		renderer.PrintError(err.Error())
+		return err
+	}
+
+	base, err := localURL("http", r.Port())
+	if err != nil {
+		r.Stop()
+		renderer.PrintError("Failed to generate short link: " + err.Error())
+		return err
+	}
+
+	r.Start()
+	activeRedirector = r
+
+	// Port 80 is implied, so leave it out of the link
+	renderer.PrintNotice("Short link: " + strings.TrimSuffix(base, ":80") + "/" + word)
+	return nil
+}
+
+// stopShortLink stops the redirect server if one is running.
+func stopShortLink(renderer *qr.Renderer) {
+	if activeRedirector == nil {
+		return
+	}
+	if err := activeRedirector.Stop(); err != nil {
+		renderer.PrintError("Error stopping short link: " + err.Error())
+	}
+	activeRedirector = nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/hash/qrlocal/pkg/qr"
+)
+
+// showImage opens the QR code as an image in the default viewer.
+var showImage bool
+
+// shownImage is the temporary PNG opened by --show-image, removed on exit.
+var shownImage string
+
+// canShowImage reports whether an image viewer can appear on this
+// screen. Over SSH, or on Linux without X11 or Wayland, it can't.
+func canShowImage() bool {
+	if os.Getenv("SSH_CONNECTION") != "" && os.Getenv("DISPLAY") == "" {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	}
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// openQRImage writes the QR code for url to a temporary PNG and opens it
+// with the same opener as --open.
+func openQRImage(url string, renderer *qr.Renderer) error {
+	f, err := os.CreateTemp("", "qrlocal-*.png")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	f.Close()
+
+	if err := qr.WritePNG(url, path, pngOptions(url)); err != nil {
+		os.Remove(path)
+		return err
+	}
+	if err := openURL(path); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	shownImage = path
+	renderer.PrintSuccess("Opened the QR code for " + url + " in the image viewer")
+	return nil
+}
+
+// removeShownImage deletes the temporary PNG opened by --show-image.
+func removeShownImage() {
+	if shownImage == "" {
+		return
+	}
+	os.Remove(shownImage)
+	shownImage = ""
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hash/qrlocal/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveStdin   bool            // Serve standard input at the root instead of a directory
+	stdinLimitMB int             // Largest standard input --serve-stdin accepts
+	stdinContent *server.Content // Standard input read by --serve-stdin
+)
+
+// runQRLocalStdin handles "somecmd | qrlocal [port] --serve-stdin": standard
+// input is read to the end, then served like --serve serves a directory.
+func runQRLocalStdin(cmd *cobra.Command, args []string) error {
+	if stdinLimitMB <= 0 {
+		return fmt.Errorf("--stdin-limit-mb must be positive")
+	}
+	if stdinIsTerminal() {
+		return fmt.Errorf("--serve-stdin needs input piped to it, e.g. 'make 2>&1 | qrlocal --serve-stdin'")
+	}
+
+	content, err := server.ReadContent(os.Stdin, int64(stdinLimitMB)<<20)
+	if errors.Is(err, server.ErrContentTooLarge) {
+		return fmt.Errorf("standard input is larger than --stdin-limit-mb (%d MB)", stdinLimitMB)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read standard input: %w", err)
+	}
+	defer content.Close()
+
+	stdinContent = content
+	return runQRLocalServe(cmd, args)
+}
+
+// servedName describes what srv serves, for status messages.
+func servedName(srv *server.Server) string {
+	if stdinContent != nil {
+		return fmt.Sprintf("standard input (%s, %s)", server.FormatFileSize(stdinContent.Size()), stdinContent.ContentType())
+	}
+	return srv.Directory()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+)
+
+// summaryJSON prints the session summary as JSON on stdout.
+var summaryJSON bool
+
+// sessionSummary records a public tunnel session when it ends.
+type sessionSummary struct {
+	Provider        string    `json:"provider"`
+	URL             string    `json:"url"`
+	ConnectedAt     time.Time `json:"connected_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+
+	// Requests and the byte counts are only known when the traffic
+	// passes through qrlocal's own file server or password proxy; a raw
+	// SSH forward to another service is opaque.
+	Requests *uint64 `json:"requests,omitempty"`
+	BytesIn  *uint64 `json:"bytes_in,omitempty"`
+	BytesOut *uint64 `json:"bytes_out,omitempty"`
+}
+
+// newSessionSummary captures the active tunnel's session, or returns nil
+// if no tunnel was established. Call it before the server or proxy stop.
+func newSessionSummary() *sessionSummary {
+	if activeTunnel == nil {
+		return nil
+	}
+	s := &sessionSummary{
+		Provider:    activeTunnel.ProviderName(),
+		URL:         activeTunnel.PublicURL(),
+		ConnectedAt: activeTunnel.ConnectedAt(),
+	}
+	s.DurationSeconds = time.Since(s.ConnectedAt).Round(time.Second).Seconds()
+
+	var requests, in, out uint64
+	switch {
+	case activeServer != nil:
+		requests = activeServer.Requests()
+		in, out = activeServer.BytesTransferred()
+	case activeProxy != nil:
+		requests = activeProxy.Requests()
+		in, out = activeProxy.BytesTransferred()
+	default:
+		return s
+	}
+	s.Requests, s.BytesIn, s.BytesOut = &requests, &in, &out
+	return s
+}
+
+// logSessionSummary records the summary in the --log-file, if open.
+func logSessionSummary(s *sessionSummary) {
+	logTunnelEvent("tunnel closed after %s", time.Duration(s.DurationSeconds)*time.Second)
+	if s.Requests != nil {
+		logTunnelEvent("stats: %d requests in total, %s in, %s out", *s.Requests,
+			server.FormatFileSize(int64(*s.BytesIn)), server.FormatFileSize(int64(*s.BytesOut)))
+	}
+}
+
+// printSessionSummary prints the summary, as JSON on stdout with --json.
+// The text summary is informational and hidden by --quiet and --no-banner.
+func printSessionSummary(s *sessionSummary, renderer *qr.Renderer) {
+	if s == nil {
+		return
+	}
+	logSessionSummary(s)
+
+	if summaryJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(s); err != nil {
+			renderer.PrintError("Failed to write session summary: " + err.Error())
+		}
+		return
+	}
+
+	renderer.PrintInfo("Session summary:")
+	renderer.PrintInfo("  Provider: " + s.Provider)
+	renderer.PrintInfo("  URL:      " + s.URL)
+	renderer.PrintInfo(fmt.Sprintf("  Up for:   %s", time.Duration(s.DurationSeconds)*time.Second))
+	if s.Requests != nil {
+		renderer.PrintInfo(fmt.Sprintf("  Requests: %d", *s.Requests))
+	}
+	if s.BytesIn != nil {
+		renderer.PrintInfo(fmt.Sprintf("  Traffic:  %s in, %s out",
+			server.FormatFileSize(int64(*s.BytesIn)), server.FormatFileSize(int64(*s.BytesOut))))
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// terminalSize is not supported on this platform; callers fall back to
+// $COLUMNS/$LINES or a default size.
+func terminalSize(f *os.File) (width, height int) {
+	return 0, 0
+}
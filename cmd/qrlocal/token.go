@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/server"
+)
+
+// defaultTokenTTL is how long a one-time QR link stays valid.
+const defaultTokenTTL = 5 * time.Minute
+
+var (
+	tokenLink bool          // Put a one-time login token in the QR code
+	tokenTTL  time.Duration // How long the token is valid
+)
+
+// qrTokenURL returns shareURL with a one-time token that lets the first
+// visitor past the password or access code, for rendering as the QR code.
+// Without --token-link it returns shareURL unchanged.
+func qrTokenURL(srv *server.Server, shareURL string, renderer *qr.Renderer) (string, error) {
+	if !tokenLink {
+		return shareURL, nil
+	}
+
+	token, err := srv.IssueToken(tokenTTL)
+	if err != nil {
+		renderer.PrintError("Failed to create a one-time link: " + err.Error())
+		return "", err
+	}
+
+	u, err := url.Parse(shareURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	renderer.PrintInfo(fmt.Sprintf("The QR code logs in once and expires in %s; others need the password or code at %s", tokenTTL, shareURL))
+	return u.String(), nil
+}
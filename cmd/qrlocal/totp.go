@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/qr"
+	"github.com/hash/qrlocal/pkg/totp"
+)
+
+// TOTP flags
+var (
+	totpFlag   bool   // Require a time-based access code
+	totpSecret string // Base32 secret for the access code (generated if empty)
+
+	// stopCodeDisplay stops printing access codes; nil when not running
+	stopCodeDisplay chan struct{}
+)
+
+// resolveTOTPSecret returns the access code secret for the serve command,
+// or "" when access codes are disabled.
+func resolveTOTPSecret() (string, error) {
+	if totpSecret != "" {
+		return totpSecret, totp.ValidateSecret(totpSecret)
+	}
+	if !totpFlag {
+		return "", nil
+	}
+	return totp.GenerateSecret()
+}
+
+// startCodeDisplay prints the current access code, and each new one as
+// the previous code expires, until stopAccessCodes is called.
+func startCodeDisplay(secret string, renderer *qr.Renderer) {
+	stop := make(chan struct{})
+	stopCodeDisplay = stop
+
+	printCode := func() {
+		now := time.Now()
+		code, err := totp.Code(secret, now)
+		if err != nil {
+			renderer.PrintError("Failed to generate access code: " + err.Error())
+			return
+		}
+		message := fmt.Sprintf("Access code: %s (valid for %ds)", code, int(totp.Remaining(now).Seconds()))
+		// Visitors can't get in without the code, so show it even in quiet mode
+		if quietFlag {
+			fmt.Fprintln(os.Stderr, message)
+			return
+		}
+		renderer.PrintNotice(message)
+	}
+
+	printCode()
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(totp.Remaining(time.Now())):
+				printCode()
+			}
+		}
+	}()
+}
+
+// stopAccessCodes stops the access code display, if running.
+func stopAccessCodes() {
+	if stopCodeDisplay != nil {
+		close(stopCodeDisplay)
+		stopCodeDisplay = nil
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/network"
+	"github.com/hash/qrlocal/pkg/tunnel"
+	"github.com/spf13/cobra"
+)
+
+// tunnelCommandOut is the file "tunnel-command" writes the script to
+var tunnelCommandOut string
+
+// knownHostsVar is the shell variable the script keeps the path of a
+// pinned host key's known_hosts file in.
+const knownHostsVar = "$known_hosts"
+
+// tunnelCommandCmd prints the ssh command qrlocal would run for a tunnel
+var tunnelCommandCmd = &cobra.Command{
+	Use:   "tunnel-command <port>",
+	Short: "Print the ssh command for a tunnel as a shell script",
+	Long: `Print the ssh command line qrlocal would run to open a public tunnel to the
+port, quoted for the shell, without running it. The output is a complete
+/bin/sh script for running the tunnel outside qrlocal or from your own
+tooling; --out writes it to an executable file instead.`,
+	Example: `  qrlocal tunnel-command 3000
+  qrlocal tunnel-command 3000 --provider pinggy --out tunnel.sh`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTunnelCommand,
+}
+
+func init() {
+	tunnelCommandCmd.Flags().StringVar(&providerFlag, "provider", "", "Tunnel provider (default from config)")
+	tunnelCommandCmd.Flags().StringVar(&targetFlag, "target", tunnel.DefaultLocalHost, "Host on your network the tunnel forwards to")
+	tunnelCommandCmd.Flags().BoolVar(&tcpFlag, "tcp", false, "Forward raw TCP instead of HTTP (requires a provider with TCP support)")
+	tunnelCommandCmd.Flags().StringArrayVar(&sshOpts, "ssh-opt", nil, "Extra ssh option for the tunnel, passed verbatim (repeatable), e.g. \"-J bastion\" or ServerAliveInterval=30")
+	tunnelCommandCmd.Flags().StringVarP(&tunnelCommandOut, "out", "o", "", "Write the script to this file and make it executable")
+	rootCmd.AddCommand(tunnelCommandCmd)
+}
+
+func runTunnelCommand(cmd *cobra.Command, args []string) error {
+	port, err := strconv.Atoi(args[0])
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port number: %s (must be 1-65535)", args[0])
+	}
+	if targetFlag, err = network.NormalizeHost(targetFlag); err != nil {
+		return fmt.Errorf("invalid --target: %w", err)
+	}
+	if err := network.ValidateHost(targetFlag); err != nil {
+		return fmt.Errorf("invalid --target: %w", err)
+	}
+
+	name := providerFlag
+	if name == "" {
+		name = cfg.DefaultProvider
+	}
+	provider, err := tunnel.GetProvider(name, cfg)
+	if errors.Is(err, tunnel.ErrUnknownProvider) {
+		if suggestion, ok := tunnel.SuggestProvider(name, cfg); ok {
+			return fmt.Errorf("%w '%s'; did you mean '%s'?", tunnel.ErrUnknownProvider, name, suggestion)
+		}
+		return fmt.Errorf("%w (see 'qrlocal providers')", err)
+	} else if err != nil {
+		return err
+	}
+	for _, opt := range sshOpts {
+		extra, err := tunnel.ParseSSHOption(opt)
+		if err != nil {
+			return err
+		}
+		provider.ExtraArgs = append(provider.ExtraArgs, extra...)
+	}
+
+	command, err := tunnel.SSHCommand(tunnel.Config{
+		LocalHost: targetFlag,
+		LocalPort: port,
+		Provider:  provider,
+		TCP:       tcpFlag,
+	}, knownHostsVar)
+	if err != nil {
+		return err
+	}
+
+	script := tunnelScript(provider.Name, net.JoinHostPort(targetFlag, strconv.Itoa(port)), command)
+	if tunnelCommandOut == "" {
+		fmt.Print(script)
+		return nil
+	}
+	if err := os.WriteFile(tunnelCommandOut, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	fmt.Printf("Wrote %s\n", tunnelCommandOut)
+	return nil
+}
+
+// tunnelScript returns a /bin/sh script that runs command, noting how
+// qrlocal would read the public URL from its output.
+func tunnelScript(provider, target string, command tunnel.Command) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Public tunnel to %s via %s, as opened by qrlocal.\n", target, provider)
+	fmt.Fprintf(&b, "# %s.\n", command.URLNote)
+
+	if command.KnownHosts != "" {
+		// The pinned host key goes in a file of its own, as ssh can't
+		// take it on the command line
+		b.WriteString("known_hosts=$(mktemp) || exit 1\n")
+		b.WriteString("trap 'rm -f \"$known_hosts\"' EXIT\n")
+		fmt.Fprintf(&b, "printf '%%s\\n' %s >\"$known_hosts\"\n", shellQuote(strings.TrimSpace(command.KnownHosts)))
+	}
+
+	quoted := make([]string, len(command.Args))
+	for i, arg := range command.Args {
+		if strings.Contains(arg, knownHostsVar) {
+			// Left for the shell to expand
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = shellQuote(arg)
+	}
+	b.WriteString(strings.Join(quoted, " ") + "\n")
+	return b.String()
+}
+
+// shellSafe matches arguments that need no quoting in a POSIX shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
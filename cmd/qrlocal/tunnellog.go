@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logFilePath string // Record the tunnel's output and activity in this file
+	logTruncate bool   // Start the log file empty instead of appending
+	logRotateMB int    // Rotate the log file when it grows past this size
+
+	// tunnelLog is the open --log-file, or nil
+	tunnelLog *logFile
+)
+
+// logStatsInterval is how often traffic statistics are written to the
+// log file, when they changed.
+const logStatsInterval = time.Minute
+
+// addLogFlags registers the tunnel log flags on cmd.
+func addLogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logFilePath, "log-file", "", "Append the provider's output and tunnel activity, with timestamps, to this file (requires --public)")
+	cmd.Flags().BoolVar(&logTruncate, "log-truncate", false, "Empty the --log-file when starting instead of appending to it")
+	cmd.Flags().IntVar(&logRotateMB, "log-rotate-mb", 0, "Move the --log-file to <file>.1 when it grows past this many MB (0 never rotates)")
+}
+
+// validateLogFlags checks the tunnel log flags before anything starts.
+func validateLogFlags(changed func(string) bool) error {
+	if logFilePath == "" {
+		if changed("log-truncate") || changed("log-rotate-mb") {
+			return fmt.Errorf("--log-truncate and --log-rotate-mb require --log-file")
+		}
+		return nil
+	}
+	if !publicFlag {
+		return fmt.Errorf("--log-file requires --public")
+	}
+	if logRotateMB < 0 {
+		return fmt.Errorf("--log-rotate-mb must not be negative")
+	}
+	return nil
+}
+
+// openTunnelLog opens the --log-file, if any, and starts writing traffic
+// statistics to it.
+func openTunnelLog() error {
+	if logFilePath == "" {
+		return nil
+	}
+	f, err := openLogFile(logFilePath, logTruncate, int64(logRotateMB)<<20)
+	if err != nil {
+		return err
+	}
+	tunnelLog = f
+	go tunnelLog.writeStats()
+	return nil
+}
+
+// closeTunnelLog closes the --log-file, if open.
+func closeTunnelLog() {
+	if tunnelLog == nil {
+		return
+	}
+	tunnelLog.Close()
+	tunnelLog = nil
+}
+
+// logTunnelEvent writes a line to the --log-file, if open.
+func logTunnelEvent(format string, args ...any) {
+	if tunnelLog == nil {
+		return
+	}
+	fmt.Fprintf(tunnelLog, format+"\n", args...)
+}
+
+// logFile is a log file that prefixes every line with a timestamp and
+// optionally rotates when it grows too large. It is safe for concurrent
+// use.
+type logFile struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	size    int64
+	maxSize int64 // Zero never rotates
+	done    chan struct{}
+}
+
+// openLogFile opens path for appending, or empties it first if truncate
+// is set.
+func openLogFile(path string, truncate bool, maxSize int64) (*logFile, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return &logFile{path: path, f: f, size: info.Size(), maxSize: maxSize, done: make(chan struct{})}, nil
+}
+
+// Write writes p, one or more lines, each prefixed with the time.
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	stamp := time.Now().Format(time.RFC3339) + " "
+	var buf bytes.Buffer
+	for line := range bytes.Lines(p) {
+		buf.WriteString(stamp)
+		buf.Write(line)
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(buf.Len()) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := l.f.Write(buf.Bytes())
+	l.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// rotate moves the log to <path>.1, replacing an older one, and starts a
+// new, empty log.
+func (l *logFile) rotate() error {
+	l.f.Close()
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		l.f = nil
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	l.f, l.size = f, 0
+	return nil
+}
+
+// writeStats writes the request count and traffic of qrlocal's own server
+// or proxy every logStatsInterval, when they changed, until the log is
+// closed.
+func (l *logFile) writeStats() {
+	ticker := time.NewTicker(logStatsInterval)
+	defer ticker.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+
+		var requests, in, out uint64
+		switch {
+		case activeServer != nil:
+			requests = activeServer.Requests()
+			in, out = activeServer.BytesTransferred()
+		case activeProxy != nil:
+			requests = activeProxy.Requests()
+			in, out = activeProxy.BytesTransferred()
+		default:
+			// Raw forwards to another service can't be counted
+			return
+		}
+		if requests == last {
+			continue
+		}
+		fmt.Fprintf(l, "stats: %d requests (+%d), %s in, %s out\n", requests, requests-last,
+			server.FormatFileSize(int64(in)), server.FormatFileSize(int64(out)))
+		last = requests
+	}
+}
+
+// Close stops the statistics and closes the file.
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.done:
+		return nil
+	default:
+		close(l.done)
+	}
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
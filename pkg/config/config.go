@@ -2,10 +2,20 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hash/qrlocal/pkg/network"
 	"gopkg.in/yaml.v3"
 )
 
@@ -29,24 +39,140 @@ func DefaultConfigPath() (string, error) {
 
 // ProviderConfig defines a tunnel provider configuration.
 type ProviderConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	User     string `yaml:"user"`
-	URLRegex string `yaml:"url_regex"`
+	Host     string `yaml:"host" json:"host"`
+	Port     int    `yaml:"port" json:"port"`
+	User     string `yaml:"user" json:"user"`
+	URLRegex string `yaml:"url_regex" json:"url_regex"`
+
+	// Description is a note for the user, shown in provider listings
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	// ClientLabel identifies this client in the provider's logs or dashboard
+	ClientLabel string `yaml:"client_label,omitempty" json:"client_label,omitempty"`
+
+	// RemoteForward is the ssh -R spec template, e.g. "0:{{.Host}}:{{.Port}}".
+	// Defaults to "80:{{.Host}}:{{.Port}}".
+	RemoteForward string `yaml:"remote_forward,omitempty" json:"remote_forward,omitempty"`
+
+	// TCPURLRegex matches the forwarded address in raw TCP mode (--tcp).
+	// Providers without it don't support TCP tunnels.
+	TCPURLRegex string `yaml:"tcp_url_regex,omitempty" json:"tcp_url_regex,omitempty"`
+
+	// TCPRemoteForward is the ssh -R spec template used in TCP mode.
+	// Defaults to RemoteForward.
+	TCPRemoteForward string `yaml:"tcp_remote_forward,omitempty" json:"tcp_remote_forward,omitempty"`
+
+	// MaxConcurrent limits simultaneous tunnels to this provider; 0 means
+	// no limit.
+	MaxConcurrent int `yaml:"max_concurrent,omitempty" json:"max_concurrent,omitempty"`
+
+	// Capabilities lists optional features the provider supports: https,
+	// tcp, subdomain and token. tcp is implied by TCPURLRegex.
+	Capabilities []string `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	// ExtraArgs are ssh options passed verbatim, e.g. ["-J", "bastion"]
+	// or ["-o", "PubkeyAuthentication=no"].
+	ExtraArgs []string `yaml:"extra_args,omitempty" json:"extra_args,omitempty"`
+
+	// HostKey pins the provider's SSH host key, e.g. "ssh-ed25519
+	// AAAAC3Nz..." as printed by ssh-keyscan. KnownHosts names a
+	// known_hosts file to check the host against instead. Either turns on
+	// strict host key checking; by default any host key is accepted.
+	HostKey    string `yaml:"host_key,omitempty" json:"host_key,omitempty"`
+	KnownHosts string `yaml:"known_hosts,omitempty" json:"known_hosts,omitempty"`
+
+	// JSONOutput asks the provider for JSON events, as localhost.run
+	// prints with "--output json", and reads the URL from their address
+	// field. URLRegex is still used for other lines.
+	JSONOutput bool `yaml:"json_output,omitempty" json:"json_output,omitempty"`
+}
+
+// Messages overrides the text qrlocal prints around QR codes and
+// messages, for translation. Empty fields keep the English defaults.
+type Messages struct {
+	PublicTitle string `yaml:"public_title,omitempty" json:"public_title,omitempty"`
+	LocalTitle  string `yaml:"local_title,omitempty" json:"local_title,omitempty"`
+	ScanHint    string `yaml:"scan_hint,omitempty" json:"scan_hint,omitempty"`
+	Error       string `yaml:"error,omitempty" json:"error,omitempty"`
+	Warning     string `yaml:"warning,omitempty" json:"warning,omitempty"`
 }
 
 // Config represents the qrlocal configuration file structure.
 type Config struct {
 	// Default settings
-	DefaultProvider string `yaml:"default_provider"`
-	CopyToClipboard bool   `yaml:"copy_to_clipboard"`
-	QuietMode       bool   `yaml:"quiet_mode"`
+	DefaultProvider string `yaml:"default_provider" json:"default_provider"`
+	CopyToClipboard bool   `yaml:"copy_to_clipboard" json:"copy_to_clipboard"`
+	QuietMode       bool   `yaml:"quiet_mode" json:"quiet_mode"`
+
+	// FallbackProviders are tried in order when the default provider
+	// can't connect. Providers that failed repeatedly in the last
+	// HealthCooldown are tried last, including the default.
+	FallbackProviders []string `yaml:"fallback_providers,omitempty" json:"fallback_providers,omitempty"`
+
+	// NoBanner hides informational and success messages, such as the
+	// "Press Ctrl+C" prompt, while still printing warnings and errors
+	NoBanner bool `yaml:"no_banner,omitempty" json:"no_banner,omitempty"`
+
+	// PersistDownloads keeps the serve command's per-file download counts
+	// in the state file, so they add up across sessions
+	PersistDownloads bool `yaml:"persist_downloads,omitempty" json:"persist_downloads,omitempty"`
+
+	// MaxAge closes every tunnel and server after this long, e.g. "2h",
+	// so a forgotten share doesn't stay up. The --max-age flag overrides it.
+	MaxAge string `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+
+	// OnUp and OnDown are shell commands run when a public tunnel comes
+	// up and goes down, like the --on-up and --on-down flags
+	OnUp   string `yaml:"on_up,omitempty" json:"on_up,omitempty"`
+	OnDown string `yaml:"on_down,omitempty" json:"on_down,omitempty"`
+
+	// Host replaces the detected local IP in generated URLs, for
+	// port-forwarding setups with a known public IP or DNS name
+	Host string `yaml:"host,omitempty" json:"host,omitempty"`
+
+	// QR error correction level (low, medium, high, highest). URLs longer
+	// than QRLevelThreshold characters get lower levels, down to
+	// QRLevelFloor, to keep the terminal QR code small. 0 disables this.
+	QRLevel          string `yaml:"qr_level" json:"qr_level"`
+	QRLevelThreshold int    `yaml:"qr_level_threshold" json:"qr_level_threshold"`
+	QRLevelFloor     string `yaml:"qr_level_floor" json:"qr_level_floor"`
+
+	// Messages translates the titles and message prefixes
+	Messages Messages `yaml:"messages,omitempty" json:"messages,omitempty"`
 
 	// Built-in provider settings
-	Providers map[string]ProviderConfig `yaml:"providers"`
+	Providers map[string]ProviderConfig `yaml:"providers" json:"providers"`
 
 	// Custom providers defined by user
-	CustomProviders map[string]ProviderConfig `yaml:"custom_providers"`
+	CustomProviders map[string]ProviderConfig `yaml:"custom_providers" json:"custom_providers"`
+
+	// ProvidersURL points at a YAML or JSON list of provider definitions
+	// shared by a team, fetched at load time into SharedProviders.
+	// ProvidersTTL is how long a fetched copy is reused, e.g. "12h";
+	// it defaults to DefaultProvidersTTL.
+	ProvidersURL string `yaml:"providers_url,omitempty" json:"providers_url,omitempty"`
+	ProvidersTTL string `yaml:"providers_ttl,omitempty" json:"providers_ttl,omitempty"`
+
+	// SharedProviders are the providers fetched from ProvidersURL. Custom
+	// providers with the same name take precedence.
+	SharedProviders map[string]ProviderConfig `yaml:"-" json:"shared_providers,omitempty"`
+
+	// SharedProvidersWarning explains why ProvidersURL couldn't be
+	// fetched, and whether a cached copy is used instead
+	SharedProvidersWarning string `yaml:"-" json:"-"`
+
+	// ProjectConfigWarning lists the settings of the project config that
+	// were ignored because only the global config may set them
+	ProjectConfigWarning string `yaml:"-" json:"-"`
+
+	// Sources lists the config files that were loaded, in order
+	Sources []string `yaml:"-" json:"sources,omitempty"`
+
+	// EnvOverrides lists the environment variables that changed settings
+	EnvOverrides []string `yaml:"-" json:"env_overrides,omitempty"`
+
+	// SetOverrides lists the keys changed with Set, as by --set
+	SetOverrides []string `yaml:"-" json:"set_overrides,omitempty"`
 }
 
 // DefaultConfig returns the default configuration.
@@ -55,68 +181,334 @@ func DefaultConfig() *Config {
 		DefaultProvider: "localhost.run",
 		CopyToClipboard: false,
 		QuietMode:       false,
+
+		QRLevel:          "medium",
+		QRLevelThreshold: 100,
+		QRLevelFloor:     "low",
+
 		Providers: map[string]ProviderConfig{
 			"localhost.run": {
 				Host:     "localhost.run",
 				Port:     22,
 				User:     "nokey",
 				URLRegex: `https://[a-zA-Z0-9]+\.lhr\.life`,
+
+				JSONOutput:   true,
+				Capabilities: []string{"https"},
 			},
 			"pinggy": {
-				Host:     "a.pinggy.io",
-				Port:     443,
-				User:     "a",
-				URLRegex: `https://[a-zA-Z0-9-]+\.a\.free\.pinggy\.link`,
+				Host:          "a.pinggy.io",
+				Port:          443,
+				User:          "a",
+				URLRegex:      `https://[a-zA-Z0-9-]+\.a\.free\.pinggy\.link`,
+				RemoteForward: "0:{{.Host}}:{{.Port}}",
+
+				Capabilities: []string{"https", "token"},
 			},
 			"serveo": {
 				Host:     "serveo.net",
 				Port:     22,
 				User:     "serveo",
 				URLRegex: `Forwarding HTTP traffic from (https://[a-zA-Z0-9-]+\.(?:serveo\.net|serveousercontent\.com))`,
+
+				TCPURLRegex:      `Forwarding TCP connect from ([a-zA-Z0-9.-]+:\d+)`,
+				TCPRemoteForward: "0:{{.Host}}:{{.Port}}",
+
+				Capabilities: []string{"https", "tcp", "subdomain"},
 			},
 			"tunnelto": {
 				Host:     "tunnel.us.tunnel.to",
 				Port:     22,
 				User:     "tunnel",
 				URLRegex: `https://[a-zA-Z0-9-]+\.tunnel\.to`,
+
+				Capabilities: []string{"https", "subdomain", "token"},
 			},
 		},
 		CustomProviders: map[string]ProviderConfig{},
 	}
 }
 
+// ProjectConfigName is the file name of a per-project config overlay.
+const ProjectConfigName = ".qrlocal.yaml"
+
 // Load reads and parses the configuration file.
-// If the file doesn't exist, it returns the default configuration.
+// If the file doesn't exist, the default configuration is used.
+//
+// Settings are layered with the following precedence (highest first):
+// environment variables, a project config found by FindProjectConfig in
+// the working directory, the config file at path, and the defaults.
+// Provider maps are merged key-by-key rather than replaced. A project
+// config can't set hooks, extra_args or providers_url; see
+// applyProjectFile. When
+// providers_url is set, the shared providers are fetched last; a failed
+// fetch only sets SharedProvidersWarning.
 func Load(path string) (*Config, error) {
+	return LoadWithOverrides(path, nil)
+}
+
+// LoadWithOverrides is like Load, but applies the "key=value" overrides,
+// as given to --set, on top of the environment. They are applied before
+// the shared providers are fetched and host names are normalized, so an
+// override of providers_url or of a host is treated like the same
+// setting in a file.
+func LoadWithOverrides(path string, overrides []string) (*Config, error) {
 	// If no path specified, use default
 	if path == "" {
-		var err error
-		path, err = DefaultConfigPath()
+		path, _ = DefaultConfigPath()
+	}
+
+	// Start with default config
+	cfg := DefaultConfig()
+
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if project := FindProjectConfig(wd); project != "" && project != path {
+			if err := cfg.applyProjectFile(project); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	for _, arg := range overrides {
+		key, value, err := ParseSet(arg)
+		if err == nil {
+			err = cfg.Set(key, value)
+		}
 		if err != nil {
-			return DefaultConfig(), nil
+			return nil, fmt.Errorf("invalid --set: %w", err)
 		}
 	}
 
-	// Check if config file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return DefaultConfig(), nil
+	if cfg.ProvidersURL != "" {
+		cachePath, _ := DefaultProvidersCachePath()
+		cfg.applySharedProviders(cachePath)
+	}
+
+	if err := cfg.normalizeHosts(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// normalizeHosts converts internationalized host names, of the local
+// host and of every provider, to punycode.
+func (c *Config) normalizeHosts() error {
+	host, err := network.NormalizeHost(c.Host)
+	if err != nil {
+		return fmt.Errorf("host: %w", err)
 	}
+	c.Host = host
 
-	// Read config file
+	for _, providers := range []map[string]ProviderConfig{c.Providers, c.CustomProviders, c.SharedProviders} {
+		for name, p := range providers {
+			host, err := network.NormalizeHost(p.Host)
+			if err != nil {
+				return fmt.Errorf("provider %s: %w", name, err)
+			}
+			if host != p.Host {
+				p.Host = host
+				providers[name] = p
+			}
+		}
+	}
+	return nil
+}
+
+// FindProjectConfig looks for a project config file in dir and its parents,
+// stopping at the repository root (a directory containing .git) or the
+// filesystem root. It returns an empty string if none is found.
+func FindProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectConfigName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+
+		// Don't walk past the repository root
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// applyFile overlays the config file at path onto c.
+// A missing file is not an error.
+func (c *Config) applyFile(path string) error {
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Start with default config
-	cfg := DefaultConfig()
+	// Decode provider entries as raw nodes so they can be merged field by field
+	var raw struct {
+		Providers       map[string]yaml.Node `yaml:"providers"`
+		CustomProviders map[string]yaml.Node `yaml:"custom_providers"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fileError(path, err)
+	}
 
-	// Parse YAML
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	// Unknown keys are errors, so typos don't silently do nothing
+	providers, custom := c.Providers, c.CustomProviders
+	c.Providers, c.CustomProviders = nil, nil
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fileError(path, err)
 	}
 
-	return cfg, nil
+	if c.Providers, err = mergeProviders(providers, raw.Providers); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if c.CustomProviders, err = mergeProviders(custom, raw.CustomProviders); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	c.Sources = append(c.Sources, path)
+
+	return nil
+}
+
+// applyProjectFile overlays a project config onto c like applyFile, but
+// keeps the settings that run commands or pick ssh options: on_up,
+// on_down, extra_args and providers_url. A project config comes with the
+// repository it is in, which may be someone else's, so those can only be
+// set in the global config, with --set or with flags. The settings it
+// tried to change are listed in ProjectConfigWarning.
+func (c *Config) applyProjectFile(path string) error {
+	onUp, onDown, providersURL := c.OnUp, c.OnDown, c.ProvidersURL
+	extraArgs := func(providers map[string]ProviderConfig) map[string][]string {
+		args := make(map[string][]string, len(providers))
+		for name, p := range providers {
+			args[name] = p.ExtraArgs
+		}
+		return args
+	}
+	builtinArgs, customArgs := extraArgs(c.Providers), extraArgs(c.CustomProviders)
+
+	if err := c.applyFile(path); err != nil {
+		return err
+	}
+
+	var ignored []string
+	for _, s := range []struct {
+		key     string
+		value   *string
+		trusted string
+	}{
+		{"on_up", &c.OnUp, onUp},
+		{"on_down", &c.OnDown, onDown},
+		{"providers_url", &c.ProvidersURL, providersURL},
+	} {
+		if *s.value != s.trusted {
+			*s.value = s.trusted
+			ignored = append(ignored, s.key)
+		}
+	}
+	for _, section := range []struct {
+		key       string
+		providers map[string]ProviderConfig
+		trusted   map[string][]string
+	}{
+		{"providers", c.Providers, builtinArgs},
+		{"custom_providers", c.CustomProviders, customArgs},
+	} {
+		for name, p := range section.providers {
+			if !slices.Equal(p.ExtraArgs, section.trusted[name]) {
+				p.ExtraArgs = section.trusted[name]
+				section.providers[name] = p
+				ignored = append(ignored, section.key+"."+name+".extra_args")
+			}
+		}
+	}
+
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		c.ProjectConfigWarning = fmt.Sprintf("%s: ignoring %s; only the global config, --set or flags can set them",
+			path, strings.Join(ignored, ", "))
+	}
+	return nil
+}
+
+// mergeProviders returns base with the entries of overlay merged in.
+// Fields set in an overlay entry replace those of the base entry with the
+// same name; unset fields keep their base values.
+func mergeProviders(base map[string]ProviderConfig, overlay map[string]yaml.Node) (map[string]ProviderConfig, error) {
+	merged := make(map[string]ProviderConfig, len(base)+len(overlay))
+	for name, p := range base {
+		merged[name] = p
+	}
+	for name, node := range overlay {
+		p := merged[name]
+		if err := node.Decode(&p); err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		merged[name] = p
+	}
+	return merged, nil
+}
+
+// Environment variables that override config file settings.
+const (
+	EnvDefaultProvider = "QRLOCAL_DEFAULT_PROVIDER"
+	EnvCopyToClipboard = "QRLOCAL_COPY_TO_CLIPBOARD"
+	EnvQuietMode       = "QRLOCAL_QUIET_MODE"
+	EnvNoBanner        = "QRLOCAL_NO_BANNER"
+)
+
+// applyEnv overlays settings from environment variables onto c.
+func (c *Config) applyEnv() error {
+	if v := os.Getenv(EnvDefaultProvider); v != "" {
+		c.DefaultProvider = v
+		c.EnvOverrides = append(c.EnvOverrides, EnvDefaultProvider)
+	}
+
+	bools := []struct {
+		name string
+		dst  *bool
+	}{
+		{EnvCopyToClipboard, &c.CopyToClipboard},
+		{EnvQuietMode, &c.QuietMode},
+		{EnvNoBanner, &c.NoBanner},
+	}
+	for _, b := range bools {
+		v := os.Getenv(b.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %q", b.name, v)
+		}
+		*b.dst = parsed
+		c.EnvOverrides = append(c.EnvOverrides, b.name)
+	}
+
+	return nil
 }
 
 // Save writes the configuration to the specified path.
@@ -151,7 +543,8 @@ func (c *Config) Save(path string) error {
 }
 
 // GetProvider returns the provider configuration by name.
-// It checks built-in providers first, then custom providers.
+// It checks built-in providers first, then custom providers, then shared
+// providers.
 func (c *Config) GetProvider(name string) (ProviderConfig, bool) {
 	// Check built-in providers
 	if p, ok := c.Providers[name]; ok {
@@ -163,6 +556,11 @@ func (c *Config) GetProvider(name string) (ProviderConfig, bool) {
 		return p, true
 	}
 
+	// Check shared providers
+	if p, ok := c.SharedProviders[name]; ok {
+		return p, true
+	}
+
 	return ProviderConfig{}, false
 }
 
@@ -178,9 +576,84 @@ func (c *Config) ListProviders() []string {
 		providers = append(providers, name+" (custom)")
 	}
 
+	for name := range c.sharedOnly() {
+		providers = append(providers, name+" (shared)")
+	}
+
 	return providers
 }
 
+// ProviderInfo describes a configured provider for listings.
+type ProviderInfo struct {
+	Name     string `json:"name"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	URLRegex string `json:"url_regex"`
+	Builtin  bool   `json:"builtin"`
+	Shared   bool   `json:"shared,omitempty"`
+	Default  bool   `json:"default"`
+
+	Description  string   `json:"description,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// ProviderInfos returns all configured providers, built-in providers first,
+// then custom and shared providers, each group sorted by name.
+func (c *Config) ProviderInfos() []ProviderInfo {
+	shared := c.sharedOnly()
+	infos := make([]ProviderInfo, 0, len(c.Providers)+len(c.CustomProviders)+len(shared))
+	infos = appendProviderInfos(infos, c.Providers, true, c.DefaultProvider)
+	infos = appendProviderInfos(infos, c.CustomProviders, false, c.DefaultProvider)
+
+	start := len(infos)
+	infos = appendProviderInfos(infos, shared, false, c.DefaultProvider)
+	for i := start; i < len(infos); i++ {
+		infos[i].Shared = true
+	}
+	return infos
+}
+
+// sharedOnly returns the shared providers not shadowed by a built-in or
+// custom provider of the same name.
+func (c *Config) sharedOnly() map[string]ProviderConfig {
+	shared := make(map[string]ProviderConfig, len(c.SharedProviders))
+	for name, p := range c.SharedProviders {
+		_, builtin := c.Providers[name]
+		_, custom := c.CustomProviders[name]
+		if !builtin && !custom {
+			shared[name] = p
+		}
+	}
+	return shared
+}
+
+// appendProviderInfos appends the providers in m to infos in name order.
+func appendProviderInfos(infos []ProviderInfo, m map[string]ProviderConfig, builtin bool, defaultName string) []ProviderInfo {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := m[name]
+		infos = append(infos, ProviderInfo{
+			Name:     name,
+			Host:     p.Host,
+			Port:     p.Port,
+			User:     p.User,
+			URLRegex: p.URLRegex,
+			Builtin:  builtin,
+			Default:  name == defaultName,
+
+			Description:  p.Description,
+			Capabilities: p.Capabilities,
+		})
+	}
+	return infos
+}
+
 // InitConfig creates a new config file with default values.
 func InitConfig(path string) error {
 	cfg := DefaultConfig()
@@ -199,3 +672,76 @@ func Exists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
+
+// Validate checks the provider settings for mistakes that would only show
+// up when a tunnel is created, and returns all problems found.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := c.MaxAgeDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.ProvidersTTLDuration(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range c.FallbackProviders {
+		if _, ok := c.GetProvider(name); !ok {
+			errs = append(errs, fmt.Errorf("fallback_providers: unknown provider %s", name))
+		}
+	}
+	for _, info := range c.ProviderInfos() {
+		p, _ := c.GetProvider(info.Name)
+		for _, err := range p.validate() {
+			errs = append(errs, fmt.Errorf("provider %s: %w", info.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MaxAgeDuration parses MaxAge. It returns zero when MaxAge is empty.
+func (c *Config) MaxAgeDuration() (time.Duration, error) {
+	if c.MaxAge == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.MaxAge)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("max_age: %q is not a positive duration such as 2h", c.MaxAge)
+	}
+	return d, nil
+}
+
+// validate checks a single provider's settings.
+func (p ProviderConfig) validate() []error {
+	var errs []error
+	// user and host end up in ssh's user@host argument, where a leading
+	// '-' would be read as an option
+	if p.Host == "" {
+		errs = append(errs, errors.New("host is empty"))
+	} else if host, err := network.NormalizeHost(p.Host); err != nil {
+		errs = append(errs, err)
+	} else if err := network.ValidateHost(host); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.HasPrefix(p.User, "-") || strings.ContainsAny(p.User, "@ \t\r\n") {
+		errs = append(errs, fmt.Errorf("invalid user %q: must not start with '-' or contain '@' or spaces", p.User))
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", p.Port))
+	}
+	if p.URLRegex == "" {
+		errs = append(errs, errors.New("url_regex is empty"))
+	} else if _, err := regexp.Compile(p.URLRegex); err != nil {
+		errs = append(errs, fmt.Errorf("invalid url_regex: %w", err))
+	}
+	if p.TCPURLRegex != "" {
+		if _, err := regexp.Compile(p.TCPURLRegex); err != nil {
+			errs = append(errs, fmt.Errorf("invalid tcp_url_regex: %w", err))
+		}
+	}
+	if p.MaxConcurrent < 0 {
+		errs = append(errs, errors.New("max_concurrent must not be negative"))
+	}
+	if p.HostKey != "" && p.KnownHosts != "" {
+		errs = append(errs, errors.New("set host_key or known_hosts, not both"))
+	}
+	return errs
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file with the given contents to a temporary
+// directory and returns its path.
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		line     string // Expected line number in the error, "" for no error
+		message  string // Expected part of the message after path:line:
+	}{
+		{
+			name:     "unknown key with suggestion",
+			contents: "quiet_mode: true\ndefault-provider: serveo\n",
+			line:     "2",
+			message:  `unknown key "default-provider" (did you mean "default_provider"?)`,
+		},
+		{
+			name:     "unknown key without suggestion",
+			contents: "colour: red\n",
+			line:     "1",
+			message:  `unknown key "colour"`,
+		},
+		{
+			name:     "unknown provider key",
+			contents: "providers:\n  serveo:\n    Port: 2222\n",
+			line:     "3",
+			message:  `unknown key "Port" (did you mean "port"?)`,
+		},
+		{
+			name:     "wrong type",
+			contents: "default_provider: serveo\nquiet_mode: maybe\n",
+			line:     "2",
+			message:  "cannot unmarshal !!str `maybe` into bool",
+		},
+		{
+			name:     "syntax error",
+			contents: "default_provider: serveo\nquiet_mode: on: true\n",
+			line:     "2",
+			message:  "mapping values are not allowed in this context",
+		},
+		{
+			name:     "empty file",
+			contents: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.contents)
+			cfg := DefaultConfig()
+			err := cfg.applyFile(path)
+
+			if tt.line == "" {
+				if err != nil {
+					t.Fatalf("applyFile: %v", err)
+				}
+				if cfg.DefaultProvider != DefaultConfig().DefaultProvider {
+					t.Errorf("DefaultProvider = %q, want the default", cfg.DefaultProvider)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("applyFile succeeded, want an error")
+			}
+			prefix := path + ":" + tt.line + ": "
+			var problem string
+			for _, line := range strings.Split(err.Error(), "\n") {
+				if strings.HasPrefix(strings.TrimSpace(line), prefix) {
+					problem = strings.TrimPrefix(strings.TrimSpace(line), prefix)
+				}
+			}
+			if problem == "" {
+				t.Fatalf("error %q has no line starting with %q", err, prefix)
+			}
+			if !strings.Contains(problem, tt.message) {
+				t.Errorf("problem %q doesn't contain %q", problem, tt.message)
+			}
+		})
+	}
+}
+
+func TestProviderOverrides(t *testing.T) {
+	defaults := DefaultConfig().Providers
+	tests := []struct {
+		name     string
+		contents string
+		provider string
+		want     func(p ProviderConfig) ProviderConfig // Applied to the default entry
+	}{
+		{
+			name:     "port only",
+			contents: "providers:\n  serveo:\n    port: 2222\n",
+			provider: "serveo",
+			want: func(p ProviderConfig) ProviderConfig {
+				p.Port = 2222
+				return p
+			},
+		},
+		{
+			name:     "regex only",
+			contents: "providers:\n  localhost.run:\n    url_regex: 'https://\\S+'\n",
+			provider: "localhost.run",
+			want: func(p ProviderConfig) ProviderConfig {
+				p.URLRegex = `https://\S+`
+				return p
+			},
+		},
+		{
+			name:     "list replaced, not appended",
+			contents: "providers:\n  pinggy:\n    capabilities: [https]\n",
+			provider: "pinggy",
+			want: func(p ProviderConfig) ProviderConfig {
+				p.Capabilities = []string{"https"}
+				return p
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if err := cfg.applyFile(writeConfig(t, tt.contents)); err != nil {
+				t.Fatalf("applyFile: %v", err)
+			}
+			for name, def := range defaults {
+				want := def
+				if name == tt.provider {
+					want = tt.want(def)
+				}
+				if got := cfg.Providers[name]; !reflect.DeepEqual(got, want) {
+					t.Errorf("provider %s = %+v, want %+v", name, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("new provider", func(t *testing.T) {
+		cfg := DefaultConfig()
+		contents := `providers:
+  serveo:
+    user: me
+custom_providers:
+  mine:
+    host: tunnel.example.com
+    port: 2200
+    user: share
+    url_regex: 'https://[a-z]+\.example\.com'
+    remote_forward: "0:{{.Host}}:{{.Port}}"
+`
+		if err := cfg.applyFile(writeConfig(t, contents)); err != nil {
+			t.Fatalf("applyFile: %v", err)
+		}
+		want := ProviderConfig{
+			Host:          "tunnel.example.com",
+			Port:          2200,
+			User:          "share",
+			URLRegex:      `https://[a-z]+\.example\.com`,
+			RemoteForward: "0:{{.Host}}:{{.Port}}",
+		}
+		if got := cfg.CustomProviders["mine"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("custom provider = %+v, want %+v", got, want)
+		}
+		serveo := defaults["serveo"]
+		serveo.User = "me"
+		if got := cfg.Providers["serveo"]; !reflect.DeepEqual(got, serveo) {
+			t.Errorf("serveo = %+v, want %+v", got, serveo)
+		}
+		if len(cfg.Providers) != len(defaults) {
+			t.Errorf("got %d built-in providers, want %d", len(cfg.Providers), len(defaults))
+		}
+	})
+}
+
+func TestLoadWithOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	shared := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `providers:
+  team:
+    host: tünnel.example
+    port: 22
+    url_regex: 'https://\S+'
+`)
+	}))
+	defer shared.Close()
+
+	cfg, err := LoadWithOverrides(writeConfig(t, "quiet_mode: true\n"), []string{
+		"providers_url=" + shared.URL,
+		"host=münchen.example",
+		"providers.serveo.host=bücher.example",
+	})
+	if err != nil {
+		t.Fatalf("LoadWithOverrides: %v", err)
+	}
+
+	if cfg.SharedProvidersWarning != "" {
+		t.Errorf("SharedProvidersWarning = %q", cfg.SharedProvidersWarning)
+	}
+	if got := cfg.SharedProviders["team"].Host; got != "xn--tnnel-kva.example" {
+		t.Errorf("shared provider host = %q, want it fetched from the overridden providers_url and normalized", got)
+	}
+	if cfg.Host != "xn--mnchen-3ya.example" {
+		t.Errorf("Host = %q, want the override normalized", cfg.Host)
+	}
+	if got := cfg.Providers["serveo"].Host; got != "xn--bcher-kva.example" {
+		t.Errorf("serveo host = %q, want the override normalized", got)
+	}
+	if !cfg.QuietMode {
+		t.Error("the config file wasn't applied")
+	}
+
+	if _, err := LoadWithOverrides("", []string{"no_such_key=1"}); err == nil || !strings.Contains(err.Error(), "invalid --set") {
+		t.Errorf("unknown key error = %v, want an invalid --set error", err)
+	}
+}
+
+func TestSharedProvidersRejectSSHOptions(t *testing.T) {
+	builtins := DefaultConfig().Providers
+	tests := []struct {
+		name    string
+		user    string
+		host    string
+		message string
+	}{
+		{"option as user", "-oProxyCommand=touch /tmp/x", "tunnel.example", "invalid user"},
+		{"user with host", "a@evil.example", "tunnel.example", "invalid user"},
+		{"user with space", "a -oProxyCommand=x", "tunnel.example", "invalid user"},
+		{"option as host", "share", "-oProxyCommand=x", "invalid host"},
+		{"host with space", "share", "evil.example -oProxyCommand=x", "invalid host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := fmt.Sprintf("providers:\n  team:\n    host: %q\n    port: 22\n    user: %q\n    url_regex: 'https://\\S+'\n", tt.host, tt.user)
+			_, err := parseSharedProviders([]byte(doc), builtins)
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("parseSharedProviders = %v, want %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestSharedProvidersCacheChecked(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "providers-cache.yaml")
+	bad := ProviderConfig{Host: "tunnel.example", Port: 22, User: "-oProxyCommand=x", URLRegex: `https://\S+`}
+	if err := writeProvidersCache(cachePath, &providersCache{
+		URL:       "http://127.0.0.1:1/providers.yaml",
+		FetchedAt: time.Now(),
+		Providers: map[string]ProviderConfig{"team": bad},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.ProvidersURL = "http://127.0.0.1:1/providers.yaml"
+	cfg.applySharedProviders(cachePath)
+	if _, ok := cfg.SharedProviders["team"]; ok {
+		t.Error("a cached provider with an ssh option as its user was used")
+	}
+}
+
+func TestProjectConfigUntrustedSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	t.Chdir(project)
+	contents := `default_provider: pinggy
+on_up: "touch /tmp/up"
+on_down: "touch /tmp/down"
+providers_url: https://evil.example/providers.yaml
+providers:
+  serveo:
+    extra_args: ['-oProxyCommand=touch /tmp/x']
+custom_providers:
+  mine:
+    host: tunnel.example
+    port: 22
+    url_regex: 'https://\S+'
+    extra_args: ['-oProxyCommand=touch /tmp/y']
+`
+	if err := os.WriteFile(filepath.Join(project, ProjectConfigName), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	global := writeConfig(t, "on_up: notify-send up\nproviders:\n  serveo:\n    extra_args: ['-4']\n")
+	cfg, err := Load(global)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.DefaultProvider != "pinggy" {
+		t.Errorf("DefaultProvider = %q, want the project's", cfg.DefaultProvider)
+	}
+	if cfg.OnUp != "notify-send up" || cfg.OnDown != "" || cfg.ProvidersURL != "" {
+		t.Errorf("on_up = %q, on_down = %q, providers_url = %q, want the global config's", cfg.OnUp, cfg.OnDown, cfg.ProvidersURL)
+	}
+	if got := cfg.Providers["serveo"].ExtraArgs; !reflect.DeepEqual(got, []string{"-4"}) {
+		t.Errorf("serveo extra_args = %q, want the global config's", got)
+	}
+	if mine, ok := cfg.CustomProviders["mine"]; !ok || mine.ExtraArgs != nil {
+		t.Errorf("custom provider = %+v, want it defined without extra_args", mine)
+	}
+	for _, key := range []string{"on_up", "on_down", "providers_url", "providers.serveo.extra_args", "custom_providers.mine.extra_args"} {
+		if !strings.Contains(cfg.ProjectConfigWarning, key) {
+			t.Errorf("ProjectConfigWarning %q doesn't mention %s", cfg.ProjectConfigWarning, key)
+		}
+	}
+}
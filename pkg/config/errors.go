@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLineMessage splits a yaml.v3 error line such as "line 3: field x
+// not found in type config.Config" into its line number and message.
+var yamlLineMessage = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// unknownField matches yaml.v3's message for a key rejected by KnownFields.
+var unknownField = regexp.MustCompile(`^field (\S+) not found in type config\.(\w+)$`)
+
+// fileTypes are the types config files decode into, by name, for
+// suggesting the right spelling of an unknown key.
+var fileTypes = map[string]reflect.Type{
+	"Config":         reflect.TypeOf(Config{}),
+	"ProviderConfig": reflect.TypeOf(ProviderConfig{}),
+	"Messages":       reflect.TypeOf(Messages{}),
+}
+
+// fileError describes a failure to decode the config file at path, one
+// problem per line as "path:line: message". Unknown keys are named, with
+// the intended key suggested when it only differs in case or dashes.
+func fileError(path string, err error) error {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	problems := make([]string, 0, len(messages))
+	for _, message := range messages {
+		location := path
+		if m := yamlLineMessage.FindStringSubmatch(message); m != nil {
+			location += ":" + m[1]
+			message = m[2]
+		}
+		if m := unknownField.FindStringSubmatch(message); m != nil {
+			message = fmt.Sprintf("unknown key %q", m[1])
+			if suggestion := suggestKey(m[1], fileTypes[m[2]]); suggestion != "" {
+				message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+			}
+		}
+		problems = append(problems, location+": "+message)
+	}
+	return fmt.Errorf("invalid config file:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// suggestKey returns the key of t that key was probably meant to be,
+// ignoring case and treating dashes as underscores, or "".
+func suggestKey(key string, t reflect.Type) string {
+	if t == nil {
+		return ""
+	}
+	normalized := strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" && name == normalized {
+			return name
+		}
+	}
+	return ""
+}
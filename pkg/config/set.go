@@ -0,0 +1,276 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseSet splits a "key=value" override, as given to --set.
+func ParseSet(arg string) (key, value string, err error) {
+	key, value, ok := strings.Cut(arg, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("%q is not key=value", arg)
+	}
+	return key, value, nil
+}
+
+// Set overrides the setting at key, a dotted path of config file keys such
+// as "quiet_mode" or "providers.serveo.port", with value. Strings are
+// taken as is, booleans and numbers are parsed, and lists are either
+// comma-separated or a YAML flow sequence such as "[-J, bastion]".
+// Provider maps get a new entry when the name isn't there yet.
+//
+// Provider names may contain dots: an existing name such as
+// "providers.localhost.run.port" is found as it is, and any name can be
+// quoted, as in `custom_providers."my.tunnel".port`. KeyPath builds such
+// keys.
+func (c *Config) Set(key, value string) error {
+	_, err := c.set(key, value)
+	return err
+}
+
+// set is Set, returning the config file keys the setting was found at.
+func (c *Config) set(key, value string) ([]string, error) {
+	keys, err := splitKey(key)
+	if err == nil {
+		keys, err = setPath(reflect.ValueOf(c).Elem(), "", keys, value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	c.SetOverrides = append(c.SetOverrides, key)
+	return keys, nil
+}
+
+// splitKey splits a dotted key into config file keys. A key in double
+// quotes is taken whole, dots included.
+func splitKey(key string) ([]string, error) {
+	var keys []string
+	for rest := key; ; {
+		var k string
+		if quoted, ok := strings.CutPrefix(rest, `"`); ok {
+			end := strings.IndexByte(quoted, '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quote")
+			}
+			k, rest = quoted[:end], quoted[end+1:]
+			if rest != "" && rest[0] != '.' {
+				return nil, fmt.Errorf("%q must be followed by a dot", k)
+			}
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			k, rest = rest[:end], rest[end:]
+		}
+		if k == "" {
+			return nil, errors.New("empty key")
+		}
+		keys = append(keys, k)
+		if rest == "" {
+			return keys, nil
+		}
+		rest = rest[1:]
+	}
+}
+
+// KeyPath joins config file keys into a key for Set and SaveSetting,
+// quoting the ones that contain dots, such as provider names.
+func KeyPath(keys ...string) string {
+	quoted := make([]string, len(keys))
+	for i, k := range keys {
+		if strings.Contains(k, ".") {
+			k = `"` + k + `"`
+		}
+		quoted[i] = k
+	}
+	return strings.Join(quoted, ".")
+}
+
+// setPath sets the field at the path of keys below v, which is at the key
+// parent, to value, and returns the keys it was found at: map keys
+// containing dots come in split, and are joined back up.
+func setPath(v reflect.Value, parent string, keys []string, value string) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, setValue(v, value)
+	}
+	key := keys[0]
+
+	switch v.Kind() {
+	case reflect.Struct:
+		field, ok := fieldByKey(v, key)
+		if !ok {
+			if suggestion := suggestKey(key, v.Type()); suggestion != "" {
+				return nil, fmt.Errorf("unknown key %q (did you mean %q?)", key, suggestion)
+			}
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+		rest, err := setPath(field, key, keys[1:], value)
+		return append([]string{key}, rest...), err
+	case reflect.Map:
+		// The longest run of keys naming an existing entry, such as
+		// localhost.run, is one key
+		n := 1
+		for i := len(keys); i > 1; i-- {
+			if v.MapIndex(reflect.ValueOf(strings.Join(keys[:i], "."))).IsValid() {
+				n = i
+				break
+			}
+		}
+		key = strings.Join(keys[:n], ".")
+
+		// Map entries aren't addressable: update a copy and store it back
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		mapKey := reflect.ValueOf(key)
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(mapKey); existing.IsValid() {
+			entry.Set(existing)
+		}
+		rest, err := setPath(entry, key, keys[n:], value)
+		if err != nil {
+			return nil, err
+		}
+		v.SetMapIndex(mapKey, entry)
+		return append([]string{key}, rest...), nil
+	}
+	return nil, fmt.Errorf("%s is a single setting with no keys below it", parent)
+}
+
+// fieldByKey returns the field of struct v whose yaml key is key.
+func fieldByKey(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" && name == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setValue parses value into v according to v's type.
+func setValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.New("can't be set from the command line")
+		}
+		var items []string
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := yaml.Unmarshal([]byte(value), &items); err != nil {
+				return fmt.Errorf("%q is not a list: %w", value, err)
+			}
+		} else if value != "" {
+			for _, item := range strings.Split(value, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		// Whole sections, such as a provider, are given as YAML
+		dec := yaml.NewDecoder(bytes.NewReader([]byte(value)))
+		dec.KnownFields(true)
+		fresh := reflect.New(v.Type())
+		if err := dec.Decode(fresh.Interface()); err != nil {
+			return fmt.Errorf("%q is not valid YAML for this setting: %w", value, err)
+		}
+		v.Set(fresh.Elem())
+	}
+	return nil
+}
+
+// SaveSetting sets key, a dotted path of config keys as for Set, to the
+// string value in the config file at path, or the default config file
+// when path is empty. The rest of the file, comments included, is kept
+// as it is; a missing file is created.
+func SaveSetting(path, key, value string) error {
+	// Check the key the way --set would
+	keys, err := DefaultConfig().set(key, value)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		if path, err = DefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fileError(path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.MappingNode})
+	}
+
+	node := doc.Content[0]
+	for _, k := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s: %s:%d is not a mapping", key, path, node.Line)
+		}
+		node = mappingValue(node, k)
+	}
+	*node = yaml.Node{Kind: yaml.ScalarNode, Value: value, LineComment: node.LineComment}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// Hand-written config files are usually indented by two spaces
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the value node of key in mapping, adding an empty
+// mapping under key when it isn't there.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetDottedProviderNames(t *testing.T) {
+	tests := []struct {
+		key   string
+		check func(c *Config) bool
+	}{
+		{"providers.localhost.run.port", func(c *Config) bool { return c.Providers["localhost.run"].Port == 2222 }},
+		{`providers."localhost.run".port`, func(c *Config) bool { return c.Providers["localhost.run"].Port == 2222 }},
+		{`custom_providers."my.tunnel".port`, func(c *Config) bool { return c.CustomProviders["my.tunnel"].Port == 2222 }},
+		{"providers.serveo.port", func(c *Config) bool { return c.Providers["serveo"].Port == 2222 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if err := cfg.Set(tt.key, "2222"); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Error("the setting wasn't changed")
+			}
+			if _, ok := cfg.Providers["localhost"]; ok {
+				t.Error("Set created a provider named localhost")
+			}
+		})
+	}
+
+	for _, key := range []string{`providers."localhost.run`, `providers."localhost.run"port`, "providers..port"} {
+		if err := DefaultConfig().Set(key, "2222"); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", key)
+		}
+	}
+}
+
+func TestKeyPath(t *testing.T) {
+	key := KeyPath("providers", "localhost.run", "remote_forward")
+	if key != `providers."localhost.run".remote_forward` {
+		t.Errorf("KeyPath = %s", key)
+	}
+	keys, err := splitKey(key)
+	if err != nil || !reflect.DeepEqual(keys, []string{"providers", "localhost.run", "remote_forward"}) {
+		t.Errorf("splitKey(%s) = %q, %v", key, keys, err)
+	}
+}
+
+func TestSaveSettingDottedProviderName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("# mine\nproviders:\n  localhost.run:\n    user: me\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"providers.localhost.run.port", `custom_providers."my.tunnel".remote_forward`} {
+		if err := SaveSetting(path, key, "2222"); err != nil {
+			t.Fatalf("SaveSetting(%s): %v", key, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.applyFile(path); err != nil {
+		t.Fatalf("applyFile: %v", err)
+	}
+	if p := cfg.Providers["localhost.run"]; p.Port != 2222 || p.User != "me" {
+		t.Errorf("localhost.run = %+v, want port 2222 and user me", p)
+	}
+	if got := cfg.CustomProviders["my.tunnel"].RemoteForward; got != "2222" {
+		t.Errorf("my.tunnel remote_forward = %q, want 2222", got)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# mine") {
+		t.Errorf("the file's comment was lost:\n%s", data)
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultProvidersTTL is how long a fetched providers_url document is
+	// used before it is fetched again, unless providers_ttl says otherwise.
+	DefaultProvidersTTL = 24 * time.Hour
+
+	// providersFetchTimeout bounds fetching providers_url, so an
+	// unreachable server delays startup only briefly.
+	providersFetchTimeout = 5 * time.Second
+
+	// maxProvidersSize is the largest providers_url document accepted.
+	maxProvidersSize = 1 << 20
+)
+
+// sharedProvidersFile is the document served at providers_url, in YAML
+// or JSON: a "providers" map in the format of custom_providers.
+type sharedProvidersFile struct {
+	Providers map[string]ProviderConfig `yaml:"providers"`
+}
+
+// providersCache is the last good copy of the providers_url document.
+type providersCache struct {
+	URL       string                    `yaml:"url"`
+	FetchedAt time.Time                 `yaml:"fetched_at"`
+	Providers map[string]ProviderConfig `yaml:"providers"`
+}
+
+// DefaultProvidersCachePath returns the default cache file for
+// providers_url (~/.qrlocal/providers-cache.yaml).
+func DefaultProvidersCachePath() (string, error) {
+	dir, err := DefaultConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "providers-cache.yaml"), nil
+}
+
+// ProvidersTTLDuration parses ProvidersTTL. It returns DefaultProvidersTTL
+// when ProvidersTTL is empty.
+func (c *Config) ProvidersTTLDuration() (time.Duration, error) {
+	if c.ProvidersTTL == "" {
+		return DefaultProvidersTTL, nil
+	}
+	d, err := time.ParseDuration(c.ProvidersTTL)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("providers_ttl: %q is not a duration such as 12h", c.ProvidersTTL)
+	}
+	return d, nil
+}
+
+// applySharedProviders fills SharedProviders from providers_url, using
+// the cached copy at cachePath while it is younger than the TTL. When
+// fetching fails or the document is invalid, the cached copy is used
+// however old it is and SharedProvidersWarning says why. It never fails
+// the load: the built-in and custom providers work regardless.
+func (c *Config) applySharedProviders(cachePath string) {
+	ttl, err := c.ProvidersTTLDuration()
+	if err != nil {
+		ttl = DefaultProvidersTTL
+	}
+
+	// The cache is checked again, as it may predate stricter checks
+	cache := readProvidersCache(cachePath)
+	if cache != nil && (cache.URL != c.ProvidersURL || checkSharedProviders(cache.Providers, c.Providers) != nil) {
+		cache = nil
+	}
+	if cache != nil && time.Since(cache.FetchedAt) < ttl {
+		c.SharedProviders = cache.Providers
+		return
+	}
+
+	providers, err := fetchProviders(c.ProvidersURL, c.Providers)
+	if err != nil {
+		c.SharedProvidersWarning = fmt.Sprintf("providers_url: %v", err)
+		if cache != nil {
+			c.SharedProviders = cache.Providers
+			c.SharedProvidersWarning += fmt.Sprintf("; using the copy fetched %s",
+				cache.FetchedAt.Local().Format("2006-01-02 15:04"))
+		}
+		return
+	}
+
+	c.SharedProviders = providers
+	if cachePath != "" {
+		// A cache that can't be written only means fetching again next run
+		_ = writeProvidersCache(cachePath, &providersCache{
+			URL:       c.ProvidersURL,
+			FetchedAt: time.Now(),
+			Providers: providers,
+		})
+	}
+}
+
+// fetchProviders downloads and validates the providers document at rawURL.
+// builtins are the built-in providers, whose names can't be redefined.
+func fetchProviders(rawURL string, builtins map[string]ProviderConfig) (map[string]ProviderConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+
+	client := &http.Client{Timeout: providersFetchTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: HTTP %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProvidersSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	if len(data) > maxProvidersSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, maxProvidersSize)
+	}
+
+	providers, err := parseSharedProviders(data, builtins)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider list at %s: %w", rawURL, err)
+	}
+	return providers, nil
+}
+
+// parseSharedProviders decodes a providers document, rejecting unknown
+// fields so typos don't pass silently, and checks every entry.
+func parseSharedProviders(data []byte, builtins map[string]ProviderConfig) (map[string]ProviderConfig, error) {
+	var doc sharedProvidersFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Providers) == 0 {
+		return nil, errors.New("no providers defined")
+	}
+	if err := checkSharedProviders(doc.Providers, builtins); err != nil {
+		return nil, err
+	}
+	return doc.Providers, nil
+}
+
+// checkSharedProviders checks every shared provider. They can't redefine
+// built-in providers or pass extra ssh options, which could run commands
+// on this machine; validate keeps options out of their user and host too.
+func checkSharedProviders(providers, builtins map[string]ProviderConfig) error {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		p := providers[name]
+		if name == "" {
+			errs = append(errs, errors.New("provider with an empty name"))
+			continue
+		}
+		if _, ok := builtins[name]; ok {
+			errs = append(errs, fmt.Errorf("provider %s: redefines a built-in provider", name))
+		}
+		if len(p.ExtraArgs) > 0 {
+			errs = append(errs, fmt.Errorf("provider %s: extra_args is not allowed in shared providers", name))
+		}
+		for _, err := range p.validate() {
+			errs = append(errs, fmt.Errorf("provider %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readProvidersCache returns the cache at path, or nil if there is none
+// or it can't be read.
+func readProvidersCache(path string) *providersCache {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	cache := &providersCache{}
+	if err := yaml.Unmarshal(data, cache); err != nil || len(cache.Providers) == 0 {
+		return nil
+	}
+	return cache
+}
+
+// writeProvidersCache saves cache to path.
+func writeProvidersCache(path string, cache *providersCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("failed to encode providers cache: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write providers cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// UnhealthyFailures is the number of consecutive failed connections
+	// after which a provider is tried last.
+	UnhealthyFailures = 2
+
+	// HealthCooldown is how long after its last failure an unhealthy
+	// provider is tried last.
+	HealthCooldown = 30 * time.Minute
+)
+
+// State holds what qrlocal remembers between runs. Unlike the config
+// file, it is written by qrlocal itself.
+type State struct {
+	Providers map[string]ProviderHealth `yaml:"providers,omitempty"`
+
+	// Downloads counts complete downloads from the built-in server by
+	// absolute file path, when persist_downloads is set.
+	Downloads map[string]uint64 `yaml:"downloads,omitempty"`
+
+	// ConfigOffered is set once the user was asked whether to create a
+	// config file, so they aren't asked again.
+	ConfigOffered bool `yaml:"config_offered,omitempty"`
+}
+
+// ProviderHealth records recent connection attempts to a provider.
+type ProviderHealth struct {
+	LastSuccess time.Time `yaml:"last_success,omitempty"`
+	LastFailure time.Time `yaml:"last_failure,omitempty"`
+	Failures    int       `yaml:"consecutive_failures,omitempty"`
+}
+
+// DefaultStatePath returns the default state file path (~/.qrlocal/state.yaml).
+func DefaultStatePath() (string, error) {
+	dir, err := DefaultConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.yaml"), nil
+}
+
+// LoadState reads the state file at path, or the default one if path is
+// empty. A missing file is an empty state.
+func LoadState(path string) (*State, error) {
+	if path == "" {
+		var err error
+		path, err = DefaultStatePath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &State{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return s, nil
+}
+
+// Save writes the state to path, or the default state file if path is empty.
+func (s *State) Save(path string) error {
+	if path == "" {
+		var err error
+		path, err = DefaultStatePath()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// RecordSuccess notes a successful connection to the named provider.
+func (s *State) RecordSuccess(name string, at time.Time) {
+	h := s.Providers[name]
+	h.LastSuccess = at
+	h.Failures = 0
+	s.setHealth(name, h)
+}
+
+// RecordFailure notes a failed connection to the named provider.
+func (s *State) RecordFailure(name string, at time.Time) {
+	h := s.Providers[name]
+	h.LastFailure = at
+	h.Failures++
+	s.setHealth(name, h)
+}
+
+func (s *State) setHealth(name string, h ProviderHealth) {
+	if s.Providers == nil {
+		s.Providers = make(map[string]ProviderHealth)
+	}
+	s.Providers[name] = h
+}
+
+// Unhealthy reports whether the named provider failed UnhealthyFailures
+// times in a row, the last time within HealthCooldown of now.
+func (s *State) Unhealthy(name string, now time.Time) bool {
+	h, ok := s.Providers[name]
+	return ok && h.Failures >= UnhealthyFailures && now.Sub(h.LastFailure) < HealthCooldown
+}
+
+// RecordDownloads replaces the saved download counts of the given files.
+func (s *State) RecordDownloads(counts map[string]uint64) {
+	if s.Downloads == nil {
+		s.Downloads = make(map[string]uint64, len(counts))
+	}
+	for path, n := range counts {
+		s.Downloads[path] = n
+	}
+}
+
+// ResetHealth forgets all recorded provider health.
+func (s *State) ResetHealth() {
+	s.Providers = nil
+}
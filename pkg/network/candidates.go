@@ -0,0 +1,187 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Candidate is a local address that other devices may reach this machine on.
+type Candidate struct {
+	IP        string // Link-local IPv6 addresses include their %zone
+	Interface string
+
+	// DefaultRoute is set when the interface carries the default route,
+	// i.e. the system would send traffic for the internet through it.
+	DefaultRoute bool
+
+	// Running is set when the interface has an active carrier.
+	Running bool
+
+	// Bindable is set when a listener could briefly be opened on the
+	// address, which rules out addresses that are configured but unusable.
+	Bindable bool
+}
+
+// IPv4 reports whether the candidate is an IPv4 address.
+func (c Candidate) IPv4() bool {
+	return !strings.Contains(c.IP, ":")
+}
+
+// LinkLocal reports whether the candidate is a link-local address: an
+// IPv6 address with a zone, or a self-assigned 169.254.x.x IPv4 address,
+// which an interface gets when DHCP fails and other devices rarely share.
+func (c Candidate) LinkLocal() bool {
+	return strings.Contains(c.IP, "%") || strings.HasPrefix(c.IP, "169.254.")
+}
+
+// score ranks candidates: bindable, default route, active carrier, then
+// IPv4 over global IPv6 over link-local addresses of either kind.
+func (c Candidate) score() int {
+	score := 0
+	if c.Bindable {
+		score += 16
+	}
+	if c.DefaultRoute {
+		score += 8
+	}
+	if c.Running {
+		score += 4
+	}
+	switch {
+	case c.LinkLocal():
+	case c.IPv4():
+		score += 2
+	default:
+		score++
+	}
+	return score
+}
+
+// GetLocalIPCandidates returns the addresses of all interfaces that are
+// up, except loopback, best first. Each candidate is probed concurrently
+// by binding a listener to it.
+func GetLocalIPCandidates() ([]Candidate, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	routed := defaultRouteIPs()
+
+	var candidates []Candidate
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+			if ip == nil || ip.IsLoopback() {
+				continue
+			}
+
+			c := Candidate{
+				IP:        ip.String(),
+				Interface: iface.Name,
+				Running:   iface.Flags&net.FlagRunning != 0,
+			}
+			switch {
+			case ip.To4() != nil, ip.IsGlobalUnicast():
+			case ip.IsLinkLocalUnicast():
+				// Link-local addresses are only reachable through their interface
+				c.IP += "%" + iface.Name
+			default:
+				continue
+			}
+			c.DefaultRoute = routed[ip.String()]
+			candidates = append(candidates, c)
+		}
+	}
+
+	probeCandidates(candidates)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score() > candidates[j].score()
+	})
+	return candidates, nil
+}
+
+// GetLocalIPv6 returns the best ranked local IPv6 address, for sharing
+// services that only listen on IPv6.
+func GetLocalIPv6() (string, error) {
+	candidates, err := GetLocalIPCandidates()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range candidates {
+		if !c.IPv4() {
+			return c.IP, nil
+		}
+	}
+	return "", fmt.Errorf("no suitable local IPv6 address found")
+}
+
+// defaultRouteIPs returns the source addresses the system would use to
+// reach the internet over IPv4 and IPv6. Dialing UDP sends no packets.
+func defaultRouteIPs() map[string]bool {
+	ips := make(map[string]bool)
+	for _, addr := range []string{"8.8.8.8:80", "[2001:4860:4860::8888]:80"} {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			continue
+		}
+		ips[conn.LocalAddr().(*net.UDPAddr).IP.String()] = true
+		conn.Close()
+	}
+	return ips
+}
+
+// probeCandidates sets Bindable on each candidate that a listener can be
+// opened on, probing them all at once.
+func probeCandidates(candidates []Candidate) {
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *Candidate) {
+			defer wg.Done()
+			ln, err := net.Listen("tcp", net.JoinHostPort(c.IP, "0"))
+			if err != nil {
+				return
+			}
+			ln.Close()
+			c.Bindable = true
+		}(&candidates[i])
+	}
+	wg.Wait()
+}
+
+// GetInterfaceIP returns the best address of the named interface.
+func GetInterfaceIP(name string) (string, error) {
+	candidates, err := GetLocalIPCandidates()
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, c := range candidates {
+		if c.Interface == name {
+			return c.IP, nil
+		}
+		names = append(names, c.Interface)
+	}
+	slices.Sort(names)
+	return "", fmt.Errorf("interface %q has no usable address (available: %s)", name, strings.Join(slices.Compact(names), ", "))
+}
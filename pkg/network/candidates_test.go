@@ -0,0 +1,59 @@
+package network
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCandidateRanking(t *testing.T) {
+	usable := func(ip, iface string) Candidate {
+		return Candidate{IP: ip, Interface: iface, Running: true, Bindable: true}
+	}
+	// Listed in the order they should rank
+	want := []Candidate{
+		usable("192.168.1.23", "wlan0"),
+		usable("10.0.0.5", "eth1"),
+		usable("fd00::2", "wlan0"),
+		usable("169.254.12.34", "eth0"),
+		usable("fe80::1%wlan0", "wlan0"),
+		{IP: "192.168.2.9", Interface: "eth2", Bindable: true}, // No carrier
+	}
+
+	got := []Candidate{want[4], want[3], want[5], want[2], want[1], want[0]}
+	sort.SliceStable(got, func(i, j int) bool { return got[i].score() > got[j].score() })
+
+	// Equal scores keep their order, so compare by rank groups
+	for i := range want {
+		if got[i].score() != want[i].score() {
+			t.Errorf("rank %d: got %s (score %d), want %s (score %d)", i+1, got[i].IP, got[i].score(), want[i].IP, want[i].score())
+		}
+	}
+
+	link := usable("169.254.12.34", "eth0")
+	lan := usable("192.168.1.23", "wlan0")
+	if link.score() >= lan.score() {
+		t.Errorf("169.254.x.x scores %d, not below a LAN address's %d", link.score(), lan.score())
+	}
+	if !link.LinkLocal() || lan.LinkLocal() {
+		t.Errorf("LinkLocal: 169.254.12.34 = %t, 192.168.1.23 = %t", link.LinkLocal(), lan.LinkLocal())
+	}
+}
+
+func TestLocalListenerIPv6Loopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	host, ok := LocalListener(port)
+	if !ok || host != "::1" {
+		t.Errorf("LocalListener = %q, %t; want ::1", host, ok)
+	}
+	if ip, err := listenerIPv6(); err == nil && strings.Contains(ip, "%") {
+		t.Errorf("listenerIPv6 = %q, want no link-local address", ip)
+	}
+}
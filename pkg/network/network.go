@@ -2,14 +2,68 @@
 package network
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/idna"
 )
 
-// IsPortActive checks if a given port has an active listener.
+// loopbackHosts are the addresses a listener on this machine is looked
+// for on, in order. Servers bound to [::1], or to [::] with IPv4 disabled,
+// don't answer on 127.0.0.1.
+var loopbackHosts = []string{"127.0.0.1", "::1"}
+
+// IsPortActive checks if a given port has an active listener on this
+// machine, over IPv4 or IPv6.
 func IsPortActive(port int) bool {
-	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	_, ok := LocalListener(port)
+	return ok
+}
+
+// LocalListener returns the address a listener on port answers on: the
+// IPv4 loopback address, else the IPv6 one, else the local IPv6 address
+// for servers bound to that address only. It reports false when nothing
+// is listening.
+func LocalListener(port int) (string, bool) {
+	for _, host := range loopbackHosts {
+		if IsAddrActive(host, port) {
+			return host, true
+		}
+	}
+	if ip, err := listenerIPv6(); err == nil && IsAddrActive(ip, port) {
+		return ip, true
+	}
+	return "", false
+}
+
+// listenerIPv6 returns the best ranked global IPv6 address of this
+// machine, for LocalListener to try after loopback. Ranking probes every
+// interface, so it happens once per run rather than on every poll.
+// Link-local addresses are left out: a server bound to one is rare, and
+// a zoned address is of no use to other devices.
+var listenerIPv6 = sync.OnceValues(func() (string, error) {
+	candidates, err := GetLocalIPCandidates()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range candidates {
+		if !c.IPv4() && !c.LinkLocal() {
+			return c.IP, nil
+		}
+	}
+	return "", fmt.Errorf("no global IPv6 address found")
+})
+
+// IsAddrActive checks if host has an active listener on port.
+func IsAddrActive(host string, port int) bool {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
 	if err != nil {
 		return false
@@ -18,62 +72,92 @@ func IsPortActive(port int) bool {
 	return true
 }
 
-// GetLocalIP returns the local network IP address.
-// This is the IP address that other devices on the same network can use.
-func GetLocalIP() (string, error) {
-	// Try to find a suitable local IP by attempting to connect to an external address
-	// We don't actually connect, but this helps us find the right interface
-	conn, err := net.Dial("udp", "8.8.8.8:80")
+// SpeaksTLS reports whether the listener on host:port completes a TLS
+// handshake. Certificates aren't verified, so self-signed dev servers count.
+func SpeaksTLS(host string, port int) bool {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
 	if err != nil {
-		// Fallback: iterate through interfaces
-		return getLocalIPFromInterfaces()
+		return false
 	}
-	defer conn.Close()
-
-	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP.String(), nil
+	conn.Close()
+	return true
 }
 
-// getLocalIPFromInterfaces iterates through network interfaces to find a local IP.
-func getLocalIPFromInterfaces() (string, error) {
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return "", fmt.Errorf("failed to get network interfaces: %w", err)
+// DetectScheme returns "https" if the listener on host:port speaks TLS,
+// and "http" otherwise.
+func DetectScheme(host string, port int) string {
+	if SpeaksTLS(host, port) {
+		return "https"
 	}
+	return "http"
+}
+
+// WaitForPort polls the given port with exponential backoff until it has an
+// active listener or timeout elapses. It reports whether the port came up.
+func WaitForPort(port int, timeout time.Duration) bool {
+	_, ok := WaitForLocalListener(port, timeout)
+	return ok
+}
+
+// WaitForLocalListener is like WaitForPort, and also returns the address
+// the listener answers on; see LocalListener.
+func WaitForLocalListener(port int, timeout time.Duration) (string, bool) {
+	var host string
+	ok := waitFor(timeout, func() bool {
+		var active bool
+		host, active = LocalListener(port)
+		return active
+	})
+	return host, ok
+}
+
+// WaitForAddr is like WaitForPort for a listener on host.
+func WaitForAddr(host string, port int, timeout time.Duration) bool {
+	return waitFor(timeout, func() bool {
+		return IsAddrActive(host, port)
+	})
+}
+
+// waitFor calls active with exponential backoff until it reports true or
+// timeout elapses, and returns its last result.
+func waitFor(timeout time.Duration, active func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	delay := 100 * time.Millisecond
 
-	for _, iface := range interfaces {
-		// Skip loopback and down interfaces
-		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
-			continue
+	for {
+		if active() {
+			return true
 		}
 
-		addrs, err := iface.Addrs()
-		if err != nil {
-			continue
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
 		}
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
 
-		for _, addr := range addrs {
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
-			}
-
-			// Skip loopback and IPv6 addresses
-			if ip == nil || ip.IsLoopback() {
-				continue
-			}
-
-			// Only return IPv4 addresses
-			if ip.To4() != nil {
-				return ip.String(), nil
-			}
+		if delay < 2*time.Second {
+			delay *= 2
 		}
 	}
+}
 
-	return "", fmt.Errorf("no suitable local IP address found")
+// GetLocalIP returns the local network IP address.
+// This is the IP address that other devices on the same network can use:
+// the best ranked of GetLocalIPCandidates.
+func GetLocalIP() (string, error) {
+	candidates, err := GetLocalIPCandidates()
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no suitable local IP address found")
+	}
+	return candidates[0].IP, nil
 }
 
 // GenerateLocalURL creates a local network URL for the given port.
@@ -82,5 +166,66 @@ func GenerateLocalURL(port int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("http://%s:%d", ip, port), nil
+	return GenerateURL("http", ip, port), nil
+}
+
+// GenerateURL creates a URL with the given scheme for host and port.
+// IPv6 addresses are enclosed in brackets, and the zone of a link-local
+// address such as fe80::1%eth0 is kept with its "%" encoded as "%25", as
+// browsers require (RFC 6874).
+func GenerateURL(scheme, host string, port int) string {
+	if addr, zone, ok := strings.Cut(host, "%"); ok && strings.Contains(addr, ":") {
+		host = addr + "%25" + zone
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// hostnameLabel matches a single DNS label.
+var hostnameLabel = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// NormalizeHost returns host with an internationalized domain name, such
+// as bücher.example, converted to its ASCII (punycode) form, as DNS, ssh
+// and URLs need it. IP addresses and ASCII hosts are returned unchanged.
+func NormalizeHost(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host %q: not a valid internationalized domain name", host)
+	}
+	return ascii, nil
+}
+
+// isASCII reports whether s contains only ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateHost checks that host is an IP address or a plausible hostname.
+func ValidateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("host is empty")
+	}
+	// netip accepts IPv6 zones such as fe80::1%eth0
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("invalid host %q: too long", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !hostnameLabel.MatchString(label) {
+			return fmt.Errorf("invalid host %q: not a hostname or IP address", host)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,40 @@
+package network
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestGenerateURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		host   string
+		port   int
+		want   string
+	}{
+		{"IPv4", "http", "192.168.1.23", 8080, "http://192.168.1.23:8080"},
+		{"hostname", "https", "myhost.local", 443, "https://myhost.local:443"},
+		{"IPv6", "http", "fd00::2", 3000, "http://[fd00::2]:3000"},
+		{"IPv6 loopback", "http", "::1", 3000, "http://[::1]:3000"},
+		{"zoned link-local", "http", "fe80::1%eth0", 8080, "http://[fe80::1%25eth0]:8080"},
+		{"numeric zone", "http", "fe80::1%2", 8080, "http://[fe80::1%252]:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateURL(tt.scheme, tt.host, tt.port)
+			if got != tt.want {
+				t.Fatalf("GenerateURL(%q, %q, %d) = %q, want %q", tt.scheme, tt.host, tt.port, got, tt.want)
+			}
+
+			// The URL must parse back to the same host, zone included
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatalf("url.Parse(%q): %v", got, err)
+			}
+			if u.Hostname() != tt.host {
+				t.Errorf("parsed host = %q, want %q", u.Hostname(), tt.host)
+			}
+		})
+	}
+}
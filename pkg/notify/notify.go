@@ -0,0 +1,77 @@
+// Package notify provides best-effort desktop notifications.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no desktop notifier is available on the system.
+var ErrUnavailable = errors.New("no desktop notifier available")
+
+// Available reports whether a desktop notifier can be used on this system.
+func Available() bool {
+	_, _, err := command("", "")
+	return err == nil
+}
+
+// Send displays a desktop notification with the given title and message.
+// It returns ErrUnavailable if the platform has no supported notifier.
+func Send(title, message string) error {
+	name, args, err := command(title, message)
+	if err != nil {
+		return err
+	}
+	if err := exec.Command(name, args...).Run(); err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	return nil
+}
+
+// command builds the platform-specific notifier invocation.
+func command(title, message string) (string, []string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("osascript"); err != nil {
+			return "", nil, ErrUnavailable
+		}
+		script := fmt.Sprintf("display notification %s with title %s",
+			appleScriptQuote(message), appleScriptQuote(title))
+		return "osascript", []string{"-e", script}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return "", nil, ErrUnavailable
+		}
+		return "notify-send", []string{"--app-name=qrlocal", title, message}, nil
+	case "windows":
+		if _, err := exec.LookPath("powershell.exe"); err != nil {
+			return "", nil, ErrUnavailable
+		}
+		return "powershell.exe", []string{"-NoProfile", "-NonInteractive", "-Command", toastScript(title, message)}, nil
+	default:
+		return "", nil, ErrUnavailable
+	}
+}
+
+// appleScriptQuote quotes a string for use as an AppleScript literal.
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// toastScript builds a PowerShell script that shows a Windows toast notification.
+func toastScript(title, message string) string {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null;` +
+		`$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02);` +
+		`$x = $t.GetElementsByTagName('text');` +
+		`$x.Item(0).AppendChild($t.CreateTextNode(` + quote(title) + `)) | Out-Null;` +
+		`$x.Item(1).AppendChild($t.CreateTextNode(` + quote(message) + `)) | Out-Null;` +
+		`[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('qrlocal').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+}
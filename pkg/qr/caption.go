@@ -0,0 +1,115 @@
+package qr
+
+import (
+	"image"
+	"image/draw"
+	"strings"
+)
+
+// maxCaptionLines is the number of lines a caption may wrap to before the
+// font is made smaller.
+const maxCaptionLines = 3
+
+// captionBreaks are characters after which a URL is preferably wrapped.
+const captionBreaks = "/?&=-._"
+
+// wrapCaption splits text into lines of at most width characters. Lines
+// are broken after a URL separator when one is found in the second half of
+// the line, and hard-wrapped otherwise.
+func wrapCaption(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+
+	var lines []string
+	runes := []rune(text)
+	for len(runes) > width {
+		cut := width
+		for i := width - 1; i >= width/2; i-- {
+			if strings.ContainsRune(captionBreaks, runes[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		lines = append(lines, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// layoutCaption picks a font scale for the caption texts in an image size
+// pixels wide, shrinking the font until every text wraps to at most
+// maxCaptionLines lines, and returns the scale and wrapped lines.
+func layoutCaption(size int, texts ...string) (int, []string) {
+	margin := size / 20
+	scale := max(1, size/150)
+
+	for {
+		chars := (size - 2*margin) / (cellWidth * scale)
+		var lines []string
+		fits := true
+		for _, text := range texts {
+			if text == "" {
+				continue
+			}
+			wrapped := wrapCaption(text, chars)
+			if len(wrapped) > maxCaptionLines {
+				fits = false
+			}
+			lines = append(lines, wrapped...)
+		}
+		if fits || scale == 1 {
+			return scale, lines
+		}
+		scale--
+	}
+}
+
+// addCaption returns a copy of img extended downwards with label and
+// caption drawn centered beneath the QR code in the foreground color.
+func addCaption(img *image.Paletted, label, caption string) *image.Paletted {
+	size := img.Bounds().Dx()
+	scale, lines := layoutCaption(size, label, caption)
+	if len(lines) == 0 {
+		return img
+	}
+
+	lineHeight := cellHeight * scale
+	padding := lineHeight / 2
+	height := img.Bounds().Dy() + len(lines)*lineHeight + 2*padding
+
+	out := image.NewPaletted(image.Rect(0, 0, size, height), img.Palette)
+	draw.Draw(out, img.Bounds(), img, image.Point{}, draw.Src)
+
+	fg := uint8(1)
+	for i, line := range lines {
+		runes := []rune(line)
+		x := (size - len(runes)*cellWidth*scale) / 2
+		y := img.Bounds().Dy() + padding + i*lineHeight
+		for _, r := range runes {
+			drawGlyph(out, glyph(r), x, y, scale, fg)
+			x += cellWidth * scale
+		}
+	}
+	return out
+}
+
+// drawGlyph draws g with its top-left corner at (x, y), each font pixel
+// scaled to a scale x scale square.
+func drawGlyph(img *image.Paletted, g [glyphWidth]byte, x, y, scale int, color uint8) {
+	for col, bits := range g {
+		for row := 0; row < glyphHeight; row++ {
+			if bits&(1<<row) == 0 {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x+col*scale+dx, y+row*scale+dy, color)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,611 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// blockSpec describes a group of Reed-Solomon blocks in a QR symbol.
+type blockSpec struct {
+	count     int // Number of blocks in the group
+	codewords int // Total codewords per block
+	data      int // Data codewords per block
+}
+
+// blockTable holds the block structure for each version (index 0 is
+// version 1) and recovery level, in the order L, M, Q, H.
+var blockTable = [40][4][]blockSpec{
+	{{{1, 26, 19}}, {{1, 26, 16}}, {{1, 26, 13}}, {{1, 26, 9}}},                                                                 // 1
+	{{{1, 44, 34}}, {{1, 44, 28}}, {{1, 44, 22}}, {{1, 44, 16}}},                                                                // 2
+	{{{1, 70, 55}}, {{1, 70, 44}}, {{2, 35, 17}}, {{2, 35, 13}}},                                                                // 3
+	{{{1, 100, 80}}, {{2, 50, 32}}, {{2, 50, 24}}, {{4, 25, 9}}},                                                                // 4
+	{{{1, 134, 108}}, {{2, 67, 43}}, {{2, 33, 15}, {2, 34, 16}}, {{2, 33, 11}, {2, 34, 12}}},                                    // 5
+	{{{2, 86, 68}}, {{4, 43, 27}}, {{4, 43, 19}}, {{4, 43, 15}}},                                                                // 6
+	{{{2, 98, 78}}, {{4, 49, 31}}, {{2, 32, 14}, {4, 33, 15}}, {{4, 39, 13}, {1, 40, 14}}},                                      // 7
+	{{{2, 121, 97}}, {{2, 60, 38}, {2, 61, 39}}, {{4, 40, 18}, {2, 41, 19}}, {{4, 40, 14}, {2, 41, 15}}},                        // 8
+	{{{2, 146, 116}}, {{3, 58, 36}, {2, 59, 37}}, {{4, 36, 16}, {4, 37, 17}}, {{4, 36, 12}, {4, 37, 13}}},                       // 9
+	{{{2, 86, 68}, {2, 87, 69}}, {{4, 69, 43}, {1, 70, 44}}, {{6, 43, 19}, {2, 44, 20}}, {{6, 43, 15}, {2, 44, 16}}},            // 10
+	{{{4, 101, 81}}, {{1, 80, 50}, {4, 81, 51}}, {{4, 50, 22}, {4, 51, 23}}, {{3, 36, 12}, {8, 37, 13}}},                        // 11
+	{{{2, 116, 92}, {2, 117, 93}}, {{6, 58, 36}, {2, 59, 37}}, {{4, 46, 20}, {6, 47, 21}}, {{7, 42, 14}, {4, 43, 15}}},          // 12
+	{{{4, 133, 107}}, {{8, 59, 37}, {1, 60, 38}}, {{8, 44, 20}, {4, 45, 21}}, {{12, 33, 11}, {4, 34, 12}}},                      // 13
+	{{{3, 145, 115}, {1, 146, 116}}, {{4, 64, 40}, {5, 65, 41}}, {{11, 36, 16}, {5, 37, 17}}, {{11, 36, 12}, {5, 37, 13}}},      // 14
+	{{{5, 109, 87}, {1, 110, 88}}, {{5, 65, 41}, {5, 66, 42}}, {{5, 54, 24}, {7, 55, 25}}, {{11, 36, 12}, {7, 37, 13}}},         // 15
+	{{{5, 122, 98}, {1, 123, 99}}, {{7, 73, 45}, {3, 74, 46}}, {{15, 43, 19}, {2, 44, 20}}, {{3, 45, 15}, {13, 46, 16}}},        // 16
+	{{{1, 135, 107}, {5, 136, 108}}, {{10, 74, 46}, {1, 75, 47}}, {{1, 50, 22}, {15, 51, 23}}, {{2, 42, 14}, {17, 43, 15}}},     // 17
+	{{{5, 150, 120}, {1, 151, 121}}, {{9, 69, 43}, {4, 70, 44}}, {{17, 50, 22}, {1, 51, 23}}, {{2, 42, 14}, {19, 43, 15}}},      // 18
+	{{{3, 141, 113}, {4, 142, 114}}, {{3, 70, 44}, {11, 71, 45}}, {{17, 47, 21}, {4, 48, 22}}, {{9, 39, 13}, {16, 40, 14}}},     // 19
+	{{{3, 135, 107}, {5, 136, 108}}, {{3, 67, 41}, {13, 68, 42}}, {{15, 54, 24}, {5, 55, 25}}, {{15, 43, 15}, {10, 44, 16}}},    // 20
+	{{{4, 144, 116}, {4, 145, 117}}, {{17, 68, 42}}, {{17, 50, 22}, {6, 51, 23}}, {{19, 46, 16}, {6, 47, 17}}},                  // 21
+	{{{2, 139, 111}, {7, 140, 112}}, {{17, 74, 46}}, {{7, 54, 24}, {16, 55, 25}}, {{34, 37, 13}}},                               // 22
+	{{{4, 151, 121}, {5, 152, 122}}, {{4, 75, 47}, {14, 76, 48}}, {{11, 54, 24}, {14, 55, 25}}, {{16, 45, 15}, {14, 46, 16}}},   // 23
+	{{{6, 147, 117}, {4, 148, 118}}, {{6, 73, 45}, {14, 74, 46}}, {{11, 54, 24}, {16, 55, 25}}, {{30, 46, 16}, {2, 47, 17}}},    // 24
+	{{{8, 132, 106}, {4, 133, 107}}, {{8, 75, 47}, {13, 76, 48}}, {{7, 54, 24}, {22, 55, 25}}, {{22, 45, 15}, {13, 46, 16}}},    // 25
+	{{{10, 142, 114}, {2, 143, 115}}, {{19, 74, 46}, {4, 75, 47}}, {{28, 50, 22}, {6, 51, 23}}, {{33, 46, 16}, {4, 47, 17}}},    // 26
+	{{{8, 152, 122}, {4, 153, 123}}, {{22, 73, 45}, {3, 74, 46}}, {{8, 53, 23}, {26, 54, 24}}, {{12, 45, 15}, {28, 46, 16}}},    // 27
+	{{{3, 147, 117}, {10, 148, 118}}, {{3, 73, 45}, {23, 74, 46}}, {{4, 54, 24}, {31, 55, 25}}, {{11, 45, 15}, {31, 46, 16}}},   // 28
+	{{{7, 146, 116}, {7, 147, 117}}, {{21, 73, 45}, {7, 74, 46}}, {{1, 53, 23}, {37, 54, 24}}, {{19, 45, 15}, {26, 46, 16}}},    // 29
+	{{{5, 145, 115}, {10, 146, 116}}, {{19, 75, 47}, {10, 76, 48}}, {{15, 54, 24}, {25, 55, 25}}, {{23, 45, 15}, {25, 46, 16}}}, // 30
+	{{{13, 145, 115}, {3, 146, 116}}, {{2, 74, 46}, {29, 75, 47}}, {{42, 54, 24}, {1, 55, 25}}, {{23, 45, 15}, {28, 46, 16}}},   // 31
+	{{{17, 145, 115}}, {{10, 74, 46}, {23, 75, 47}}, {{10, 54, 24}, {35, 55, 25}}, {{19, 45, 15}, {35, 46, 16}}},                // 32
+	{{{17, 145, 115}, {1, 146, 116}}, {{14, 74, 46}, {21, 75, 47}}, {{29, 54, 24}, {19, 55, 25}}, {{11, 45, 15}, {46, 46, 16}}}, // 33
+	{{{13, 145, 115}, {6, 146, 116}}, {{14, 74, 46}, {23, 75, 47}}, {{44, 54, 24}, {7, 55, 25}}, {{59, 46, 16}, {1, 47, 17}}},   // 34
+	{{{12, 151, 121}, {7, 152, 122}}, {{12, 75, 47}, {26, 76, 48}}, {{39, 54, 24}, {14, 55, 25}}, {{22, 45, 15}, {41, 46, 16}}}, // 35
+	{{{6, 151, 121}, {14, 152, 122}}, {{6, 75, 47}, {34, 76, 48}}, {{46, 54, 24}, {10, 55, 25}}, {{2, 45, 15}, {64, 46, 16}}},   // 36
+	{{{17, 152, 122}, {4, 153, 123}}, {{29, 74, 46}, {14, 75, 47}}, {{49, 54, 24}, {10, 55, 25}}, {{24, 45, 15}, {46, 46, 16}}}, // 37
+	{{{4, 152, 122}, {18, 153, 123}}, {{13, 74, 46}, {32, 75, 47}}, {{48, 54, 24}, {14, 55, 25}}, {{42, 45, 15}, {32, 46, 16}}}, // 38
+	{{{20, 147, 117}, {4, 148, 118}}, {{40, 75, 47}, {7, 76, 48}}, {{43, 54, 24}, {22, 55, 25}}, {{10, 45, 15}, {67, 46, 16}}},  // 39
+	{{{19, 148, 118}, {6, 149, 119}}, {{18, 75, 47}, {31, 76, 48}}, {{34, 54, 24}, {34, 55, 25}}, {{20, 45, 15}, {61, 46, 16}}}, // 40
+}
+
+// alignmentCenters lists the alignment pattern centers per version.
+var alignmentCenters = [41][]int{
+	{}, {},
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50}, {6, 30, 54}, {6, 32, 58}, {6, 34, 62},
+	{6, 26, 46, 66}, {6, 26, 48, 70}, {6, 26, 50, 74}, {6, 30, 54, 78}, {6, 30, 56, 82}, {6, 30, 58, 86}, {6, 34, 62, 90},
+	{6, 28, 50, 72, 94}, {6, 26, 50, 74, 98}, {6, 30, 54, 78, 102}, {6, 28, 54, 80, 106}, {6, 32, 58, 84, 110}, {6, 30, 58, 86, 114}, {6, 34, 62, 90, 118},
+	{6, 26, 50, 74, 98, 122}, {6, 30, 54, 78, 102, 126}, {6, 26, 52, 78, 104, 130}, {6, 30, 56, 82, 108, 134}, {6, 34, 60, 86, 112, 138}, {6, 30, 58, 86, 114, 142}, {6, 34, 62, 90, 118, 146},
+	{6, 30, 54, 78, 102, 126, 150}, {6, 24, 50, 76, 102, 128, 154}, {6, 28, 54, 80, 106, 132, 158}, {6, 32, 58, 84, 110, 136, 162}, {6, 26, 54, 82, 110, 138, 166}, {6, 30, 58, 86, 114, 142, 170},
+}
+
+// Decode reads the text content of an unrotated QR code bitmap, one entry
+// per module. The quiet zone is optional. Damaged codewords are corrected
+// up to the capacity of the symbol's error correction level; see
+// DecodeImage for reading a rendered image.
+func Decode(bitmap [][]bool) (string, error) {
+	grid, err := cropSymbol(bitmap)
+	if err != nil {
+		return "", err
+	}
+
+	size := len(grid)
+	version := (size - 17) / 4
+
+	level, mask, err := readFormat(grid)
+	if err != nil {
+		return "", err
+	}
+
+	reserved := functionModules(version)
+	codewords := readCodewords(grid, reserved, mask)
+
+	data, err := deinterleave(codewords, blockTable[version-1][level])
+	if err != nil {
+		return "", err
+	}
+
+	return decodeSegments(data, version)
+}
+
+// cropSymbol removes the quiet zone and checks the symbol size.
+func cropSymbol(bitmap [][]bool) ([][]bool, error) {
+	minX, minY, maxX, maxY := -1, -1, -1, -1
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			if minY < 0 {
+				minY = y
+			}
+			maxY = y
+			if minX < 0 || x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+		}
+	}
+	if minX < 0 {
+		return nil, errors.New("no QR symbol found")
+	}
+
+	size := maxX - minX + 1
+	if maxY-minY+1 != size || size < 21 || size > 177 || (size-17)%4 != 0 {
+		return nil, fmt.Errorf("invalid QR symbol size %dx%d", size, maxY-minY+1)
+	}
+
+	grid := make([][]bool, size)
+	for y := range grid {
+		grid[y] = bitmap[minY+y][minX : minX+size]
+	}
+	return grid, nil
+}
+
+// readFormat reads the recovery level and mask pattern from the format
+// information next to the top-left finder pattern.
+func readFormat(grid [][]bool) (level, mask int, err error) {
+	var bits uint32
+	set := func(i int, dark bool) {
+		if dark {
+			bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(i, grid[i][8])
+	}
+	set(6, grid[7][8])
+	set(7, grid[8][8])
+	set(8, grid[8][7])
+	for i := 9; i <= 14; i++ {
+		set(i, grid[8][14-i])
+	}
+
+	// Pick the valid format word closest to what was read
+	best, bestDist := -1, 4
+	for data := 0; data < 32; data++ {
+		if d := popcount(formatWord(data) ^ bits); d < bestDist {
+			best, bestDist = data, d
+		}
+	}
+	if best < 0 {
+		return 0, 0, errors.New("unreadable format information")
+	}
+
+	// Map the format's EC bits to the L, M, Q, H table order
+	levels := [4]int{1, 0, 3, 2}
+	return levels[best>>3], best & 7, nil
+}
+
+// formatWord returns the masked 15-bit BCH format word for 5 data bits.
+func formatWord(data int) uint32 {
+	v := uint32(data) << 10
+	for i := 14; i >= 10; i-- {
+		if v&(1<<i) != 0 {
+			v ^= 0x537 << (i - 10)
+		}
+	}
+	return (uint32(data)<<10 | v) ^ 0x5412
+}
+
+func popcount(v uint32) int {
+	n := 0
+	for ; v != 0; v &= v - 1 {
+		n++
+	}
+	return n
+}
+
+// functionModules marks the modules that don't carry data.
+func functionModules(version int) [][]bool {
+	size := 17 + 4*version
+	m := make([][]bool, size)
+	for i := range m {
+		m[i] = make([]bool, size)
+	}
+	fill := func(y0, x0, h, w int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				if y >= 0 && y < size && x >= 0 && x < size {
+					m[y][x] = true
+				}
+			}
+		}
+	}
+
+	// Finder patterns with separators and format information
+	fill(0, 0, 9, 9)
+	fill(0, size-8, 9, 8)
+	fill(size-8, 0, 8, 9)
+
+	// Alignment patterns, skipping those that overlap finders. This must
+	// happen before the timing patterns are marked.
+	centers := alignmentCenters[version]
+	for _, cy := range centers {
+		for _, cx := range centers {
+			if m[cy][cx] {
+				continue
+			}
+			fill(cy-2, cx-2, 5, 5)
+		}
+	}
+
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	// Version information
+	if version >= 7 {
+		fill(0, size-11, 6, 3)
+		fill(size-11, 0, 3, 6)
+	}
+
+	return m
+}
+
+// readCodewords reads and unmasks the data modules in placement order.
+func readCodewords(grid, reserved [][]bool, mask int) []byte {
+	size := len(grid)
+	var codewords []byte
+	var cur byte
+	n := 0
+
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		// Skip the vertical timing pattern
+		if right == 6 {
+			right--
+		}
+		for i := 0; i < size; i++ {
+			y := i
+			if upward {
+				y = size - 1 - i
+			}
+			for dx := 0; dx < 2; dx++ {
+				x := right - dx
+				if reserved[y][x] {
+					continue
+				}
+				bit := grid[y][x] != maskBit(mask, y, x)
+				cur <<= 1
+				if bit {
+					cur |= 1
+				}
+				n++
+				if n == 8 {
+					codewords = append(codewords, cur)
+					cur, n = 0, 0
+				}
+			}
+		}
+		upward = !upward
+	}
+	return codewords
+}
+
+// maskBit reports whether the mask pattern inverts the module at (y, x).
+func maskBit(mask, y, x int) bool {
+	switch mask {
+	case 0:
+		return (y+x)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (y+x)%3 == 0
+	case 4:
+		return (y/2+x/3)%2 == 0
+	case 5:
+		return (y*x)%2+(y*x)%3 == 0
+	case 6:
+		return ((y*x)%2+(y*x)%3)%2 == 0
+	default:
+		return ((y+x)%2+(y*x)%3)%2 == 0
+	}
+}
+
+// deinterleave splits codewords into blocks, corrects each block with its
+// error correction codewords and returns the concatenated data codewords.
+func deinterleave(codewords []byte, spec []blockSpec) ([]byte, error) {
+	var blocks [][]byte
+	var dataLens []int
+	total, maxData, ecLen := 0, 0, 0
+	for _, g := range spec {
+		for i := 0; i < g.count; i++ {
+			blocks = append(blocks, make([]byte, 0, g.codewords))
+			dataLens = append(dataLens, g.data)
+		}
+		total += g.count * g.codewords
+		if g.data > maxData {
+			maxData = g.data
+		}
+		ecLen = g.codewords - g.data
+	}
+	if len(codewords) < total {
+		return nil, errors.New("QR symbol is truncated")
+	}
+
+	pos := 0
+	for i := 0; i < maxData; i++ {
+		for b := range blocks {
+			if i < dataLens[b] {
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[pos])
+			pos++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		if err := correctBlock(block, ecLen); err != nil {
+			return nil, fmt.Errorf("block %d: %w", b+1, err)
+		}
+		data = append(data, block[:dataLens[b]]...)
+	}
+	return data, nil
+}
+
+// syndromes evaluates the block polynomial, first codeword highest, at
+// the generator's roots alpha^0 to alpha^(ecLen-1). They are all zero when
+// the block is intact.
+func syndromes(block []byte, ecLen int) ([]byte, bool) {
+	s := make([]byte, ecLen)
+	ok := true
+	for i := range s {
+		x := gfExp[i]
+		for _, c := range block {
+			s[i] = gfMul(s[i], x) ^ c
+		}
+		if s[i] != 0 {
+			ok = false
+		}
+	}
+	return s, ok
+}
+
+// correctBlock fixes up to ecLen/2 wrong codewords in a Reed-Solomon block
+// in place, finding the error locator with Berlekamp-Massey, the error
+// positions with a Chien search and their values with Forney's formula.
+func correctBlock(block []byte, ecLen int) error {
+	s, ok := syndromes(block, ecLen)
+	if ok {
+		return nil
+	}
+
+	// Berlekamp-Massey; polynomials are stored lowest degree first
+	locator := []byte{1}
+	prev := []byte{1}
+	errs, shift, prevDisc := 0, 1, byte(1)
+	for n := 0; n < ecLen; n++ {
+		d := s[n]
+		for i := 1; i <= errs && i < len(locator); i++ {
+			d ^= gfMul(locator[i], s[n-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		next := append([]byte(nil), locator...)
+		coef := gfDiv(d, prevDisc)
+		for len(next) < len(prev)+shift {
+			next = append(next, 0)
+		}
+		for i, c := range prev {
+			next[i+shift] ^= gfMul(coef, c)
+		}
+		if 2*errs <= n {
+			prev, prevDisc = locator, d
+			errs = n + 1 - errs
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*errs > ecLen {
+		return errors.New("too many errors to correct")
+	}
+
+	// The error evaluator is S(x) * locator(x) mod x^ecLen
+	evaluator := make([]byte, ecLen)
+	for i := range evaluator {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], s[i-j])
+		}
+	}
+
+	found := 0
+	for k := range block {
+		// Codeword k is the coefficient of x^(n-1-k); its locator is
+		// alpha^(n-1-k) and the locator polynomial has a root at its inverse
+		power := len(block) - 1 - k
+		x := gfExp[power%255]
+		xInv := gfExp[(255-power%255)%255]
+		if polyEval(locator, xInv) != 0 {
+			continue
+		}
+
+		// The formal derivative keeps the odd-degree terms
+		var deriv byte
+		for i := 1; i < len(locator); i += 2 {
+			deriv ^= gfMul(locator[i], gfPow(xInv, i-1))
+		}
+		if deriv == 0 {
+			return errors.New("too many errors to correct")
+		}
+		block[k] ^= gfMul(x, gfDiv(polyEval(evaluator, xInv), deriv))
+		found++
+	}
+	if found != errs {
+		return errors.New("too many errors to correct")
+	}
+	if _, ok := syndromes(block, ecLen); !ok {
+		return errors.New("too many errors to correct")
+	}
+	return nil
+}
+
+// GF(256) tables for the QR polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var gfExp, gfLog = func() (exp [256]byte, log [256]byte) {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	exp[255] = exp[0]
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+int(gfLog[b]))%255]
+}
+
+// gfDiv divides a by the non-zero b.
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+func gfPow(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])*n%255]
+}
+
+// polyEval evaluates a polynomial, lowest degree first, at x.
+func polyEval(p []byte, x byte) byte {
+	var v byte
+	for i := len(p) - 1; i >= 0; i-- {
+		v = gfMul(v, x) ^ p[i]
+	}
+	return v
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, errors.New("unexpected end of QR data")
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.data[r.pos/8]&(0x80>>(r.pos%8)) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v, nil
+}
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// decodeSegments parses the numeric, alphanumeric and byte mode segments
+// in the data codewords.
+func decodeSegments(data []byte, version int) (string, error) {
+	// Character count field widths for numeric, alphanumeric and byte modes
+	countBits := [3]int{10, 9, 8}
+	switch {
+	case version >= 27:
+		countBits = [3]int{14, 13, 16}
+	case version >= 10:
+		countBits = [3]int{12, 11, 16}
+	}
+
+	r := &bitReader{data: data}
+	var sb strings.Builder
+	for r.remaining() >= 4 {
+		mode, _ := r.read(4)
+		switch mode {
+		case 0x0:
+			return sb.String(), nil
+		case 0x1:
+			n, err := r.read(countBits[0])
+			if err != nil {
+				return "", err
+			}
+			for ; n >= 3; n -= 3 {
+				v, err := r.read(10)
+				if err != nil {
+					return "", err
+				}
+				fmt.Fprintf(&sb, "%03d", v)
+			}
+			switch n {
+			case 2:
+				v, err := r.read(7)
+				if err != nil {
+					return "", err
+				}
+				fmt.Fprintf(&sb, "%02d", v)
+			case 1:
+				v, err := r.read(4)
+				if err != nil {
+					return "", err
+				}
+				fmt.Fprintf(&sb, "%d", v)
+			}
+		case 0x2:
+			n, err := r.read(countBits[1])
+			if err != nil {
+				return "", err
+			}
+			for ; n >= 2; n -= 2 {
+				v, err := r.read(11)
+				if err != nil {
+					return "", err
+				}
+				if v/45 >= 45 {
+					return "", errors.New("invalid alphanumeric data")
+				}
+				sb.WriteByte(alphanumericChars[v/45])
+				sb.WriteByte(alphanumericChars[v%45])
+			}
+			if n == 1 {
+				v, err := r.read(6)
+				if err != nil {
+					return "", err
+				}
+				if v >= 45 {
+					return "", errors.New("invalid alphanumeric data")
+				}
+				sb.WriteByte(alphanumericChars[v])
+			}
+		case 0x4:
+			n, err := r.read(countBits[2])
+			if err != nil {
+				return "", err
+			}
+			for ; n > 0; n-- {
+				v, err := r.read(8)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteByte(byte(v))
+			}
+		default:
+			return "", fmt.Errorf("unsupported QR data mode %#x", mode)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,85 @@
+package qr
+
+import (
+	"bytes"
+	"testing"
+)
+
+// rsEncode appends ecLen Reed-Solomon error correction codewords to data,
+// using the QR code generator polynomial.
+func rsEncode(data []byte, ecLen int) []byte {
+	// The generator, highest degree first, has roots alpha^0..alpha^(ecLen-1)
+	gen := []byte{1}
+	for i := 0; i < ecLen; i++ {
+		next := make([]byte, len(gen)+1)
+		for j, c := range gen {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, gfExp[i])
+		}
+		gen = next
+	}
+
+	rem := make([]byte, ecLen)
+	for _, d := range data {
+		f := d ^ rem[0]
+		rem = append(rem[1:], 0)
+		for j := range rem {
+			rem[j] ^= gfMul(gen[j+1], f)
+		}
+	}
+	return append(append([]byte(nil), data...), rem...)
+}
+
+func TestCorrectBlock(t *testing.T) {
+	const ecLen = 10
+	data := []byte("qrlocal corrects errors")
+	block := rsEncode(data, ecLen)
+	if _, ok := syndromes(block, ecLen); !ok {
+		t.Fatal("encoded block has non-zero syndromes")
+	}
+
+	// Damage spread over data and error correction codewords
+	positions := []int{0, 31, 7, 19, 26, 12}
+	for errs := 0; errs <= len(positions); errs++ {
+		damaged := append([]byte(nil), block...)
+		for _, p := range positions[:errs] {
+			damaged[p] ^= byte(0x5a + p)
+		}
+		err := correctBlock(damaged, ecLen)
+
+		if errs <= ecLen/2 {
+			if err != nil {
+				t.Errorf("%d errors: %v", errs, err)
+			} else if !bytes.Equal(damaged, block) {
+				t.Errorf("%d errors: corrected block differs from the original", errs)
+			}
+		} else if err == nil {
+			t.Errorf("%d errors: corrected beyond capacity", errs)
+		}
+	}
+}
+
+func TestDecodeCorrectsDamage(t *testing.T) {
+	const content = "https://example.com/damaged"
+	code, err := newCode(content, LevelHighest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitmap := code.Bitmap()
+
+	// Flip a patch of modules in the middle of the symbol
+	mid := len(bitmap) / 2
+	for y := mid - 2; y <= mid+2; y++ {
+		for x := mid - 2; x <= mid+2; x++ {
+			bitmap[y][x] = !bitmap[y][x]
+		}
+	}
+
+	got, err := Decode(bitmap)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got != content {
+		t.Errorf("Decode = %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,45 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseFormat returns the dark and light module glyphs for a text format
+// name, for TextOptions.Dark and Light:
+//
+//	half             two rows of modules per line of half blocks (the default)
+//	full             "██" and "  ", one full block per module, doubled for a square aspect
+//	ascii            "##" and "  "
+//	custom:<d><l>    the single characters d and l, each doubled
+//
+// Half, and an empty name, return empty strings.
+func ParseFormat(name string) (dark, light string, err error) {
+	switch strings.ToLower(name) {
+	case "", "half":
+		return "", "", nil
+	case "full":
+		return "██", "  ", nil
+	case "ascii":
+		return "##", "  ", nil
+	}
+
+	chars, ok := strings.CutPrefix(name, "custom:")
+	if !ok {
+		return "", "", fmt.Errorf("invalid QR format %q (use half, full, ascii or custom:<dark><light>)", name)
+	}
+	runes := []rune(chars)
+	if len(runes) != 2 {
+		return "", "", fmt.Errorf("custom QR format needs exactly two characters, dark then light, got %q", chars)
+	}
+	for _, r := range runes {
+		if !unicode.IsPrint(r) || unicode.Is(unicode.Mn, r) {
+			return "", "", fmt.Errorf("custom QR format character %q is not printable", r)
+		}
+	}
+	if runes[0] == runes[1] {
+		return "", "", fmt.Errorf("custom QR format needs different dark and light characters")
+	}
+	return strings.Repeat(string(runes[0]), 2), strings.Repeat(string(runes[1]), 2), nil
+}
@@ -0,0 +1,190 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// DefaultScale is the number of pixels per module used when neither
+// a size nor a scale is given.
+const DefaultScale = 8
+
+// ImageOptions controls the dimensions of exported QR images.
+// Size and Scale are mutually exclusive; both include the quiet zone.
+type ImageOptions struct {
+	Size  int   // Absolute width/height in pixels
+	Scale int   // Pixels per module
+	Level Level // Error correction level
+
+	// Caption and Label are drawn as text beneath the QR code in PNG
+	// output, the label first. Both are optional.
+	Caption string
+	Label   string
+
+	// Style is the shape of the modules in PNG output
+	Style ModuleStyle
+
+	// Logo, when set, is drawn in the center of PNG output. It hides some
+	// modules, so the code is encoded with LevelHighest whatever Level
+	// says.
+	Logo image.Image
+}
+
+// level returns the error correction level to encode PNG output with.
+func (o ImageOptions) level() Level {
+	if o.Logo != nil {
+		return LevelHighest
+	}
+	return o.Level
+}
+
+// newCode encodes content with the given error correction level.
+func newCode(content string, level Level) (*qrcode.QRCode, error) {
+	return qrcode.New(content, level.recoveryLevel())
+}
+
+// pixelSize computes the image width/height in pixels for a symbol
+// that is modules wide (quiet zone included).
+func (o ImageOptions) pixelSize(modules int) (int, error) {
+	if o.Size != 0 && o.Scale != 0 {
+		return 0, errors.New("image size and scale are mutually exclusive")
+	}
+	if o.Size < 0 || o.Scale < 0 {
+		return 0, errors.New("image size and scale must be positive")
+	}
+	switch {
+	case o.Size > 0:
+		if o.Size < modules {
+			return 0, fmt.Errorf("image size %dpx is too small for %d modules", o.Size, modules)
+		}
+		return o.Size, nil
+	case o.Scale > 0:
+		return o.Scale * modules, nil
+	default:
+		return DefaultScale * modules, nil
+	}
+}
+
+// EncodePNG encodes content as a QR code PNG image.
+func EncodePNG(content string, opts ImageOptions) ([]byte, error) {
+	code, err := newCode(content, opts.level())
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := opts.pixelSize(len(code.Bitmap()))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Caption == "" && opts.Label == "" && opts.Style == StyleSquare && opts.Logo == nil {
+		return code.PNG(size)
+	}
+
+	img, err := codeImage(code, size, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Logo != nil {
+		return encodePNG(addLogo(img, size, len(code.Bitmap()), opts.Logo))
+	}
+	return encodePNG(img)
+}
+
+// codeImage draws code as a size x size image in the module style of
+// opts, with its caption and label beneath.
+func codeImage(code *qrcode.QRCode, size int, opts ImageOptions) (*image.Paletted, error) {
+	var img *image.Paletted
+	if opts.Style == StyleSquare {
+		var ok bool
+		if img, ok = code.Image(size).(*image.Paletted); !ok {
+			return nil, errors.New("unexpected QR image format")
+		}
+	} else {
+		img = styledImage(code.Bitmap(), size, opts.Style)
+	}
+	return addCaption(img, opts.Label, opts.Caption), nil
+}
+
+// encodePNG encodes img as a compact PNG.
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WritePNG writes content as a QR code PNG image to path.
+func WritePNG(content, path string, opts ImageOptions) error {
+	data, err := EncodePNG(content, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write PNG: %w", err)
+	}
+	return nil
+}
+
+// DataURI encodes content as a QR code PNG and returns it as a base64 data URI.
+func DataURI(content string, opts ImageOptions) (string, error) {
+	data, err := EncodePNG(content, opts)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// EncodeSVG encodes content as a QR code SVG image.
+func EncodeSVG(content string, opts ImageOptions) ([]byte, error) {
+	code, err := newCode(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+	size, err := opts.pixelSize(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="#ffffff"/>`, modules, modules)
+	sb.WriteString(`<path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&sb, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+	sb.WriteString("\n")
+
+	return []byte(sb.String()), nil
+}
+
+// WriteSVG writes content as a QR code SVG image to path.
+func WriteSVG(content, path string, opts ImageOptions) error {
+	data, err := EncodeSVG(content, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SVG: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Level is a QR code error correction level. The zero value is the
+// default level, medium.
+type Level int
+
+// Error correction levels, from least to most redundant.
+const (
+	LevelDefault Level = iota
+	LevelLow
+	LevelMedium
+	LevelHigh
+	LevelHighest
+)
+
+// levelNames maps level names to levels.
+var levelNames = map[string]Level{
+	"low":     LevelLow,
+	"medium":  LevelMedium,
+	"high":    LevelHigh,
+	"highest": LevelHighest,
+}
+
+// ParseLevel parses a level name: low, medium, high or highest.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return LevelDefault, nil
+	}
+	level, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid error correction level %q (use low, medium, high or highest)", name)
+	}
+	return level, nil
+}
+
+// normalize resolves LevelDefault to the concrete default level.
+func (l Level) normalize() Level {
+	if l == LevelDefault {
+		return LevelMedium
+	}
+	return l
+}
+
+// String returns the level name.
+func (l Level) String() string {
+	switch l.normalize() {
+	case LevelLow:
+		return "low"
+	case LevelHigh:
+		return "high"
+	case LevelHighest:
+		return "highest"
+	default:
+		return "medium"
+	}
+}
+
+// recoveryLevel converts the level to go-qrcode's representation.
+func (l Level) recoveryLevel() qrcode.RecoveryLevel {
+	switch l.normalize() {
+	case LevelLow:
+		return qrcode.Low
+	case LevelHigh:
+		return qrcode.High
+	case LevelHighest:
+		return qrcode.Highest
+	default:
+		return qrcode.Medium
+	}
+}
+
+// LevelPolicy chooses an error correction level based on content length,
+// lowering it for long content so the QR code stays small enough to show
+// in a terminal.
+type LevelPolicy struct {
+	Level     Level // Preferred level
+	Threshold int   // Content length above which the level is lowered; 0 disables
+	Floor     Level // Lowest level the policy may choose
+}
+
+// LevelFor returns the level to use for content and whether it was lowered.
+// The level drops one step for each full Threshold the content exceeds,
+// but never below Floor.
+func (p LevelPolicy) LevelFor(content string) (Level, bool) {
+	level := p.Level.normalize()
+	if p.Threshold <= 0 || len(content) <= p.Threshold {
+		return level, false
+	}
+
+	floor := p.Floor.normalize()
+	if p.Floor == LevelDefault {
+		floor = LevelLow
+	}
+
+	lowered := level
+	for steps := (len(content) - 1) / p.Threshold; steps > 0 && lowered > floor; steps-- {
+		lowered--
+	}
+	return lowered, lowered != level
+}
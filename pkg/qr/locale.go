@@ -0,0 +1,40 @@
+package qr
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// UnicodeSupported reports whether the terminal is likely to display
+// Unicode, judging by TERM and the locale variables. A dumb terminal or a
+// locale that is set but not UTF-8, such as "C" or "POSIX", means no.
+// Windows consoles and an unset locale are given the benefit of the doubt.
+func UnicodeSupported() bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	// The first of these that is set decides, as for setlocale(3)
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return isUTF8Locale(locale)
+		}
+	}
+	return true
+}
+
+// isUTF8Locale reports whether a locale name such as "en_US.UTF-8" uses
+// the UTF-8 encoding.
+func isUTF8Locale(locale string) bool {
+	_, rest, ok := strings.Cut(locale, ".")
+	if !ok {
+		return false
+	}
+	charset, _, _ := strings.Cut(rest, "@")
+	charset = strings.ToLower(strings.ReplaceAll(charset, "-", ""))
+	return charset == "utf8"
+}
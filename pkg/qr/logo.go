@@ -0,0 +1,85 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+
+	// Logos may be in any of these formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// LogoFraction is the largest share of the QR code's width, quiet zone
+// included, that a logo takes up. With the highest error correction the
+// modules it hides are recovered by scanners.
+const LogoFraction = 0.2
+
+// LoadLogo reads a PNG, JPEG or GIF image to embed with ImageOptions.Logo.
+func LoadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo: %w", err)
+	}
+	defer f.Close()
+
+	logo, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo %s: %w", path, err)
+	}
+	if logo.Bounds().Empty() {
+		return nil, fmt.Errorf("logo %s is empty", path)
+	}
+	return logo, nil
+}
+
+// addLogo draws logo in the center of the size x size QR code at the top
+// of img, scaled to fit LogoFraction of its width with its aspect ratio
+// kept, on a light square that leaves a module's width of margin.
+func addLogo(img image.Image, size, modules int, logo image.Image) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	box := int(float64(size) * LogoFraction)
+	lb := logo.Bounds()
+	w, h := box, box
+	if lb.Dx() > lb.Dy() {
+		h = max(1, box*lb.Dy()/lb.Dx())
+	} else {
+		w = max(1, box*lb.Dx()/lb.Dy())
+	}
+
+	margin := max(1, size/modules)
+	at := image.Rect((size-w)/2, (size-h)/2, (size-w)/2+w, (size-h)/2+h)
+	draw.Draw(out, at.Inset(-margin), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, at, scaleImage(logo, w, h), image.Point{}, draw.Over)
+	return out
+}
+
+// scaleImage resizes src to w x h, averaging the source pixels that fall
+// in each destination pixel so downscaled logos stay smooth.
+func scaleImage(src image.Image, w, h int) *image.RGBA {
+	sb := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/h
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := sb.Min.X + x*sb.Dx()/w
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/w)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+pr, g+pg, b+pb, a+pa, n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
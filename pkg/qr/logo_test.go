@@ -0,0 +1,64 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// testLogo returns a w x h logo, dark with a light stripe, as hard on the
+// modules it covers as a logo can be.
+func testLogo(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 20, G: 20, B: 60, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, h/3, w, 2*h/3), image.NewUniform(color.White), image.Point{}, draw.Src)
+	return img
+}
+
+func TestLogoDecodes(t *testing.T) {
+	logos := map[string]image.Image{
+		"square": testLogo(64, 64),
+		"wide":   testLogo(120, 40),
+	}
+	for name, logo := range logos {
+		for _, style := range []ModuleStyle{StyleSquare, StyleDots, StyleRounded} {
+			for _, size := range []ImageOptions{{}, {Size: 333}} {
+				opts := size
+				opts.Style = style
+				opts.Logo = logo
+				t.Run(fmt.Sprintf("%s/style=%d/size=%d", name, style, size.Size), func(t *testing.T) {
+					data, err := EncodePNG(verifyURL, opts)
+					if err != nil {
+						t.Fatalf("EncodePNG: %v", err)
+					}
+					if err := VerifyPNG(data, verifyURL); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestLogoForcesHighestLevel(t *testing.T) {
+	logo := testLogo(64, 64)
+	want, err := EncodePNG(verifyURL, ImageOptions{Logo: logo, Level: LevelHighest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []Level{LevelLow, LevelMedium, LevelHigh} {
+		got, err := EncodePNG(verifyURL, ImageOptions{Logo: logo, Level: level})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("level %s with a logo isn't encoded at the highest level", level)
+		}
+		if err := VerifyPNG(got, verifyURL); err != nil {
+			t.Errorf("level %s: %v", level, err)
+		}
+	}
+}
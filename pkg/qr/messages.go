@@ -0,0 +1,57 @@
+package qr
+
+// Messages holds the text the renderer adds around QR codes and
+// messages, so it can be translated. Empty fields use the defaults. Its
+// fields match config.Messages, which converts to it directly.
+type Messages struct {
+	PublicTitle string // Heading above a public URL's QR code
+	LocalTitle  string // Heading above a local URL's QR code
+	ScanHint    string // Hint below the URL
+	Error       string // Prefix of error messages, before ": "
+	Warning     string // Prefix of warning messages, before ": "
+}
+
+// DefaultMessages returns the built-in English messages.
+func DefaultMessages() Messages {
+	return Messages{
+		PublicTitle: "Public URL (via SSH tunnel)",
+		LocalTitle:  "Local Network URL",
+		ScanHint:    "Scan the QR code or visit the URL above",
+		Error:       "Error",
+		Warning:     "Warning",
+	}
+}
+
+// withDefaults returns m with its empty fields filled from DefaultMessages.
+func (m Messages) withDefaults() Messages {
+	d := DefaultMessages()
+	if m.PublicTitle == "" {
+		m.PublicTitle = d.PublicTitle
+	}
+	if m.LocalTitle == "" {
+		m.LocalTitle = d.LocalTitle
+	}
+	if m.ScanHint == "" {
+		m.ScanHint = d.ScanHint
+	}
+	if m.Error == "" {
+		m.Error = d.Error
+	}
+	if m.Warning == "" {
+		m.Warning = d.Warning
+	}
+	return m
+}
+
+// SetMessages sets the renderer's messages. Empty fields keep the English
+// defaults.
+func (r *Renderer) SetMessages(m Messages) {
+	r.messages = m.withDefaults()
+}
+
+// SetTitle replaces the heading above the QR code, for both local and
+// public URLs, with title as given. An empty title restores the default
+// headings.
+func (r *Renderer) SetTitle(title string) {
+	r.titleText = title
+}
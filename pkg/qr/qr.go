@@ -2,69 +2,242 @@
 package qr
 
 import (
+	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
-	"github.com/skip2/go-qrcode"
 )
 
 // Renderer handles QR code rendering with styled terminal output.
 type Renderer struct {
-	quiet bool
+	quiet    bool
+	noBanner bool
+	debug    bool
+	text     TextOptions
+	out      io.Writer
+	centered bool
+	width    int // Terminal width in columns, 0 when unknown
+	ascii    bool
+
+	messages  Messages
+	titleText string // Replaces the default headings when set
+	theme     Theme
 }
 
-// NewRenderer creates a new QR code renderer.
+// NewRenderer creates a new QR code renderer that writes to stderr,
+// keeping stdout free for machine-readable output.
 func NewRenderer(quiet bool) *Renderer {
-	return &Renderer{quiet: quiet}
-}
-
-// Styles for terminal output using Lipgloss.
-var (
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("205")).
-			MarginBottom(1)
-
-	urlStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("42")).
-			Background(lipgloss.Color("235")).
-			Padding(0, 1)
-
-	qrStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("255")).
-		Background(lipgloss.Color("0"))
-
-	infoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("244")).
-			Italic(true).
-			MarginTop(1)
-
-	errorStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("196"))
-
-	successStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("82"))
-
-	boxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("63")).
-			Padding(1, 2).
-			Align(lipgloss.Center)
-)
+	return &Renderer{quiet: quiet, out: os.Stderr, centered: true, messages: DefaultMessages(), theme: themes[DefaultTheme]}
+}
+
+// SetTheme sets the colors of the output; see ParseTheme.
+func (r *Renderer) SetTheme(theme Theme) {
+	r.theme = theme
+}
+
+// SetOutput sets where the renderer writes its output.
+func (r *Renderer) SetOutput(w io.Writer) {
+	r.out = w
+}
+
+// SetCentered controls whether RenderOutput centers the QR code in an
+// 80-column area, or a narrower one set with SetWidth. Without centering,
+// the output doesn't depend on the terminal layout, which keeps
+// golden-file tests stable.
+func (r *Renderer) SetCentered(centered bool) {
+	r.centered = centered
+}
+
+// SetWidth sets the width of the terminal in columns, so centering uses
+// at most that width and is skipped for output that doesn't fit. Zero
+// means unknown, and output is centered in 80 columns.
+func (r *Renderer) SetWidth(width int) {
+	r.width = width
+}
+
+// println writes a line of output.
+func (r *Renderer) println(s string) {
+	fmt.Fprintln(r.out, s)
+}
+
+// place centers output in an 80-column area, or the terminal width if it
+// is narrower, unless centering is disabled. Output at least as wide as
+// the area is left as is, since padding it would wrap every line.
+func (r *Renderer) place(output string) string {
+	if !r.centered {
+		return output
+	}
+	width := 80
+	if r.width > 0 {
+		width = min(width, r.width)
+	}
+	if lipgloss.Width(output) >= width {
+		return output
+	}
+	return lipgloss.Place(
+		width, 0, // width, height (0 = auto)
+		lipgloss.Center, lipgloss.Center,
+		output,
+	)
+}
+
+// SetQuiet enables or disables quiet mode.
+func (r *Renderer) SetQuiet(quiet bool) {
+	r.quiet = quiet
+}
+
+// SetNoBanner hides informational and success messages and the scan hint,
+// without changing the QR output the way quiet mode does.
+func (r *Renderer) SetNoBanner(noBanner bool) {
+	r.noBanner = noBanner
+}
+
+// banner reports whether non-essential messages should be printed.
+func (r *Renderer) banner() bool {
+	return !r.quiet && !r.noBanner
+}
+
+// SetTextOptions sets how QR codes are drawn in the terminal.
+func (r *Renderer) SetTextOptions(opts TextOptions) {
+	r.text = opts
+}
+
+// SetASCII makes messages and titles use only ASCII, without emoji,
+// symbols or box-drawing characters, for terminals that can't show
+// Unicode. The QR code itself is drawn according to the text options.
+func (r *Renderer) SetASCII(ascii bool) {
+	r.ascii = ascii
+}
+
+// symbol returns s, or fallback when output is limited to ASCII.
+func (r *Renderer) symbol(s, fallback string) string {
+	if r.ascii {
+		return fallback
+	}
+	return s
+}
+
+// title returns the styled heading shown above the QR code.
+func (r *Renderer) title(isPublic bool) string {
+	switch {
+	case r.titleText != "":
+		return r.theme.title.Render(r.titleText)
+	case isPublic:
+		return r.theme.title.Render(r.symbol("🌐 ", "") + r.messages.PublicTitle)
+	}
+	return r.theme.title.Render(r.symbol("📡 ", "") + r.messages.LocalTitle)
+}
+
+// SetDebug enables or disables debug messages.
+func (r *Renderer) SetDebug(debug bool) {
+	r.debug = debug
+}
+
+// asciiBorder replaces the rounded box border when output is limited to
+// ASCII.
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+}
+
+// TextOptions controls how a QR code is drawn as text.
+type TextOptions struct {
+	ASCII  bool  // Use "##" and spaces instead of Unicode blocks
+	Invert bool  // Swap dark and light modules
+	Level  Level // Error correction level
+
+	// Scale repeats each module Scale times in both directions. Scaled
+	// codes use full blocks (or "##") instead of half blocks so modules
+	// stay square. 0 and 1 mean no scaling.
+	Scale int
+
+	// Dark and Light, when set, are drawn for each dark and light module
+	// instead of half blocks. They must differ and have the same width;
+	// see ParseFormat.
+	Dark, Light string
+
+	// Sixel makes RenderOutput draw the QR code as a sixel image. Text
+	// output such as QRText is unaffected.
+	Sixel bool
+
+	// Pad makes RenderOutput surround its whole output with this many
+	// blank lines and columns in the QR code's light color, so
+	// screenshots crop cleanly. Sixel output isn't padded.
+	Pad int
+}
 
 // GenerateQRString generates a QR code as a string for terminal display.
 // Uses Unicode block characters for compact display.
 func GenerateQRString(url string) (string, error) {
-	qr, err := qrcode.New(url, qrcode.Medium)
+	return GenerateQRText(url, TextOptions{})
+}
+
+// GenerateQRText generates a QR code as text using the given options.
+func GenerateQRText(content string, opts TextOptions) (string, error) {
+	qr, err := newCode(content, opts.Level)
 	if err != nil {
 		return "", err
 	}
 
 	// Get the bitmap representation
 	bitmap := qr.Bitmap()
+	if opts.Invert {
+		invertBitmap(bitmap)
+	}
+
+	dark, light := opts.glyphs()
+	if dark == "" {
+		return halfBlockString(bitmap), nil
+	}
+	return cellString(bitmap, max(opts.Scale, 1), dark, light), nil
+}
+
+// glyphs returns the strings drawn for dark and light modules, or empty
+// strings when two rows of modules share a line of half blocks.
+func (o TextOptions) glyphs() (dark, light string) {
+	switch {
+	case o.Dark != "":
+		return o.Dark, o.Light
+	case o.ASCII:
+		return "##", "  "
+	case o.Scale > 1:
+		return "██", "  "
+	}
+	return "", ""
+}
+
+// cellString draws each module as a scale x scale square of cells, using
+// dark for dark modules and light for light ones.
+func cellString(bitmap [][]bool, scale int, dark, light string) string {
+	var sb strings.Builder
+	for _, row := range bitmap {
+		var line strings.Builder
+		for _, isDark := range row {
+			cell := light
+			if isDark {
+				cell = dark
+			}
+			line.WriteString(strings.Repeat(cell, scale))
+		}
+		line.WriteString("\n")
+		sb.WriteString(strings.Repeat(line.String(), scale))
+	}
+	return sb.String()
+}
+
+// invertBitmap swaps dark and light modules in place.
+func invertBitmap(bitmap [][]bool) {
+	for _, row := range bitmap {
+		for x := range row {
+			row[x] = !row[x]
+		}
+	}
+}
+
+// halfBlockString draws two rows of modules per line using half blocks.
+func halfBlockString(bitmap [][]bool) string {
 	size := len(bitmap)
 
 	var sb strings.Builder
@@ -98,12 +271,21 @@ func GenerateQRString(url string) (string, error) {
 		sb.WriteString("\n")
 	}
 
-	return sb.String(), nil
+	return sb.String()
+}
+
+// QRText returns the QR code text for content as the renderer draws it.
+func (r *Renderer) QRText(content string) (string, error) {
+	return GenerateQRText(content, r.text)
 }
 
 // RenderOutput renders the complete styled output with QR code and URL.
 func (r *Renderer) RenderOutput(url string, isPublic bool) error {
-	qrString, err := GenerateQRString(url)
+	if r.text.Sixel {
+		return r.renderSixel(url, isPublic)
+	}
+
+	qrString, err := r.QRText(url)
 	if err != nil {
 		return err
 	}
@@ -111,55 +293,131 @@ func (r *Renderer) RenderOutput(url string, isPublic bool) error {
 	// In quiet mode, only output the URL and QR
 	if r.quiet {
 		// Minimal output
-		styledURL := urlStyle.Render(url)
-		styledQR := qrStyle.Render(qrString)
+		styledURL := r.theme.url.Render(url)
+		styledQR := r.theme.qr.Render(qrString)
 
 		output := lipgloss.JoinVertical(lipgloss.Center,
 			styledQR,
 			styledURL,
 		)
 
-		// Center in terminal
-		centeredOutput := lipgloss.Place(
-			80, 0, // width, height (0 = auto)
-			lipgloss.Center, lipgloss.Center,
-			output,
-		)
-
-		println(centeredOutput)
+		r.println(r.place(r.pad(output)))
 		return nil
 	}
 
 	// Full styled output
-	var title string
-	if isPublic {
-		title = titleStyle.Render("🌐 Public URL (via SSH tunnel)")
-	} else {
-		title = titleStyle.Render("📡 Local Network URL")
+	title := r.title(isPublic)
+
+	styledURL := r.theme.url.Render(url)
+	styledQR := r.theme.qr.Render(qrString)
+
+	parts := []string{title, styledQR, styledURL}
+	if r.banner() {
+		parts = append(parts, r.theme.info.Render(r.messages.ScanHint))
 	}
+	content := lipgloss.JoinVertical(lipgloss.Center, parts...)
 
-	styledURL := urlStyle.Render(url)
-	styledQR := qrStyle.Render(qrString)
+	box := r.theme.box
+	if r.ascii {
+		box = box.Copy().Border(asciiBorder)
+	}
+	boxedContent := box.Render(content)
 
-	info := infoStyle.Render("Scan the QR code or visit the URL above")
+	r.println(r.place(r.pad(boxedContent)))
+	return nil
+}
 
-	content := lipgloss.JoinVertical(lipgloss.Center,
-		title,
-		styledQR,
-		styledURL,
-		info,
-	)
+// pad surrounds output with TextOptions.Pad blank lines and columns in
+// the color of the QR code's light modules, which is its foreground color
+// when inverted, so the padding continues the quiet zone.
+func (r *Renderer) pad(output string) string {
+	n := r.text.Pad
+	if n <= 0 {
+		return output
+	}
+
+	color := r.theme.qr.GetBackground()
+	if r.text.Invert {
+		color = r.theme.qr.GetForeground()
+	}
+	padStyle := lipgloss.NewStyle().Background(color)
+	blank := func(width int) string {
+		return padStyle.Render(strings.Repeat(" ", width))
+	}
+
+	lines := strings.Split(output, "\n")
+	width := 0
+	for _, line := range lines {
+		width = max(width, lipgloss.Width(line))
+	}
+
+	padded := make([]string, 0, len(lines)+2*n)
+	for range n {
+		padded = append(padded, blank(width+2*n))
+	}
+	for _, line := range lines {
+		padded = append(padded, blank(n)+line+blank(width-lipgloss.Width(line)+n))
+	}
+	for range n {
+		padded = append(padded, blank(width+2*n))
+	}
+	return strings.Join(padded, "\n")
+}
+
+// Terminal control sequences used by RenderScreen.
+const (
+	clearScreen = "\033[H\033[2J"
+	hideCursor  = "\033[?25l"
+	showCursor  = "\033[?25h"
+)
+
+// RenderScreen clears the terminal and draws the QR code for content centered
+// on a width x height screen, with label (if any) shown above it.
+func (r *Renderer) RenderScreen(w io.Writer, content, label string, width, height int) error {
+	qrString, err := r.QRText(content)
+	if err != nil {
+		return err
+	}
 
-	boxedContent := boxStyle.Render(content)
+	parts := []string{}
+	if label != "" {
+		parts = append(parts, r.theme.title.Render(label))
+	}
+	parts = append(parts, r.theme.qr.Render(qrString), r.theme.url.Render(content))
 
-	// Center in terminal
-	centeredOutput := lipgloss.Place(
-		80, 0,
+	screen := lipgloss.Place(
+		width, height,
 		lipgloss.Center, lipgloss.Center,
-		boxedContent,
+		lipgloss.JoinVertical(lipgloss.Center, parts...),
 	)
 
-	println(centeredOutput)
+	_, err = io.WriteString(w, hideCursor+clearScreen+screen)
+	return err
+}
+
+// ResetScreen clears the terminal and restores the cursor after RenderScreen.
+func ResetScreen(w io.Writer) {
+	io.WriteString(w, clearScreen+showCursor)
+}
+
+// renderSixel renders the output with the QR code as a sixel image. Images
+// can't be boxed or centered by lipgloss, so the layout is left-aligned.
+func (r *Renderer) renderSixel(url string, isPublic bool) error {
+	image, err := GenerateQRSixel(url, r.text, DefaultSixelScale)
+	if err != nil {
+		return err
+	}
+
+	if !r.quiet {
+		r.println(r.title(isPublic))
+	}
+
+	r.println(image)
+	r.println(r.theme.url.Render(url))
+
+	if r.banner() {
+		r.println(r.theme.info.Render(r.messages.ScanHint))
+	}
 	return nil
 }
 
@@ -168,24 +426,52 @@ func (r *Renderer) PrintError(message string) {
 	if r.quiet {
 		return
 	}
-	styled := errorStyle.Render("✗ Error: " + message)
-	println(styled)
+	styled := r.theme.error.Render(r.symbol("✗ ", "") + r.messages.Error + ": " + message)
+	r.println(styled)
+}
+
+// PrintWarning prints a styled warning message.
+func (r *Renderer) PrintWarning(message string) {
+	if r.quiet {
+		return
+	}
+	styled := r.theme.warning.Render(r.symbol("⚠ ", "") + r.messages.Warning + ": " + message)
+	r.println(styled)
 }
 
 // PrintSuccess prints a styled success message.
 func (r *Renderer) PrintSuccess(message string) {
-	if r.quiet {
+	if !r.banner() {
 		return
 	}
-	styled := successStyle.Render("✓ " + message)
-	println(styled)
+	styled := r.theme.success.Render(r.symbol("✓ ", "") + message)
+	r.println(styled)
 }
 
 // PrintInfo prints a styled info message.
 func (r *Renderer) PrintInfo(message string) {
+	if !r.banner() {
+		return
+	}
+	styled := r.theme.info.Render(r.symbol("ℹ ", "") + message)
+	r.println(styled)
+}
+
+// PrintNotice prints a styled message the user needs even when banners are
+// hidden, such as a short link. Quiet mode still suppresses it.
+func (r *Renderer) PrintNotice(message string) {
 	if r.quiet {
 		return
 	}
-	styled := infoStyle.Render("ℹ " + message)
-	println(styled)
+	styled := r.theme.info.Render(r.symbol("ℹ ", "") + message)
+	r.println(styled)
+}
+
+// PrintDebug prints a debug message when debug output is enabled.
+func (r *Renderer) PrintDebug(message string) {
+	if !r.debug {
+		return
+	}
+	styled := r.theme.debug.Render(r.symbol("· ", "- ") + message)
+	r.println(styled)
 }
@@ -0,0 +1,141 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// minContrast is the smallest difference in luminance, out of 255,
+	// between the darkest and lightest pixels that DecodeImage accepts:
+	// a fifth of the range, the lowest symbol contrast print quality
+	// grading passes. Below it, phone cameras struggle to tell modules
+	// apart.
+	minContrast = 51
+
+	// scanQuietZone is the width of light border, in modules, that
+	// DecodeImage requires around the symbol. The standard asks for four;
+	// scanners manage with less.
+	scanQuietZone = 2
+)
+
+// DecodeImage reads the text content of an upright, unskewed QR code
+// image such as those the package renders: a PNG, a sixel image or
+// rasterised terminal text. Pixels are split into dark and light at the
+// midpoint of the image's luminance range, and the center of each module
+// is sampled, so styled modules and a logo covering some of them are read
+// the way a scanner would. Codes drawn light on dark are read too. Text
+// beneath the code, such as a caption, is ignored.
+func DecodeImage(img image.Image) (string, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return "", errors.New("empty image")
+	}
+
+	lum := make([][]uint8, b.Dy())
+	lo, hi := uint8(255), uint8(0)
+	for y := range lum {
+		lum[y] = make([]uint8, b.Dx())
+		for x := range lum[y] {
+			l := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+			lum[y][x] = l
+			lo, hi = min(lo, l), max(hi, l)
+		}
+	}
+	if int(hi)-int(lo) < minContrast {
+		return "", fmt.Errorf("too little contrast between dark and light modules (%d of 255)", int(hi)-int(lo))
+	}
+
+	threshold := (int(lo) + int(hi)) / 2
+	var firstErr error
+	for _, inverted := range []bool{false, true} {
+		dark := make([][]bool, len(lum))
+		for y, row := range lum {
+			dark[y] = make([]bool, len(row))
+			for x, l := range row {
+				dark[y][x] = (int(l) < threshold) != inverted
+			}
+		}
+		text, err := scanBitmap(dark)
+		if err == nil {
+			return text, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
+
+// scanBitmap locates the symbol in a thresholded image by its top edge,
+// which runs from the top-left finder pattern to the end of the top-right
+// one, samples the module centers and decodes them.
+func scanBitmap(dark [][]bool) (string, error) {
+	top, left, right := -1, -1, -1
+	for y, row := range dark {
+		for x, d := range row {
+			if d {
+				if left < 0 {
+					left = x
+				}
+				right = x
+			}
+		}
+		if left >= 0 {
+			top = y
+			break
+		}
+	}
+	if top < 0 {
+		return "", errors.New("no QR symbol found")
+	}
+
+	// The finder pattern's top edge is seven modules long
+	run := 0
+	for x := left; x < len(dark[top]) && dark[top][x]; x++ {
+		run++
+	}
+	width := right - left + 1
+	version := int(math.Round((float64(width)*finderSize/float64(run) - 17) / 4))
+	if version < 1 || version > 40 {
+		return "", fmt.Errorf("no QR symbol found (%dpx wide with %dpx finder edges)", width, run)
+	}
+	modules := 17 + 4*version
+	module := float64(width) / float64(modules)
+
+	at := func(mx, my int) (isDark, ok bool) {
+		px := left + int((float64(mx)+0.5)*module)
+		py := top + int((float64(my)+0.5)*module)
+		if py < 0 || py >= len(dark) || px < 0 || px >= len(dark[py]) {
+			return false, false
+		}
+		return dark[py][px], true
+	}
+
+	bitmap := make([][]bool, modules)
+	for my := range bitmap {
+		bitmap[my] = make([]bool, modules)
+		for mx := range bitmap[my] {
+			d, ok := at(mx, my)
+			if !ok {
+				return "", errors.New("QR symbol is cut off")
+			}
+			bitmap[my][mx] = d
+		}
+	}
+
+	for i := -scanQuietZone; i < modules+scanQuietZone; i++ {
+		for j := 1; j <= scanQuietZone; j++ {
+			for _, p := range [][2]int{{i, -j}, {i, modules - 1 + j}, {-j, i}, {modules - 1 + j, i}} {
+				if d, ok := at(p[0], p[1]); d || !ok {
+					return "", errors.New("QR symbol has no quiet zone around it")
+				}
+			}
+		}
+	}
+
+	return Decode(bitmap)
+}
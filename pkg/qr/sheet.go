@@ -0,0 +1,90 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+)
+
+// SheetItem is one QR code on a contact sheet.
+type SheetItem struct {
+	Content string // Encoded in the QR code and printed beneath it
+	Label   string // Printed above the content, e.g. "Port 3000"
+}
+
+// ErrEmptySheet is returned by EncodeSheet when there is nothing to draw.
+var ErrEmptySheet = errors.New("no QR codes to put on the sheet")
+
+// EncodeSheet draws items as a grid of captioned QR codes, columns wide,
+// and returns it as a single PNG image for printing. The QR codes are
+// drawn with opts, except that each is captioned with its own content
+// and label. Cells are as large as the largest QR code, which is
+// centered in its cell.
+func EncodeSheet(items []SheetItem, columns int, opts ImageOptions) ([]byte, error) {
+	if len(items) == 0 {
+		return nil, ErrEmptySheet
+	}
+	if columns < 1 {
+		return nil, fmt.Errorf("a sheet needs at least one column, got %d", columns)
+	}
+	columns = min(columns, len(items))
+
+	images := make([]image.Image, len(items))
+	var palette color.Palette
+	var cellWidth, cellHeight int
+	for i, item := range items {
+		code, err := newCode(item.Content, opts.level())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", item.Content, err)
+		}
+		size, err := opts.pixelSize(len(code.Bitmap()))
+		if err != nil {
+			return nil, err
+		}
+
+		itemOpts := opts
+		itemOpts.Caption = item.Content
+		itemOpts.Label = item.Label
+		img, err := codeImage(code, size, itemOpts)
+		if err != nil {
+			return nil, err
+		}
+		images[i], palette = img, img.Palette
+		if opts.Logo != nil {
+			images[i] = addLogo(img, size, len(code.Bitmap()), opts.Logo)
+		}
+		cellWidth = max(cellWidth, images[i].Bounds().Dx())
+		cellHeight = max(cellHeight, images[i].Bounds().Dy())
+	}
+
+	rows := (len(items) + columns - 1) / columns
+	bounds := image.Rect(0, 0, columns*cellWidth, rows*cellHeight)
+	var sheet draw.Image = image.NewPaletted(bounds, palette)
+	if opts.Logo != nil {
+		// Logos need full color
+		sheet = image.NewRGBA(bounds)
+		draw.Draw(sheet, bounds, image.White, image.Point{}, draw.Src)
+	}
+	for i, img := range images {
+		bounds := img.Bounds()
+		x := (i%columns)*cellWidth + (cellWidth-bounds.Dx())/2
+		y := (i / columns) * cellHeight
+		draw.Draw(sheet, bounds.Add(image.Pt(x, y)), img, image.Point{}, draw.Src)
+	}
+	return encodePNG(sheet)
+}
+
+// WriteSheet writes the contact sheet for items to path; see EncodeSheet.
+func WriteSheet(path string, items []SheetItem, columns int, opts ImageOptions) error {
+	data, err := EncodeSheet(items, columns, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write sheet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package qr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultSixelScale is the number of pixels per module in sixel output.
+const DefaultSixelScale = 4
+
+// sixelTerms lists $TERM values (or prefixes) of terminals known to
+// support sixel graphics.
+var sixelTerms = []string{"mlterm", "foot", "yaft", "contour", "wezterm", "mintty", "xterm-sixel"}
+
+// sixelPrograms lists $TERM_PROGRAM values of terminals known to support
+// sixel graphics regardless of $TERM.
+var sixelPrograms = []string{"WezTerm", "iTerm.app", "mintty"}
+
+// SixelSupported reports whether the terminal appears to support sixel
+// graphics, based on $TERM and $TERM_PROGRAM. It errs on the side of
+// returning false, since a wrong guess prints garbage.
+func SixelSupported() bool {
+	term := os.Getenv("TERM")
+	if strings.Contains(term, "sixel") {
+		return true
+	}
+	for _, t := range sixelTerms {
+		if strings.HasPrefix(term, t) {
+			return true
+		}
+	}
+	program := os.Getenv("TERM_PROGRAM")
+	for _, p := range sixelPrograms {
+		if program == p {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateQRSixel encodes content as a QR code sixel image with scale
+// pixels per module. Dark modules are black unless opts.Invert is set.
+func GenerateQRSixel(content string, opts TextOptions, scale int) (string, error) {
+	code, err := newCode(content, opts.Level)
+	if err != nil {
+		return "", err
+	}
+	if scale <= 0 {
+		scale = DefaultSixelScale
+	}
+
+	bitmap := code.Bitmap()
+	if opts.Invert {
+		invertBitmap(bitmap)
+	}
+	return sixelString(bitmap, scale), nil
+}
+
+// sixelString encodes bitmap as a two-color sixel image. Each sixel band
+// covers six pixel rows; the light color is painted first, then the dark
+// color over the same band.
+func sixelString(bitmap [][]bool, scale int) string {
+	modules := len(bitmap)
+	size := modules * scale
+
+	var sb strings.Builder
+	sb.WriteString("\x1bPq")
+	fmt.Fprintf(&sb, `"1;1;%d;%d`, size, size)
+	sb.WriteString("#0;2;100;100;100#1;2;0;0;0")
+
+	dark := func(x, y int) bool {
+		return bitmap[y/scale][x/scale]
+	}
+
+	for band := 0; band < size; band += 6 {
+		for color, want := range []bool{false, true} {
+			if color > 0 {
+				sb.WriteString("$")
+			}
+			fmt.Fprintf(&sb, "#%d", color)
+
+			// Run-length encode identical sixels
+			var prev byte
+			run := 0
+			flush := func() {
+				switch {
+				case run == 0:
+				case run > 3:
+					fmt.Fprintf(&sb, "!%d%c", run, prev)
+				default:
+					sb.WriteString(strings.Repeat(string(prev), run))
+				}
+			}
+			for x := 0; x < size; x++ {
+				var bits byte
+				for i := 0; i < 6 && band+i < size; i++ {
+					if dark(x, band+i) == want {
+						bits |= 1 << i
+					}
+				}
+				ch := '?' + bits
+				if ch == prev {
+					run++
+					continue
+				}
+				flush()
+				prev, run = ch, 1
+			}
+			flush()
+		}
+		sb.WriteString("-")
+	}
+
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// ModuleStyle is the shape dark modules are drawn as in PNG output. The
+// zero value is StyleSquare.
+type ModuleStyle int
+
+// Module styles.
+const (
+	StyleSquare  ModuleStyle = iota // Plain squares, as drawn by go-qrcode
+	StyleDots                       // A circle per module
+	StyleRounded                    // Squares whose free corners are rounded off
+)
+
+// ParseModuleStyle parses a module style name: square, dots or rounded.
+func ParseModuleStyle(name string) (ModuleStyle, error) {
+	switch strings.ToLower(name) {
+	case "", "square":
+		return StyleSquare, nil
+	case "dots":
+		return StyleDots, nil
+	case "rounded":
+		return StyleRounded, nil
+	}
+	return 0, fmt.Errorf("invalid PNG style %q (use square, dots or rounded)", name)
+}
+
+const (
+	// quietZone is the border go-qrcode adds around the symbol, in modules.
+	quietZone = 4
+
+	// finderSize is the width of the three finder patterns, in modules.
+	finderSize = 7
+
+	// dotRadius and cornerRadius are in module widths. Dots stay a little
+	// apart so they read as dots; rounded corners leave enough of each
+	// module for scanners sampling its center.
+	dotRadius    = 0.45
+	cornerRadius = 0.4
+)
+
+// styledImage draws bitmap, quiet zone included, as a size x size image
+// with dark modules in the given style. The finder patterns are always
+// drawn as solid squares, since scanners locate the code by them.
+func styledImage(bitmap [][]bool, size int, style ModuleStyle) *image.Paletted {
+	modules := len(bitmap)
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+
+	dark := func(x, y int) bool {
+		return y >= 0 && y < modules && x >= 0 && x < modules && bitmap[y][x]
+	}
+
+	for py := 0; py < size; py++ {
+		my := py * modules / size
+		v := (float64(py)+0.5)*float64(modules)/float64(size) - float64(my)
+		for px := 0; px < size; px++ {
+			mx := px * modules / size
+			if !bitmap[my][mx] {
+				continue
+			}
+			u := (float64(px)+0.5)*float64(modules)/float64(size) - float64(mx)
+
+			fill := true
+			if !inFinder(mx, my, modules) {
+				switch style {
+				case StyleDots:
+					fill = math.Hypot(u-0.5, v-0.5) <= dotRadius
+				case StyleRounded:
+					fill = inRoundedModule(u, v, mx, my, dark)
+				}
+			}
+			if fill {
+				img.Pix[img.PixOffset(px, py)] = 1
+			}
+		}
+	}
+	return img
+}
+
+// inRoundedModule reports whether the point (u, v), relative to the
+// top-left of the dark module at (mx, my) in module widths, falls inside
+// it once its corners are rounded. A corner stays square when a
+// neighboring module on either of its sides is dark, so adjacent modules
+// join up into smooth shapes.
+func inRoundedModule(u, v float64, mx, my int, dark func(x, y int) bool) bool {
+	dx, cx := -1, cornerRadius
+	if u > 0.5 {
+		dx, cx = 1, 1-cornerRadius
+	}
+	dy, cy := -1, cornerRadius
+	if v > 0.5 {
+		dy, cy = 1, 1-cornerRadius
+	}
+
+	if dark(mx+dx, my) || dark(mx, my+dy) {
+		return true
+	}
+	// Only the square between the corner and its arc's center is cut
+	if (u-cx)*float64(dx) <= 0 || (v-cy)*float64(dy) <= 0 {
+		return true
+	}
+	return math.Hypot(u-cx, v-cy) <= cornerRadius
+}
+
+// inFinder reports whether module (x, y) of a bitmap modules wide, quiet
+// zone included, is part of one of the three finder patterns.
+func inFinder(x, y, modules int) bool {
+	near := func(i int) bool { return i >= quietZone && i < quietZone+finderSize }
+	far := func(i int) bool { return i >= modules-quietZone-finderSize && i < modules-quietZone }
+	return (near(x) && near(y)) || (far(x) && near(y)) || (near(x) && far(y))
+}
@@ -0,0 +1,37 @@
+package qr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStylesDecode(t *testing.T) {
+	sizes := []ImageOptions{
+		{},           // DefaultScale pixels per module
+		{Scale: 3},   // Small modules
+		{Size: 250},  // Not a multiple of the module count
+		{Size: 1001}, // Large and odd
+	}
+	for _, name := range []string{"square", "dots", "rounded"} {
+		style, err := ParseModuleStyle(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, level := range []Level{LevelLow, LevelHighest} {
+			for _, size := range sizes {
+				opts := size
+				opts.Style = style
+				opts.Level = level
+				t.Run(fmt.Sprintf("%s/%s/size=%d,scale=%d", name, level, size.Size, size.Scale), func(t *testing.T) {
+					data, err := EncodePNG(verifyURL, opts)
+					if err != nil {
+						t.Fatalf("EncodePNG: %v", err)
+					}
+					if err := VerifyPNG(data, verifyURL); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		}
+	}
+}
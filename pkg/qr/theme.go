@@ -0,0 +1,127 @@
+package qr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is a set of styles for the terminal output. The QR code style's
+// foreground is the color of the dark modules and its background that of
+// the light ones.
+type Theme struct {
+	title   lipgloss.Style
+	url     lipgloss.Style
+	qr      lipgloss.Style
+	info    lipgloss.Style
+	error   lipgloss.Style
+	warning lipgloss.Style
+	debug   lipgloss.Style
+	success lipgloss.Style
+	box     lipgloss.Style
+}
+
+// DefaultTheme is the name of the theme renderers start with.
+const DefaultTheme = "default"
+
+// themes are the preset themes, by name.
+var themes = map[string]Theme{
+	DefaultTheme: newTheme(themeColors{
+		title: "205", url: "42", urlBackground: "235", qr: "255", qrBackground: "0",
+		info: "244", error: "196", warning: "214", debug: "240", success: "82", border: "63",
+	}),
+
+	// mono has no colors at all, for e-ink displays, printing and
+	// terminals with colors turned off
+	"mono": newTheme(themeColors{}),
+
+	"solarized": newTheme(themeColors{
+		title: "#d33682", url: "#859900", urlBackground: "#073642", qr: "#fdf6e3", qrBackground: "#002b36",
+		info: "#93a1a1", error: "#dc322f", warning: "#b58900", debug: "#586e75", success: "#2aa198", border: "#268bd2",
+	}),
+
+	// high-contrast draws the QR code black on white, which every scanner
+	// reads best, and uses the brightest of the basic 16 colors for text
+	"high-contrast": newTheme(themeColors{
+		title: "15", url: "0", urlBackground: "11", qr: "0", qrBackground: "15",
+		info: "15", error: "9", warning: "11", debug: "7", success: "10", border: "15",
+	}),
+}
+
+// themeColors are the colors of a theme. Empty colors leave the
+// terminal's own.
+type themeColors struct {
+	title, url, urlBackground, qr, qrBackground  string
+	info, error, warning, debug, success, border string
+}
+
+// newTheme builds a theme with the default layout in the given colors.
+func newTheme(c themeColors) Theme {
+	return Theme{
+		title: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(themeColor(c.title)).
+			MarginBottom(1),
+		url: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(themeColor(c.url)).
+			Background(themeColor(c.urlBackground)).
+			Padding(0, 1),
+		qr: lipgloss.NewStyle().
+			Foreground(themeColor(c.qr)).
+			Background(themeColor(c.qrBackground)),
+		info: lipgloss.NewStyle().
+			Foreground(themeColor(c.info)).
+			Italic(true).
+			MarginTop(1),
+		error: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(themeColor(c.error)),
+		warning: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(themeColor(c.warning)),
+		debug: lipgloss.NewStyle().
+			Foreground(themeColor(c.debug)),
+		success: lipgloss.NewStyle().
+			Bold(true).
+			Foreground(themeColor(c.success)),
+		box: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(themeColor(c.border)).
+			Padding(1, 2).
+			Align(lipgloss.Center),
+	}
+}
+
+// themeColor returns the lipgloss color for c, or no color when c is
+// empty.
+func themeColor(c string) lipgloss.TerminalColor {
+	if c == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(c)
+}
+
+// ThemeNames returns the names of the preset themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ParseTheme returns the preset theme called name; "" is DefaultTheme.
+func ParseTheme(name string) (Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	theme, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return Theme{}, fmt.Errorf("invalid theme %q (use %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	return theme, nil
+}
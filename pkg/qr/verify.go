@@ -0,0 +1,267 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// The size in pixels of a terminal cell when QR text is rasterised for
+// verification. Cells are twice as tall as wide, so two half block rows
+// per line and two cells per full-block module both give square modules.
+const (
+	termCellWidth  = 4
+	termCellHeight = 8
+)
+
+// VerifyQR draws the QR code for content as RenderOutput would show it,
+// as text in the theme's colors or as a sixel image, and checks that the
+// picture scans back to content with DecodeImage. Glyphs other than block
+// elements are drawn as an even tint by how much of their cell they ink,
+// so custom formats and low contrast themes that a phone couldn't read
+// fail here too.
+func (r *Renderer) VerifyQR(content string) error {
+	var img image.Image
+	if r.text.Sixel {
+		sixel, err := GenerateQRSixel(content, r.text, DefaultSixelScale)
+		if err != nil {
+			return err
+		}
+		if img, err = parseSixel(sixel); err != nil {
+			return err
+		}
+	} else {
+		text, err := r.QRText(content)
+		if err != nil {
+			return err
+		}
+		fg, bg := r.qrColors()
+		img = rasteriseText(text, fg, bg)
+	}
+	return verifyImage(img, content, "rendered QR code")
+}
+
+// VerifyPNG checks that a PNG image, as written by EncodePNG, scans back
+// to content.
+func VerifyPNG(data []byte, content string) error {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("PNG QR code could not be read: %w", err)
+	}
+	return verifyImage(img, content, "PNG QR code")
+}
+
+// verifyImage decodes img and compares the result with content. what
+// names the image in errors.
+func verifyImage(img image.Image, content, what string) error {
+	decoded, err := DecodeImage(img)
+	if err != nil {
+		return fmt.Errorf("%s could not be decoded: %w", what, err)
+	}
+	if decoded != content {
+		return fmt.Errorf("%s decodes to %q, not %q", what, decoded, content)
+	}
+	return nil
+}
+
+// qrColors returns the colors the terminal shows the QR code's glyphs and
+// their background in. Colors the theme leaves unset, or the color
+// profile drops, are the terminal's own, taken to be light on dark;
+// DecodeImage reads either polarity, so only the contrast matters.
+func (r *Renderer) qrColors() (fg, bg color.Color) {
+	return displayColor(r.theme.qr.GetForeground(), color.Gray{Y: 0xe5}), displayColor(r.theme.qr.GetBackground(), color.Black)
+}
+
+// displayColor returns how the terminal shows c with the current color
+// profile, or def when it uses its default color instead.
+func displayColor(c lipgloss.TerminalColor, def color.Color) color.Color {
+	lc, ok := c.(lipgloss.Color)
+	profile := lipgloss.ColorProfile()
+	if !ok || profile == termenv.Ascii {
+		return def
+	}
+	tc := profile.Color(string(lc))
+	if tc == nil {
+		return def
+	}
+	if _, none := tc.(termenv.NoColor); none {
+		return def
+	}
+	return termenv.ConvertToRGB(tc)
+}
+
+// rasteriseText draws lines of terminal text as a grayscale image, with
+// each glyph's ink in fg over bg.
+func rasteriseText(text string, fg, bg color.Color) *image.Gray {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	cols := 0
+	for _, line := range lines {
+		cols = max(cols, len([]rune(line)))
+	}
+
+	fgY := float64(color.GrayModel.Convert(fg).(color.Gray).Y)
+	bgY := float64(color.GrayModel.Convert(bg).(color.Gray).Y)
+	img := image.NewGray(image.Rect(0, 0, cols*termCellWidth, len(lines)*termCellHeight))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(bgY)
+	}
+	for row, line := range lines {
+		for col, r := range []rune(line) {
+			for y := 0; y < termCellHeight; y++ {
+				for x := 0; x < termCellWidth; x++ {
+					cover := ink(r, x, y)
+					img.SetGray(col*termCellWidth+x, row*termCellHeight+y, color.Gray{Y: uint8(bgY + (fgY-bgY)*cover + 0.5)})
+				}
+			}
+		}
+	}
+	return img
+}
+
+// ink returns how much of the pixel at (x, y) in a rasterised cell glyph
+// r covers, from 0 to 1. The block elements QR text is drawn with are
+// exact; any other glyph is spread evenly over its cell with the coverage
+// of the caption font's glyph for it.
+func ink(r rune, x, y int) float64 {
+	fill := func(in bool) float64 {
+		if in {
+			return 1
+		}
+		return 0
+	}
+	switch r {
+	case '█':
+		return 1
+	case '▀':
+		return fill(y < termCellHeight/2)
+	case '▄':
+		return fill(y >= termCellHeight/2)
+	case '▌':
+		return fill(x < termCellWidth/2)
+	case '▐':
+		return fill(x >= termCellWidth/2)
+	case '░':
+		return 0.25
+	case '▒':
+		return 0.5
+	case '▓':
+		return 0.75
+	}
+	if unicode.IsSpace(r) {
+		return 0
+	}
+
+	set := 0
+	for _, column := range glyph(r) {
+		for ; column != 0; column &= column - 1 {
+			set++
+		}
+	}
+	return float64(set) / (cellWidth * cellHeight)
+}
+
+// parseSixel draws a sixel image, as written by sixelString, into an
+// image. It understands raster attributes, RGB color registers,
+// repeats and the carriage return and newline controls.
+func parseSixel(s string) (*image.RGBA, error) {
+	body, ok := strings.CutPrefix(s, "\x1bPq")
+	if !ok {
+		return nil, errors.New("not a sixel image")
+	}
+	body = strings.TrimSuffix(body, "\x1b\\")
+
+	// params reads the numbers separated by semicolons at body[i:]
+	params := func(i int) ([]int, int) {
+		var ps []int
+		for {
+			j := i
+			for j < len(body) && body[j] >= '0' && body[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(body[i:j])
+			ps = append(ps, n)
+			if j < len(body) && body[j] == ';' {
+				i = j + 1
+				continue
+			}
+			return ps, j
+		}
+	}
+
+	var img *image.RGBA
+	registers := map[int]color.RGBA{}
+	var current color.RGBA
+	x, band := 0, 0
+	paint := func(c byte, n int) error {
+		if img == nil {
+			return errors.New("sixel image has no size")
+		}
+		bits := c - '?'
+		for ; n > 0; n-- {
+			for i := 0; i < 6; i++ {
+				if bits&(1<<i) != 0 {
+					img.SetRGBA(x, band+i, current)
+				}
+			}
+			x++
+		}
+		return nil
+	}
+
+	for i := 0; i < len(body); {
+		c := body[i]
+		switch {
+		case c == '"':
+			var ps []int
+			ps, i = params(i + 1)
+			if len(ps) != 4 {
+				return nil, errors.New("invalid sixel raster attributes")
+			}
+			img = image.NewRGBA(image.Rect(0, 0, ps[2], ps[3]))
+		case c == '#':
+			var ps []int
+			ps, i = params(i + 1)
+			if len(ps) == 5 && ps[1] == 2 {
+				pct := func(v int) uint8 { return uint8(v * 255 / 100) }
+				registers[ps[0]] = color.RGBA{R: pct(ps[2]), G: pct(ps[3]), B: pct(ps[4]), A: 255}
+			}
+			current = registers[ps[0]]
+		case c == '!':
+			var ps []int
+			ps, i = params(i + 1)
+			if i >= len(body) {
+				return nil, errors.New("truncated sixel repeat")
+			}
+			if err := paint(body[i], ps[0]); err != nil {
+				return nil, err
+			}
+			i++
+		case c == '$':
+			x = 0
+			i++
+		case c == '-':
+			x, band = 0, band+6
+			i++
+		case c >= '?' && c <= '~':
+			if err := paint(c, 1); err != nil {
+				return nil, err
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	if img == nil {
+		return nil, errors.New("sixel image has no size")
+	}
+	return img, nil
+}
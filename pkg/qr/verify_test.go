@@ -0,0 +1,108 @@
+package qr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const verifyURL = "https://example.com/verify?id=42"
+
+// withColorProfile sets the lipgloss color profile for the rest of the test.
+func withColorProfile(t *testing.T, p termenv.Profile) {
+	t.Helper()
+	old := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(p)
+	t.Cleanup(func() { lipgloss.SetColorProfile(old) })
+}
+
+func TestVerifyQR(t *testing.T) {
+	formats := map[string]TextOptions{
+		"half":           {},
+		"half inverted":  {Invert: true},
+		"full":           {Dark: "██", Light: "  "},
+		"ascii":          {ASCII: true},
+		"scaled":         {Scale: 2},
+		"custom":         {Dark: "@@", Light: ".."},
+		"sixel":          {Sixel: true},
+		"sixel inverted": {Sixel: true, Invert: true},
+	}
+
+	for _, profile := range []termenv.Profile{termenv.TrueColor, termenv.ANSI, termenv.Ascii} {
+		withColorProfile(t, profile)
+		for _, theme := range ThemeNames() {
+			for name, opts := range formats {
+				r := NewRenderer(true)
+				r.SetTheme(themes[theme])
+				r.SetTextOptions(opts)
+				if err := r.VerifyQR(verifyURL); err != nil {
+					t.Errorf("profile %d, theme %s, format %s: %v", profile, theme, name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyQRFails(t *testing.T) {
+	withColorProfile(t, termenv.TrueColor)
+
+	tests := []struct {
+		name  string
+		theme Theme
+		opts  TextOptions
+		want  string
+	}{
+		{
+			name:  "colors too close",
+			theme: newTheme(themeColors{qr: "#777777", qrBackground: "#666666"}),
+			want:  "too little contrast",
+		},
+		{
+			name:  "characters too alike",
+			theme: themes[DefaultTheme],
+			opts:  TextOptions{Dark: "++", Light: "--"},
+			want:  "too little contrast",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRenderer(true)
+			r.SetTheme(tt.theme)
+			r.SetTextOptions(tt.opts)
+			err := r.VerifyQR(verifyURL)
+			if err == nil {
+				t.Fatal("VerifyQR passed, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q doesn't mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPNG(t *testing.T) {
+	tests := map[string]ImageOptions{
+		"default":      {},
+		"sized":        {Size: 250},
+		"with caption": {Caption: verifyURL, Label: "Wi-Fi"},
+	}
+	for name, opts := range tests {
+		data, err := EncodePNG(verifyURL, opts)
+		if err != nil {
+			t.Fatalf("%s: EncodePNG: %v", name, err)
+		}
+		if err := VerifyPNG(data, verifyURL); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	data, err := EncodePNG("https://example.com/other", ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPNG(data, verifyURL); err == nil || !strings.Contains(err.Error(), "decodes to") {
+		t.Errorf("VerifyPNG of another URL = %v, want a mismatch", err)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// requestIDRegex matches incoming request IDs that are kept as they are.
+// Others are replaced, so a client can't break up the log line.
+var requestIDRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// headerNameRegex matches valid HTTP header names.
+var headerNameRegex = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// accessLog writes a line per request, optionally tagging each request
+// with an ID.
+type accessLog struct {
+	mu       sync.Mutex
+	w        io.Writer
+	idHeader string // Request ID header; empty disables IDs
+}
+
+// newAccessLog returns an access log writing to w, or nil when w is nil.
+func newAccessLog(w io.Writer, idHeader string) (*accessLog, error) {
+	if w == nil {
+		return nil, nil
+	}
+	if idHeader != "" && !headerNameRegex.MatchString(idHeader) {
+		return nil, fmt.Errorf("invalid request ID header %q: use a header name like X-Request-Id", idHeader)
+	}
+	return &accessLog{w: w, idHeader: http.CanonicalHeaderKey(idHeader)}, nil
+}
+
+func (l *accessLog) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// The ID is kept on the request too, for anything it is passed on to
+		var id string
+		if l.idHeader != "" {
+			id = r.Header.Get(l.idHeader)
+			if !requestIDRegex.MatchString(id) {
+				id = newRequestID()
+			}
+			r.Header.Set(l.idHeader, id)
+			w.Header().Set(l.idHeader, id)
+		}
+
+		lw := &logWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		if lw.status == 0 {
+			lw.status = http.StatusOK
+		}
+
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		line := fmt.Sprintf("%s %s %q %d %d %s", time.Now().Format(time.RFC3339), client,
+			r.Method+" "+r.RequestURI+" "+r.Proto, lw.status, lw.size, time.Since(start).Round(time.Millisecond))
+		if id != "" {
+			line += " id=" + id
+		}
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		fmt.Fprintln(l.w, line)
+	})
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logWriter records the status code and body size of a response.
+type logWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *logWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the underlying writer's sendfile support for io.Copy.
+func (w *logWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.Copy(w.ResponseWriter, src)
+	w.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *logWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxCachedFileSize is the largest file kept in the file cache. Bigger
+// files are always read from disk.
+const MaxCachedFileSize = 1 << 20 // 1 MB
+
+// fileCache is a least-recently-used cache of small file contents, bounded
+// by total size. Entries are keyed by path and dropped when the file's
+// modification time or size changes.
+type fileCache struct {
+	mu      sync.Mutex
+	limit   int64
+	size    int64
+	order   *list.List // Most recently used at the front
+	entries map[string]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// cacheEntry is a cached file.
+type cacheEntry struct {
+	path    string
+	data    []byte
+	modTime time.Time
+}
+
+// newFileCache creates a cache holding up to limit bytes of file data.
+func newFileCache(limit int64) *fileCache {
+	return &fileCache{
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheable reports whether a file of size bytes may be cached.
+func (c *fileCache) cacheable(size int64) bool {
+	return size <= MaxCachedFileSize && size <= c.limit
+}
+
+// get returns the cached contents of path if they match info.
+func (c *fileCache) get(path string, info fs.FileInfo) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.modTime.Equal(info.ModTime()) || int64(len(entry.data)) != info.Size() {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.data, true
+}
+
+// put caches data for path, evicting the least recently used files to
+// stay within the size limit.
+func (c *fileCache) put(path string, data []byte, modTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		c.remove(el)
+	}
+	for c.size+int64(len(data)) > c.limit && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+	c.entries[path] = c.order.PushFront(&cacheEntry{path: path, data: data, modTime: modTime})
+	c.size += int64(len(data))
+}
+
+// remove drops an entry. The caller must hold c.mu.
+func (c *fileCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.path)
+	c.size -= int64(len(entry.data))
+}
+
+// CacheStats reports how many file requests were served from the cache
+// and how many had to read the file from disk. Both are zero when caching
+// is disabled.
+func (s *Server) CacheStats() (hits, misses uint64) {
+	if s.cache == nil {
+		return 0, 0
+	}
+	return s.cache.hits.Load(), s.cache.misses.Load()
+}
+
+// serveFile serves the regular file at filePath, from memory when it is
+// small enough to cache. Content type, Last-Modified, ETag, conditional
+// and range requests are handled the same way in both cases.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, filePath string, info fs.FileInfo) {
+	if s.cache == nil || !s.cache.cacheable(info.Size()) {
+		http.ServeFile(w, r, filePath)
+		return
+	}
+
+	data, ok := s.cache.get(filePath, info)
+	if ok {
+		s.cache.hits.Add(1)
+	} else {
+		s.cache.misses.Add(1)
+		var err error
+		data, err = os.ReadFile(filePath)
+		if err != nil {
+			http.ServeFile(w, r, filePath)
+			return
+		}
+		s.cache.put(filePath, data, info.ModTime())
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), bytes.NewReader(data))
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// MaxInMemoryContent is how much of a Content is held in memory; anything
+// larger is spilled to a temporary file.
+const MaxInMemoryContent = 8 << 20
+
+// ErrContentTooLarge is returned by ReadContent when the input is larger
+// than the limit.
+var ErrContentTooLarge = errors.New("input is too large")
+
+// Content is a single resource served at the root in place of a
+// directory, such as the output of a command piped to qrlocal.
+type Content struct {
+	data        []byte // The content, when it fits in memory
+	file        string // Temporary file holding the content otherwise
+	size        int64
+	contentType string
+	modTime     time.Time
+}
+
+// ReadContent reads r to the end, keeping up to MaxInMemoryContent bytes
+// in memory and spilling larger input to a temporary file, which Close
+// removes. Input larger than limit bytes fails with ErrContentTooLarge.
+// The content type is guessed from the first bytes.
+func ReadContent(r io.Reader, limit int64) (*Content, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, min(limit, MaxInMemoryContent)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Content{
+		contentType: http.DetectContentType(buf.Bytes()),
+		modTime:     time.Now(),
+	}
+	if n <= MaxInMemoryContent {
+		if n > limit {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrContentTooLarge, limit)
+		}
+		c.data, c.size = buf.Bytes(), n
+		return c, nil
+	}
+
+	f, err := os.CreateTemp("", "qrlocal-stdin-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	c.file = f.Name()
+	written, err := io.Copy(f, io.MultiReader(&buf, io.LimitReader(r, limit-n+1)))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = fmt.Errorf("%w: more than %d bytes", ErrContentTooLarge, limit)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.size = written
+	return c, nil
+}
+
+// Size returns the length of the content in bytes.
+func (c *Content) Size() int64 {
+	return c.size
+}
+
+// ContentType returns the guessed media type of the content.
+func (c *Content) ContentType() string {
+	return c.contentType
+}
+
+// Close removes the temporary file holding the content, if any.
+func (c *Content) Close() error {
+	if c.file == "" {
+		return nil
+	}
+	return os.Remove(c.file)
+}
+
+// handleContent serves the content at the root. Every other path is not
+// found.
+func (s *Server) handleContent(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.ReadSeeker
+	if s.content.file == "" {
+		body = bytes.NewReader(s.content.data)
+	} else {
+		f, err := os.Open(s.content.file)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	w.Header().Set("Content-Type", s.content.contentType)
+	http.ServeContent(w, r, "", s.content.modTime, body)
+}
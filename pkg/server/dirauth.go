@@ -0,0 +1,315 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthFileName is the name of the htpasswd-style file that password
+// protects the directory it is in, and everything below it down to the
+// next such file.
+const AuthFileName = ".qrlocal-auth"
+
+// isAuthFileName reports whether name refers to an auth file. Case is
+// ignored, as are trailing dots and spaces, which Windows drops when
+// opening a file, so other spellings of the name can't get one served on
+// file systems that treat them as the same file.
+func isAuthFileName(name string) bool {
+	return strings.EqualFold(strings.TrimRight(name, ". "), AuthFileName)
+}
+
+// isAuthFile reports whether filePath is an auth file: by name, or by
+// being the auth file of its directory, or of its symlink target's,
+// under a name the file system treats as the same, such as a Windows
+// short name, or through a hard link.
+func isAuthFile(filePath string) bool {
+	if isAuthFileName(filepath.Base(filePath)) {
+		return true
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	dirs := []string{filepath.Dir(filePath)}
+	if real, err := filepath.EvalSymlinks(filePath); err == nil {
+		dirs = append(dirs, filepath.Dir(real))
+	}
+	for _, dir := range dirs {
+		if auth, err := os.Stat(filepath.Join(dir, AuthFileName)); err == nil && os.SameFile(info, auth) {
+			return true
+		}
+	}
+	return false
+}
+
+// authFile is a parsed auth file: password hashes by user name.
+type authFile struct {
+	modTime time.Time
+	size    int64
+	users   map[string]string
+}
+
+// authFiles caches parsed auth files by path, re-reading a file when its
+// modification time or size changes.
+type authFiles struct {
+	mu    sync.Mutex
+	files map[string]*authFile
+}
+
+// load returns the parsed auth file at path.
+func (a *authFiles) load(path string, info os.FileInfo) (*authFile, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if f, ok := a.files[path]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &authFile{modTime: info.ModTime(), size: info.Size(), users: parseAuthFile(data)}
+	if a.files == nil {
+		a.files = make(map[string]*authFile)
+	}
+	a.files[path] = f
+	return f, nil
+}
+
+// parseAuthFile reads "user:hash" lines as written by htpasswd. Blank
+// lines, comments and lines without a colon are skipped.
+func parseAuthFile(data []byte) map[string]string {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			continue
+		}
+		users[user] = hash
+	}
+	return users
+}
+
+// allows reports whether user and password match an entry.
+func (f *authFile) allows(user, password string) bool {
+	hash, ok := f.users[user]
+	return ok && checkPassword(hash, password)
+}
+
+// checkPassword checks password against an htpasswd hash: Apache MD5
+// ($apr1$, the htpasswd default), SHA-1 ({SHA}) or plain text. Other
+// schemes, such as bcrypt, never match.
+func checkPassword(hash, password string) bool {
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		computed = apr1(password, salt)
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$"):
+		return false
+	default:
+		computed = password
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// apr1Alphabet is the crypt(3) base64 alphabet.
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1 computes Apache's MD5-based password hash, "$apr1$salt$digest".
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.Sum([]byte(password + salt + password))
+	ctx := md5.New()
+	ctx.Write([]byte(password + magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		ctx.Write(alt[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	final := ctx.Sum(nil)
+
+	for i := range 1000 {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 != 0 {
+			round.Write(final)
+		} else {
+			round.Write(pw)
+		}
+		final = round.Sum(nil)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(magic + salt + "$")
+	encode := func(v uint32, n int) {
+		for range n {
+			sb.WriteByte(apr1Alphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(final[g[0]])<<16|uint32(final[g[1]])<<8|uint32(final[g[2]]), 4)
+	}
+	encode(uint32(final[11]), 2)
+	return sb.String()
+}
+
+// authFileFor returns the path of the auth file nearest to filePath,
+// looking in filePath itself if it is a directory and then in each parent
+// up to the served or mounted directory it is in, or "" if there is none.
+func (s *Server) authFileFor(filePath string) string {
+	root := s.mountRoot(filePath)
+	if root == "" {
+		return ""
+	}
+	for dir := filePath; within(root, dir); dir = filepath.Dir(dir) {
+		candidate := filepath.Join(dir, AuthFileName)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+		if dir == root {
+			break
+		}
+	}
+	return ""
+}
+
+// findAuthFile returns the path of the first auth file in the served and
+// mounted directories, or "" if there is none.
+func findAuthFile(mounts []mount) string {
+	found := ""
+	for _, m := range mounts {
+		filepath.WalkDir(m.dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Skip what can't be read
+			}
+			if isAuthFileName(d.Name()) && d.Type().IsRegular() {
+				found = path
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if found != "" {
+			break
+		}
+	}
+	return found
+}
+
+// dirAuthMiddleware enforces the auth files of the served tree. A request
+// needs basic auth credentials listed in the auth file nearest to the
+// requested path; when the path is a symlink, the target's nearest auth
+// file must also allow them. Auth files themselves are never served.
+func (s *Server) dirAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Partial uploads are addressed by an ID only an authorized
+		// request could have created
+		if strings.HasPrefix(r.URL.Path, resumablePath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		urlPath := filepath.Clean("/" + r.URL.Path)
+		if isAuthFileName(filepath.Base(urlPath)) {
+			http.NotFound(w, r)
+			return
+		}
+
+		filePath, _ := s.resolvePath(urlPath)
+		files := []string{s.authFileFor(filePath)}
+		if real, err := filepath.EvalSymlinks(filePath); err == nil && real != filePath {
+			if s.mountRoot(real) != "" {
+				files = append(files, s.authFileFor(real))
+			}
+		}
+
+		for _, path := range files {
+			if path == "" {
+				continue
+			}
+			if !s.authorized(w, r, path) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the request's credentials against the auth file at
+// path, answering 401 when they don't match. An auth file that can't be
+// read locks everyone out rather than leaving the directory open, as does
+// one added while the server has a password: browsers send one set of
+// credentials, which the password already takes.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request, path string) bool {
+	if s.basicAuthPass != "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	info, err := os.Stat(path)
+	var f *authFile
+	if err == nil {
+		f, err = s.authFiles.load(path, info)
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if ok && f.allows(user, pass) {
+		return true
+	}
+
+	// A realm per protected directory keeps browsers from sending one
+	// directory's credentials to another
+	realm := "/"
+	if urlPath, ok := s.urlPathOf(filepath.Dir(path)); ok {
+		realm = urlPath
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", "qrlocal "+realm))
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthFileWithPassword(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"public.txt":              "public",
+		"private/" + AuthFileName: "alice:secret\n",
+		"private/notes.txt":       "private",
+	})
+
+	_, err := New(Config{Directory: dir, BasicAuthPass: "hunter2"})
+	if err == nil || !strings.Contains(err.Error(), AuthFileName) {
+		t.Fatalf("New = %v, want an error naming the auth file", err)
+	}
+
+	// An auth file added while running locks its directory
+	if err := os.Remove(filepath.Join(dir, "private", AuthFileName)); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, Config{Directory: dir, BasicAuthPass: "hunter2"})
+	writeFiles(t, dir, map[string]string{"private/" + AuthFileName: "alice:hunter2\n"})
+
+	for target, want := range map[string]int{
+		"/public.txt":        http.StatusOK,
+		"/private/notes.txt": http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("alice", "hunter2")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET %s = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestAuthFileNeverServed(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"private/" + AuthFileName: "alice:secret\n",
+		"private/notes.txt":       "private",
+	})
+	authPath := filepath.Join(dir, "private", AuthFileName)
+	if err := os.Link(authPath, filepath.Join(dir, "private", "hardlink.txt")); err != nil {
+		t.Skipf("no hard links: %v", err)
+	}
+	if err := os.Symlink(authPath, filepath.Join(dir, "symlink.txt")); err != nil {
+		t.Skipf("no symlinks: %v", err)
+	}
+	s := newTestServer(t, Config{Directory: dir, ShowListing: true})
+
+	request := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("alice", "secret")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, target := range []string{
+		"/private/" + AuthFileName,
+		"/private/.QRLOCAL-AUTH",
+		"/private/.qrlocal-auth.",
+		"/private/.qrlocal-auth%20",
+		"/private/hardlink.txt",
+		"/symlink.txt",
+	} {
+		if rec := request(target); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, rec.Code)
+		}
+	}
+	if rec := request("/private/notes.txt"); rec.Code != http.StatusOK {
+		t.Errorf("GET /private/notes.txt = %d, want 200", rec.Code)
+	}
+
+	for _, target := range []string{"/?format=json", "/private/?format=json"} {
+		rec := request(target)
+		if strings.Contains(rec.Body.String(), "hardlink.txt") || strings.Contains(rec.Body.String(), "symlink.txt") {
+			t.Errorf("listing %s shows another name for the auth file: %s", target, rec.Body)
+		}
+	}
+
+	if _, err := New(Config{Directory: dir, IndexFiles: []string{".QRLOCAL-AUTH"}}); err == nil {
+		t.Error("New accepted an auth file as the index file")
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// downloadCounter counts complete downloads of each file, keyed by the
+// file's absolute path.
+type downloadCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func newDownloadCounter(initial map[string]uint64) *downloadCounter {
+	counts := make(map[string]uint64, len(initial))
+	for path, n := range initial {
+		counts[path] = n
+	}
+	return &downloadCounter{counts: counts}
+}
+
+func (c *downloadCounter) add(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[path]++
+}
+
+func (c *downloadCounter) get(path string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[path]
+}
+
+// snapshot returns a copy of the counts.
+func (c *downloadCounter) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]uint64, len(c.counts))
+	for path, n := range c.counts {
+		counts[path] = n
+	}
+	return counts
+}
+
+// DownloadCounts returns how many times each file has been downloaded in
+// full, keyed by absolute path, including the counts the server started
+// with from Config.DownloadCounts.
+func (s *Server) DownloadCounts() map[string]uint64 {
+	return s.downloads.snapshot()
+}
+
+// serveCountedFile serves a file and counts it as downloaded when it was
+// sent in full: a GET without a Range header answered with 200, so HEAD
+// requests, resumed downloads and cache revalidations don't count.
+func (s *Server) serveCountedFile(w http.ResponseWriter, r *http.Request, filePath string, info fs.FileInfo) {
+	if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+		s.serveFile(w, r, filePath, info)
+		return
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.serveFile(sw, r, filePath, info)
+	if sw.status == http.StatusOK {
+		s.downloads.add(filePath)
+	}
+}
+
+// serveDownloadStats answers ?stats=json on a directory with the download
+// counts of the visible files under it, keyed by URL path. Files behind a
+// different auth file than the directory are left out.
+func (s *Server) serveDownloadStats(w http.ResponseWriter, dirPath string) {
+	stats := make(map[string]uint64)
+	dirAuth := s.authFileFor(dirPath)
+	for path, n := range s.downloads.snapshot() {
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if s.authFileFor(filepath.Dir(path)) != dirAuth {
+			continue
+		}
+		urlPath, ok := s.urlPathOf(path)
+		if !ok {
+			continue
+		}
+		if s.excluded(urlPath) || hiddenPath(urlPath) {
+			continue
+		}
+		stats[urlPath] = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, "Failed to encode download counts", http.StatusInternalServerError)
+	}
+}
+
+// hiddenPath reports whether any component of a URL path starts with a
+// dot, like the entries left out of listings.
+func hiddenPath(urlPath string) bool {
+	for _, part := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// ReadFrom keeps the underlying writer's sendfile support for io.Copy.
+func (w *statusWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(w.ResponseWriter, src)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// validatePatterns checks that every exclude pattern is a valid glob.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// excluded reports whether the URL path, relative to the served directory,
+// matches an exclude pattern or is ignored by the served .gitignore. A
+// path is also excluded when one of its parent directories is, so
+// excluding ".git" hides ".git/config". Auth files are always excluded.
+func (s *Server) excluded(urlPath string) bool {
+	rel := strings.Trim(filepath.ToSlash(urlPath), "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+	// The .gitignore file only covers the served directory, not mounts
+	if s.mountFor(rel).prefix == "/" && s.gitignored(rel) {
+		return true
+	}
+	parts := strings.Split(rel, "/")
+	if slices.ContainsFunc(parts, isAuthFileName) {
+		return true
+	}
+
+	for _, pattern := range s.exclude {
+		pattern = strings.Trim(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			// Match the full path and each parent directory
+			for i := len(parts); i > 0; i-- {
+				if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!pattern" re-includes a previously ignored path
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // Pattern contains a "/" and is matched from the root
+}
+
+// loadGitignore reads the .gitignore file at the root of dir. A missing
+// file yields no rules. The .git directory is always ignored.
+func loadGitignore(dir string) ([]ignoreRule, error) {
+	rules := []ignoreRule{{pattern: ".git", dirOnly: true}}
+
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	parsed, err := parseGitignore(f)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, parsed...), nil
+}
+
+// parseGitignore parses the subset of gitignore syntax qrlocal supports:
+// comments, blank lines, negation with "!", directory-only patterns ending
+// in "/", patterns anchored by a "/", and a leading "**/". Invalid globs
+// are skipped.
+func parseGitignore(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		switch {
+		case strings.HasPrefix(line, `\`):
+			line = line[1:] // Escaped leading "#" or "!"
+		case strings.HasPrefix(line, "!"):
+			rule.negate = true
+			line = line[1:]
+		}
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly = true
+			line = rest
+		}
+		if rest, ok := strings.CutPrefix(line, "**/"); ok {
+			line = rest
+		} else if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// matches reports whether the rule applies to the slash-separated path
+// rel, relative to the served directory.
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		ok, _ := path.Match(r.pattern, rel)
+		return ok
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
+
+// gitignored reports whether rel is ignored by the .gitignore rules. As in
+// git, the last matching rule wins, and a path inside an ignored directory
+// can't be re-included.
+func (s *Server) gitignored(rel string) bool {
+	if len(s.gitignore) == 0 {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		prefix := strings.Join(parts[:i], "/")
+		isDir := i < len(parts)
+		if !isDir {
+			info, err := os.Stat(filepath.Join(s.directory, filepath.FromSlash(prefix)))
+			isDir = err == nil && info.IsDir()
+		}
+
+		ignored := false
+		for _, rule := range s.gitignore {
+			if rule.matches(prefix, isDir) {
+				ignored = !rule.negate
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
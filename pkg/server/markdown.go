@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdownIndexNames are the files rendered as a directory's landing page
+// with Config.RenderMarkdown, in order of preference.
+var markdownIndexNames = []string{"index.md", "README.md", "readme.md", "Readme.md"}
+
+// maxMarkdownSize is the largest markdown file rendered; bigger ones fall
+// back to the listing.
+const maxMarkdownSize = 4 << 20
+
+// markdown converts GitHub-flavored markdown. Raw HTML in the source is
+// left out rather than passed through to visitors.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// markdownData is the data passed to the markdown page template.
+type markdownData struct {
+	Title   string        // Base name of the directory
+	Path    string        // URL path of the directory
+	File    string        // Name of the rendered markdown file
+	Content template.HTML // Rendered markdown
+	Listing bool          // Whether the file listing can be browsed
+}
+
+// serveMarkdownIndex renders dirPath's index.md or README.md as its landing
+// page. It reports false, having written nothing, when the directory has
+// no markdown index, so the caller can fall back to the listing.
+func (s *Server) serveMarkdownIndex(w http.ResponseWriter, r *http.Request, dirPath, urlPath string) bool {
+	var name string
+	var source []byte
+	for _, candidate := range markdownIndexNames {
+		if s.excluded(path.Join(filepath.ToSlash(urlPath), candidate)) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dirPath, candidate))
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxMarkdownSize {
+			continue
+		}
+		if source, err = os.ReadFile(filepath.Join(dirPath, candidate)); err == nil {
+			name = candidate
+			break
+		}
+	}
+	if name == "" {
+		return false
+	}
+
+	// Relative links and images in the markdown need the trailing slash
+	if r.URL.Path[len(r.URL.Path)-1] != '/' {
+		target := r.URL.Path + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return true
+	}
+
+	var rendered bytes.Buffer
+	if err := markdown.Convert(source, &rendered); err != nil {
+		return false
+	}
+
+	data := markdownData{
+		Title:   filepath.Base(dirPath),
+		Path:    urlPath,
+		File:    name,
+		Content: template.HTML(rendered.String()),
+		Listing: s.showListing,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := markdownTemplate.Execute(w, data); err != nil {
+		http.Error(w, "Failed to render "+name, http.StatusInternalServerError)
+	}
+	return true
+}
+
+// wantsListing reports whether a directory request asks for the file
+// listing rather than the rendered markdown index.
+func wantsListing(r *http.Request) bool {
+	query := r.URL.Query()
+	return wantsJSON(r) || query.Has("listing") || query.Has("since")
+}
+
+// Markdown page HTML template, styled like the directory listing
+var markdownTemplate = template.Must(template.New("markdown").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>{{.Title}} - qrlocal</title>
+    <style>
+        * {
+            box-sizing: border-box;
+        }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            background: #f5f5f5;
+            margin: 0;
+            padding: 20px;
+        }
+        .container {
+            max-width: 900px;
+            margin: 0 auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            overflow: hidden;
+        }
+        header {
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            color: white;
+            padding: 20px 24px;
+        }
+        header h1 {
+            font-size: 1.5rem;
+            font-weight: 600;
+            margin: 0;
+        }
+        header .path {
+            font-size: 0.9rem;
+            opacity: 0.9;
+            margin-top: 4px;
+            word-break: break-all;
+        }
+        header .path a {
+            color: white;
+        }
+        article {
+            padding: 8px 24px 24px;
+            overflow-wrap: break-word;
+        }
+        article a {
+            color: #667eea;
+        }
+        article img {
+            max-width: 100%;
+        }
+        article pre {
+            background: #f6f8fa;
+            border-radius: 6px;
+            padding: 12px 16px;
+            overflow-x: auto;
+        }
+        article code {
+            font-family: SFMono-Regular, Consolas, "Liberation Mono", Menlo, monospace;
+            font-size: 0.9em;
+        }
+        article :not(pre) > code {
+            background: #f6f8fa;
+            border-radius: 4px;
+            padding: 0.1em 0.3em;
+        }
+        article blockquote {
+            margin-left: 0;
+            padding-left: 16px;
+            border-left: 4px solid #ddd;
+            color: #666;
+        }
+        article table {
+            border-collapse: collapse;
+        }
+        article th, article td {
+            border: 1px solid #ddd;
+            padding: 6px 12px;
+        }
+        footer {
+            padding: 16px 24px;
+            background: #f9f9f9;
+            color: #888;
+            font-size: 0.85rem;
+            text-align: center;
+        }
+        footer a {
+            color: #667eea;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <header>
+            <h1>{{.Title}}</h1>
+            <div class="path">{{.Path}} &middot; {{.File}}{{if .Listing}} &middot; <a href="?listing">Browse files</a>{{end}}</div>
+        </header>
+        <article>
+            {{.Content}}
+        </article>
+        <footer>
+            Served by <a href="https://github.com/dendysatrya/qrlocal">qrlocal</a>
+        </footer>
+    </div>
+</body>
+</html>
+`))
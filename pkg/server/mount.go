@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mount is a directory served under a URL path prefix.
+type mount struct {
+	prefix string // Cleaned URL path, "/" for the served directory
+	dir    string // Absolute path of the directory on disk
+	info   fs.FileInfo
+}
+
+// newMounts resolves the Config.Mounts directories and returns them with
+// the served directory mounted at "/", longest prefix first.
+func newMounts(root string, info fs.FileInfo, dirs map[string]string) ([]mount, error) {
+	mounts := []mount{{prefix: "/", dir: root, info: info}}
+	for prefix, dir := range dirs {
+		cleaned := path.Clean("/" + strings.Trim(prefix, "/"))
+		if cleaned == "/" {
+			return nil, fmt.Errorf("invalid mount path %q: use a URL path like /docs", prefix)
+		}
+		for _, m := range mounts {
+			if m.prefix == cleaned {
+				return nil, fmt.Errorf("%s is mounted twice", cleaned)
+			}
+		}
+		absDir, info, err := resolveDirectory(dir)
+		if err != nil {
+			return nil, fmt.Errorf("mount %s: %w", cleaned, err)
+		}
+		mounts = append(mounts, mount{prefix: cleaned, dir: absDir, info: info})
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+	return mounts, nil
+}
+
+// contains reports whether the URL path is the mount point or below it.
+func (m mount) contains(urlPath string) bool {
+	return m.prefix == "/" || urlPath == m.prefix || strings.HasPrefix(urlPath, m.prefix+"/")
+}
+
+// mountFor returns the mount with the longest prefix containing the URL
+// path, which may be given without its leading slash.
+func (s *Server) mountFor(urlPath string) mount {
+	urlPath = path.Clean("/" + filepath.ToSlash(urlPath))
+	for _, m := range s.mounts {
+		if m.contains(urlPath) {
+			return m
+		}
+	}
+	return s.mounts[len(s.mounts)-1]
+}
+
+// resolvePath returns the file on disk for a cleaned URL path and whether
+// it lies within the directory of the mount it was found in.
+func (s *Server) resolvePath(urlPath string) (string, bool) {
+	m := s.mountFor(urlPath)
+	rel := strings.TrimPrefix(filepath.ToSlash(urlPath), m.prefix)
+	filePath := filepath.Join(m.dir, filepath.FromSlash(rel))
+	return filePath, within(m.dir, filePath)
+}
+
+// mountRoot returns the directory of the mount that filePath is in,
+// preferring the innermost one when mounts are nested on disk, or "" when
+// it is in none of them.
+func (s *Server) mountRoot(filePath string) string {
+	root := ""
+	for _, m := range s.mounts {
+		if within(m.dir, filePath) && len(m.dir) > len(root) {
+			root = m.dir
+		}
+	}
+	return root
+}
+
+// urlPathOf returns the URL path a file on disk is served at, or false
+// when it is outside every mount.
+func (s *Server) urlPathOf(filePath string) (string, bool) {
+	var best *mount
+	for i, m := range s.mounts {
+		if within(m.dir, filePath) && (best == nil || len(m.dir) > len(best.dir)) {
+			best = &s.mounts[i]
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(best.dir, filePath)
+	if err != nil {
+		return "", false
+	}
+	return path.Join(best.prefix, filepath.ToSlash(rel)), true
+}
+
+// mountEntries returns the mount points directly inside the directory at
+// urlPath, which are listed alongside its files.
+func (s *Server) mountEntries(urlPath string) []fs.DirEntry {
+	var entries []fs.DirEntry
+	dir := path.Clean("/" + filepath.ToSlash(urlPath))
+	for _, m := range s.mounts {
+		if m.prefix != "/" && path.Dir(m.prefix) == dir {
+			entries = append(entries, mountEntry{fs.FileInfoToDirEntry(m.info), path.Base(m.prefix)})
+		}
+	}
+	return entries
+}
+
+// mountEntry is a mounted directory as listed in its parent, under the
+// name of its mount point.
+type mountEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e mountEntry) Name() string { return e.name }
+
+// within reports whether path is dir or inside it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// AuthProxy is a reverse proxy in front of a local service, optionally
+// password protected. It passes WebSocket upgrades through once a request
+// has authenticated, so dev servers with hot reload keep working.
+type AuthProxy struct {
+	server   *http.Server
+	listener net.Listener
+	port     int
+	done     chan struct{}
+	requests requestCounter
+	bytes    byteCounter
+}
+
+// ProxyConfig holds the reverse proxy configuration.
+type ProxyConfig struct {
+	TargetHost string
+	TargetPort int
+
+	// TargetScheme is "https" for services that speak TLS, whose
+	// certificates aren't checked since dev servers use self-signed ones.
+	// Empty means "http".
+	TargetScheme string
+
+	// Password, when set, is required as the basic auth password. It is
+	// not forwarded to the service.
+	Password string
+
+	// InjectHeaders are set on every request forwarded to the service,
+	// replacing any the client sent, e.g. "X-Forwarded-Proto: https" for
+	// apps that build absolute URLs from it. Responses are left alone.
+	InjectHeaders http.Header
+
+	// ListenHost is the address the proxy listens on; empty means all
+	// interfaces. A proxy that only a tunnel connects to should listen
+	// on loopback, so it can't be reached around the tunnel.
+	ListenHost string
+}
+
+// NewAuthProxy creates a proxy on a free port of cfg.ListenHost that
+// forwards requests to the configured target, checking the password and
+// adding the injected headers. It needs a password or headers to inject.
+func NewAuthProxy(cfg ProxyConfig) (*AuthProxy, error) {
+	if cfg.Password == "" && len(cfg.InjectHeaders) == 0 {
+		return nil, fmt.Errorf("a password or headers to inject are required")
+	}
+	scheme := cfg.TargetScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	target := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(cfg.TargetHost, strconv.Itoa(cfg.TargetPort)),
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.ListenHost, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for proxy: %w", err)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			// The password is for the proxy, not the service behind it
+			if cfg.Password != "" {
+				r.Out.Header.Del("Authorization")
+			}
+			// Injected last, so they override the X-Forwarded headers
+			for name, values := range cfg.InjectHeaders {
+				r.Out.Header.Del(name)
+				for _, value := range values {
+					r.Out.Header.Add(name, value)
+				}
+			}
+		},
+		// Stream responses such as server-sent events without buffering
+		FlushInterval: -1,
+	}
+	if scheme == "https" {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		proxy.Transport = transport
+	}
+
+	p := &AuthProxy{
+		port: listener.Addr().(*net.TCPAddr).Port,
+		done: make(chan struct{}),
+	}
+	p.listener = p.bytes.listener(listener)
+
+	var handler http.Handler = proxy
+	if cfg.Password != "" {
+		handler = basicAuth(cfg.Password, handler)
+	}
+
+	// Upgraded WebSocket connections are hijacked and long-lived, so only
+	// the request headers are given a deadline
+	p.server = &http.Server{
+		Handler:           p.requests.middleware(handler),
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	return p, nil
+}
+
+// Port returns the port the proxy is listening on.
+func (p *AuthProxy) Port() int {
+	return p.port
+}
+
+// Start starts proxying requests.
+func (p *AuthProxy) Start() {
+	go func() {
+		if err := p.server.Serve(p.listener); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "Proxy server error: %v\n", err)
+		}
+		close(p.done)
+	}()
+}
+
+// Stop gracefully stops the proxy. Hijacked WebSocket connections are not
+// tracked by the server, so they end when the process exits.
+func (p *AuthProxy) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("proxy server shutdown error: %w", err)
+	}
+	<-p.done
+	return nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoWebSocket accepts WebSocket upgrades and then echoes every byte it
+// receives, standing in for a dev server's hot reload socket.
+func echoWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Authorization") != "" {
+		http.Error(w, "the proxy forwarded its password", http.StatusBadRequest)
+		return
+	}
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	buf.Flush()
+	io.Copy(conn, buf)
+}
+
+// dialUpgrade sends a WebSocket upgrade request through the proxy and
+// returns the connection and the response.
+func dialUpgrade(t *testing.T, port int, password string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+	if password != "" {
+		request += "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("user:"+password)) + "\r\n"
+	}
+	if _, err := io.WriteString(conn, request+"\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	return conn, reader, resp
+}
+
+func TestAuthProxyWebSocketUpgrade(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(echoWebSocket))
+	defer backend.Close()
+	backendAddr := backend.Listener.Addr().(*net.TCPAddr)
+
+	proxy, err := NewAuthProxy(ProxyConfig{
+		TargetHost: "127.0.0.1",
+		TargetPort: backendAddr.Port,
+		Password:   "hunter2",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProxy: %v", err)
+	}
+	proxy.Start()
+	defer proxy.Stop()
+
+	t.Run("without credentials", func(t *testing.T) {
+		_, _, resp := dialUpgrade(t, proxy.Port(), "")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, _, resp := dialUpgrade(t, proxy.Port(), "wrong")
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", resp.StatusCode)
+		}
+	})
+
+	t.Run("with credentials", func(t *testing.T) {
+		conn, reader, resp := dialUpgrade(t, proxy.Port(), "hunter2")
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			body, _ := io.ReadAll(resp.Body)
+			t.Fatalf("status = %d (%s), want 101", resp.StatusCode, body)
+		}
+		if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+			t.Errorf("Upgrade = %q, want websocket", resp.Header.Get("Upgrade"))
+		}
+
+		// The upgraded connection is passed through both ways
+		if _, err := io.WriteString(conn, "ping\n"); err != nil {
+			t.Fatal(err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading echo: %v", err)
+		}
+		if line != "ping\n" {
+			t.Errorf("echo = %q, want %q", line, "ping\n")
+		}
+	})
+}
+
+func TestAuthProxyListenHost(t *testing.T) {
+	proxy, err := NewAuthProxy(ProxyConfig{
+		TargetHost:    "127.0.0.1",
+		TargetPort:    1,
+		InjectHeaders: http.Header{"X-Forwarded-Proto": {"https"}},
+		ListenHost:    "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProxy: %v", err)
+	}
+	proxy.Start()
+	defer proxy.Stop()
+
+	if ip := proxy.listener.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+		t.Errorf("proxy listens on %s, want loopback only", ip)
+	}
+}
+
+func TestAuthProxyTLSTarget(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "tls=%t", r.TLS != nil)
+	}))
+	defer backend.Close()
+	backendAddr := backend.Listener.Addr().(*net.TCPAddr)
+
+	proxy, err := NewAuthProxy(ProxyConfig{
+		TargetHost:   "127.0.0.1",
+		TargetPort:   backendAddr.Port,
+		TargetScheme: "https",
+		Password:     "hunter2",
+	})
+	if err != nil {
+		t.Fatalf("NewAuthProxy: %v", err)
+	}
+	proxy.Start()
+	defer proxy.Stop()
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/", proxy.Port()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("user", "hunter2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "tls=true" {
+		t.Errorf("got %d %q, want 200 \"tls=true\"", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redirector is a tiny HTTP server that maps short words to full URLs,
+// so a share can be typed by hand as http://<ip>/<word>.
+type Redirector struct {
+	server   *http.Server
+	listener net.Listener
+	port     int
+	done     chan struct{}
+
+	mu      sync.RWMutex
+	aliases map[string]string
+}
+
+// aliasWordRegex restricts alias words to short, URL-safe names.
+var aliasWordRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// aliasWords are easy-to-type words used for random aliases.
+var aliasWords = []string{
+	"apple", "beach", "cloud", "delta", "eagle", "frost", "grape", "honey",
+	"igloo", "jolly", "koala", "lemon", "mango", "noble", "ocean", "piano",
+	"quilt", "river", "sunny", "tiger", "ultra", "vivid", "whale", "zebra",
+}
+
+// RandomAliasWord returns a random easy-to-type alias word.
+func RandomAliasWord() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(aliasWords))))
+	if err != nil {
+		return aliasWords[0]
+	}
+	return aliasWords[n.Int64()]
+}
+
+// NewRedirector creates a redirect server. If port is 0 it tries port 80,
+// which keeps short links shortest, and falls back to any free port.
+func NewRedirector(port int) (*Redirector, error) {
+	var listener net.Listener
+	var err error
+	if port == 0 {
+		listener, err = net.Listen("tcp", ":80")
+		if err != nil {
+			listener, err = net.Listen("tcp", ":0")
+		}
+	} else {
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for redirects: %w", err)
+	}
+
+	r := &Redirector{
+		listener: listener,
+		port:     listener.Addr().(*net.TCPAddr).Port,
+		done:     make(chan struct{}),
+		aliases:  make(map[string]string),
+	}
+
+	r.server = &http.Server{
+		Handler:      http.HandlerFunc(r.handle),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	return r, nil
+}
+
+// Add maps word to target. The word must be lowercase letters, digits or '-'.
+func (r *Redirector) Add(word, target string) error {
+	word = strings.ToLower(word)
+	if !aliasWordRegex.MatchString(word) {
+		return fmt.Errorf("invalid alias %q: use lowercase letters, digits and '-'", word)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases[word] = target
+	return nil
+}
+
+// Port returns the port the redirector is listening on.
+func (r *Redirector) Port() int {
+	return r.port
+}
+
+// Start starts serving redirects.
+func (r *Redirector) Start() {
+	go func() {
+		if err := r.server.Serve(r.listener); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "Redirect server error: %v\n", err)
+		}
+		close(r.done)
+	}()
+}
+
+// Stop gracefully stops the redirector.
+func (r *Redirector) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("redirect server shutdown error: %w", err)
+	}
+	<-r.done
+	return nil
+}
+
+// handle redirects /<word> to its target and lists aliases at /.
+func (r *Redirector) handle(w http.ResponseWriter, req *http.Request) {
+	word := strings.ToLower(strings.Trim(req.URL.Path, "/"))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if word == "" {
+		type alias struct{ Word, Target string }
+		list := make([]alias, 0, len(r.aliases))
+		for w, t := range r.aliases {
+			list = append(list, alias{w, t})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Word < list[j].Word })
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		aliasTemplate.Execute(w, list)
+		return
+	}
+
+	target, ok := r.aliases[word]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	http.Redirect(w, req, target, http.StatusFound)
+}
+
+// aliasTemplate lists the active short links.
+var aliasTemplate = template.Must(template.New("aliases").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>qrlocal short links</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f5f5f5; padding: 20px; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; background: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); padding: 24px; }
+        h1 { font-size: 1.3rem; margin-bottom: 16px; }
+        li { margin: 8px 0; word-break: break-all; }
+        a { color: #667eea; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>🔗 Short links</h1>
+        <ul>
+            {{range .}}<li><strong>/{{.Word}}</strong> → <a href="{{.Target}}">{{.Target}}</a></li>{{end}}
+        </ul>
+    </div>
+</body>
+</html>
+`))
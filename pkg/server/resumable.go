@@ -0,0 +1,372 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// resumablePath is where partial uploads are addressed, followed by
+	// their ID.
+	resumablePath = "/.qrlocal/uploads/"
+
+	// tusVersion is the version of the tus protocol subset spoken here:
+	// the core protocol and the creation extension.
+	tusVersion = "1.0.0"
+
+	// offsetContentType is the content type of PATCH bodies.
+	offsetContentType = "application/offset+octet-stream"
+
+	// resumableTTL is how long an unfinished upload can sit idle before
+	// it is discarded.
+	resumableTTL = time.Hour
+
+	// maxPartialUploads is how many unfinished uploads can be in progress
+	// at once.
+	maxPartialUploads = 16
+
+	// maxPartialBytes caps the total Upload-Length of unfinished uploads,
+	// so clients can't claim more of the temp area than a few full-size
+	// uploads need.
+	maxPartialBytes = 4 * MaxUploadSize
+)
+
+var (
+	errTooManyUploads = errors.New("too many uploads in progress")
+	errUploadSpace    = errors.New("not enough space for the upload")
+)
+
+// partialUpload is an upload in progress, stored in the temp area until
+// all of its bytes have arrived.
+type partialUpload struct {
+	mu      sync.Mutex // Serializes PATCH requests
+	dir     string     // Directory the file goes into when complete
+	name    string
+	length  int64
+	offset  int64
+	path    string // Partial data in the temp area
+	touched time.Time
+}
+
+// resumableUploads tracks partial uploads by ID.
+type resumableUploads struct {
+	mu      sync.Mutex
+	tempDir string // Created on first use
+	uploads map[string]*partialUpload
+	sweeper *time.Timer // Discards idle uploads while there are any
+}
+
+// createResumable starts a resumable upload into dirPath. The file size is
+// given in the Upload-Length header and its name in Upload-Metadata, as
+// tus clients send them. The new upload's URL is returned in Location.
+func (s *Server) createResumable(w http.ResponseWriter, r *http.Request, dirPath string) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	info, err := os.Stat(dirPath)
+	if err != nil || !info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "Upload-Length is missing or invalid", http.StatusBadRequest)
+		return
+	}
+	if length > MaxUploadSize {
+		http.Error(w, "Upload is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	fileName := uploadMetadata(r.Header.Get("Upload-Metadata"))["filename"]
+	name := filepath.Base(filepath.Clean("/" + filepath.ToSlash(fileName)))
+	if name == "/" || name == "." || strings.HasPrefix(name, ".") {
+		http.Error(w, fmt.Sprintf("invalid file name %q", fileName), http.StatusBadRequest)
+		return
+	}
+	if urlPath, ok := s.urlPathOf(filepath.Join(dirPath, name)); !ok || s.excluded(urlPath) || isAuthFile(filepath.Join(dirPath, name)) {
+		http.Error(w, name+" is excluded", http.StatusForbidden)
+		return
+	}
+	if _, err := os.Lstat(filepath.Join(dirPath, name)); err == nil {
+		http.Error(w, name+" already exists", http.StatusConflict)
+		return
+	}
+
+	id, err := newSessionToken()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	upload := &partialUpload{dir: dirPath, name: name, length: length, touched: time.Now()}
+	if err := s.resumable.add(id, upload); err != nil {
+		switch {
+		case errors.Is(err, errTooManyUploads):
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "Too many uploads in progress; try again later", http.StatusServiceUnavailable)
+		case errors.Is(err, errUploadSpace):
+			http.Error(w, "Not enough space for the upload; try again later", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Failed to start upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// An empty file is complete as soon as it is created
+	if length == 0 {
+		if err := s.finishResumable(id, upload); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Location", resumablePath+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleResumable serves HEAD, to learn how much of an upload has arrived,
+// and PATCH, to append to it, for the upload named by the URL.
+func (s *Server) handleResumable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation")
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(MaxUploadSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, resumablePath)
+	upload := s.resumable.get(id)
+	if upload == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	// The upload may have finished while this request waited
+	if s.resumable.get(id) != upload {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.length, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.appendResumable(w, r, id, upload)
+	default:
+		w.Header().Set("Allow", "HEAD, PATCH, OPTIONS")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// appendResumable writes a PATCH body at the upload's offset. A request
+// cut off midway keeps the bytes that arrived, so the client can resume
+// from the new offset. The caller holds upload.mu.
+func (s *Server) appendResumable(w http.ResponseWriter, r *http.Request, id string, upload *partialUpload) {
+	if r.Header.Get("Content-Type") != offsetContentType {
+		http.Error(w, "Content-Type must be "+offsetContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Offset is missing or invalid", http.StatusBadRequest)
+		return
+	}
+	if offset != upload.offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+		http.Error(w, "Upload-Offset doesn't match the upload", http.StatusConflict)
+		return
+	}
+
+	f, err := os.OpenFile(upload.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		http.Error(w, "Failed to save upload", http.StatusInternalServerError)
+		return
+	}
+	n, copyErr := io.Copy(f, io.LimitReader(uploadDeadline(w, r.Body), upload.length-upload.offset))
+	closeErr := f.Close()
+	upload.offset += n
+	upload.touched = time.Now()
+
+	if closeErr != nil {
+		http.Error(w, "Failed to save upload", http.StatusInternalServerError)
+		return
+	}
+	if upload.offset == upload.length {
+		if err := s.finishResumable(id, upload); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	} else if copyErr != nil {
+		// The client is most likely gone; it will ask for the offset
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finishResumable moves a complete upload into its directory, with the
+// same checks as a regular upload, and forgets it.
+func (s *Server) finishResumable(id string, upload *partialUpload) error {
+	defer s.resumable.remove(id)
+
+	f, err := os.Open(upload.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.saveUpload(upload.dir, upload.name, f)
+}
+
+// writeUploadError reports an error from saveUpload.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var rejected *errRejectedType
+	switch {
+	case errors.As(err, &rejected):
+		http.Error(w, "Unsupported Media Type: "+rejected.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, os.ErrPermission):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, os.ErrExist):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to save upload", http.StatusInternalServerError)
+	}
+}
+
+// uploadMetadata parses an Upload-Metadata header: comma-separated keys,
+// each followed by a space and its base64-encoded value.
+func uploadMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if key == "" || err != nil {
+			continue
+		}
+		meta[key] = string(value)
+	}
+	return meta
+}
+
+// add registers a new upload and creates its empty partial file. Uploads
+// idle for longer than resumableTTL are discarded first; the new one is
+// refused if maxPartialUploads are still in progress or it would take
+// the total over maxPartialBytes.
+func (u *resumableUploads) add(id string, upload *partialUpload) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.tempDir == "" {
+		dir, err := os.MkdirTemp("", "qrlocal-uploads-*")
+		if err != nil {
+			return err
+		}
+		u.tempDir = dir
+		u.uploads = make(map[string]*partialUpload)
+	}
+
+	u.expire()
+	if len(u.uploads) >= maxPartialUploads {
+		return errTooManyUploads
+	}
+	reserved := upload.length
+	for _, other := range u.uploads {
+		reserved += other.length
+	}
+	if reserved > maxPartialBytes {
+		return errUploadSpace
+	}
+
+	upload.path = filepath.Join(u.tempDir, path.Base(id))
+	f, err := os.OpenFile(upload.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	u.uploads[id] = upload
+	if u.sweeper == nil {
+		u.sweeper = time.AfterFunc(resumableTTL, u.sweep)
+	}
+	return nil
+}
+
+// expire discards uploads that have been idle for longer than
+// resumableTTL. Uploads a request is busy with are left alone. The caller
+// holds u.mu.
+func (u *resumableUploads) expire() {
+	for id, upload := range u.uploads {
+		if !upload.mu.TryLock() {
+			continue
+		}
+		if time.Since(upload.touched) > resumableTTL {
+			os.Remove(upload.path)
+			delete(u.uploads, id)
+		}
+		upload.mu.Unlock()
+	}
+}
+
+// sweep expires idle uploads, and runs again later while any are left,
+// so abandoned uploads don't hold on to the temp area until the next one
+// starts.
+func (u *resumableUploads) sweep() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.expire()
+	if len(u.uploads) > 0 {
+		u.sweeper.Reset(resumableTTL)
+	} else {
+		u.sweeper = nil
+	}
+}
+
+// get returns the upload with the given ID, or nil.
+func (u *resumableUploads) get(id string) *partialUpload {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.uploads[id]
+}
+
+// remove forgets an upload and deletes its partial data.
+func (u *resumableUploads) remove(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if upload, ok := u.uploads[id]; ok {
+		os.Remove(upload.path)
+		delete(u.uploads, id)
+	}
+}
+
+// cleanup deletes all partial uploads.
+func (u *resumableUploads) cleanup() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.sweeper != nil {
+		u.sweeper.Stop()
+		u.sweeper = nil
+	}
+	if u.tempDir != "" {
+		os.RemoveAll(u.tempDir)
+		u.tempDir = ""
+		u.uploads = nil
+	}
+}
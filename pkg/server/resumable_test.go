@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// createUpload starts a resumable upload of a file with the given name and
+// length in the server's root directory.
+func createUpload(s *Server, name string, length int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Upload-Length", strconv.FormatInt(length, 10))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(name)))
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestResumableLimits(t *testing.T) {
+	t.Run("too large", func(t *testing.T) {
+		s := newTestServer(t, Config{Directory: t.TempDir(), EnableUpload: true})
+		defer s.resumable.cleanup()
+		if code := createUpload(s, "big.bin", MaxUploadSize+1).Code; code != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d, want 413", code)
+		}
+	})
+
+	t.Run("too many in progress", func(t *testing.T) {
+		s := newTestServer(t, Config{Directory: t.TempDir(), EnableUpload: true})
+		defer s.resumable.cleanup()
+		for i := range maxPartialUploads {
+			if code := createUpload(s, fmt.Sprintf("file%d.txt", i), 10).Code; code != http.StatusCreated {
+				t.Fatalf("upload %d: status = %d, want 201", i+1, code)
+			}
+		}
+		rec := createUpload(s, "one-more.txt", 10)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503", rec.Code)
+		}
+		if rec.Header().Get("Retry-After") == "" {
+			t.Error("no Retry-After header")
+		}
+	})
+
+	t.Run("total size", func(t *testing.T) {
+		s := newTestServer(t, Config{Directory: t.TempDir(), EnableUpload: true})
+		defer s.resumable.cleanup()
+		for i := range maxPartialBytes / MaxUploadSize {
+			if code := createUpload(s, fmt.Sprintf("big%d.bin", i), MaxUploadSize).Code; code != http.StatusCreated {
+				t.Fatalf("upload %d: status = %d, want 201", i+1, code)
+			}
+		}
+		if code := createUpload(s, "small.txt", 1).Code; code != http.StatusInsufficientStorage {
+			t.Errorf("status = %d, want 507", code)
+		}
+	})
+}
+
+func TestResumableExpiry(t *testing.T) {
+	s := newTestServer(t, Config{Directory: t.TempDir(), EnableUpload: true})
+	defer s.resumable.cleanup()
+
+	idle := createUpload(s, "idle.txt", 10)
+	active := createUpload(s, "active.txt", 10)
+	if idle.Code != http.StatusCreated || active.Code != http.StatusCreated {
+		t.Fatalf("status = %d, %d, want 201", idle.Code, active.Code)
+	}
+	idleID := strings.TrimPrefix(idle.Header().Get("Location"), resumablePath)
+	activeID := strings.TrimPrefix(active.Header().Get("Location"), resumablePath)
+	if s.resumable.sweeper == nil {
+		t.Fatal("no sweep scheduled for the unfinished uploads")
+	}
+
+	upload := s.resumable.get(idleID)
+	upload.touched = time.Now().Add(-resumableTTL - time.Minute)
+	s.resumable.sweep()
+
+	if s.resumable.get(idleID) != nil {
+		t.Error("idle upload wasn't discarded")
+	}
+	if _, err := os.Stat(upload.path); !os.IsNotExist(err) {
+		t.Errorf("idle upload's partial file still exists (%v)", err)
+	}
+	if s.resumable.get(activeID) == nil {
+		t.Error("active upload was discarded")
+	}
+
+	s.resumable.get(activeID).touched = time.Now().Add(-resumableTTL - time.Minute)
+	s.resumable.sweep()
+	if s.resumable.sweeper != nil {
+		t.Error("sweep still scheduled with no uploads left")
+	}
+}
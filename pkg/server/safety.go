@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sensitiveNames are files and directories that usually hold credentials
+// or private keys. Names ending in "*" match by prefix.
+var sensitiveNames = []string{
+	".ssh", ".gnupg", ".aws", ".azure", ".kube", ".docker",
+	".env*", ".netrc", ".git-credentials", ".npmrc", ".pypirc",
+	"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+}
+
+// ExposureRisks returns the reasons why sharing the served directory
+// publicly is probably a mistake: it or a mounted directory is a
+// filesystem root or contains the home directory, or holds credential
+// files that aren't excluded. It returns nil when nothing risky was found.
+func (s *Server) ExposureRisks() []string {
+	if s.content != nil {
+		return nil
+	}
+	var risks []string
+	for _, m := range s.mounts {
+		risks = append(risks, s.mountRisks(m)...)
+	}
+	return risks
+}
+
+// mountRisks returns the exposure risks of a single mounted directory.
+func (s *Server) mountRisks(m mount) []string {
+	var risks []string
+	dir := m.dir
+	if m.prefix != "/" {
+		dir = fmt.Sprintf("%s (mounted at %s)", m.dir, m.prefix)
+	}
+
+	if m.dir == filepath.VolumeName(m.dir)+string(filepath.Separator) {
+		risks = append(risks, fmt.Sprintf("%s is the root of the filesystem", dir))
+	} else if home, err := os.UserHomeDir(); err == nil {
+		if rel, err := filepath.Rel(m.dir, home); err == nil && rel == "." {
+			risks = append(risks, fmt.Sprintf("%s is your home directory", dir))
+		} else if err == nil && !strings.HasPrefix(rel, "..") {
+			risks = append(risks, fmt.Sprintf("%s contains your home directory", dir))
+		}
+	}
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return risks
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !sensitiveName(name) || s.excluded(path.Join(m.prefix, name)) {
+			continue
+		}
+		if m.prefix == "/" {
+			risks = append(risks, fmt.Sprintf("it contains %s, which may hold credentials", name))
+		} else {
+			risks = append(risks, fmt.Sprintf("%s contains %s, which may hold credentials", dir, name))
+		}
+	}
+	return risks
+}
+
+// sensitiveName reports whether name matches one of sensitiveNames.
+func sensitiveName(name string) bool {
+	for _, sensitive := range sensitiveNames {
+		if prefix, ok := strings.CutSuffix(sensitive, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == sensitive {
+			return true
+		}
+	}
+	return false
+}
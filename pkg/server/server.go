@@ -3,122 +3,490 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Server represents a built-in HTTP file server.
 type Server struct {
-	server        *http.Server
-	port          int
-	directory     string
-	listener      net.Listener
-	done          chan struct{}
-	uploadPath    string
-	spaMode       bool   // Serve index.html for all routes (SPA support)
-	showListing   bool   // Show directory listing if no index.html
-	basicAuthPass string // Basic auth password (empty = no auth)
+	server         *http.Server
+	port           int
+	directory      string
+	mounts         []mount // Directories by URL prefix, longest first
+	listener       net.Listener
+	started        atomic.Bool // Set once Start has been called
+	done           chan struct{}
+	uploadPath     string
+	spaMode        bool   // Serve index.html for all routes (SPA support)
+	showListing    bool   // Show directory listing if no index.html
+	renderMarkdown bool   // Render README.md or index.md if no index.html
+	basicAuthPass  string // Basic auth password (empty = no auth)
+	listingTmpl    *template.Template
+	tls            bool     // Serve HTTPS using server.TLSConfig
+	upload         bool     // Accept multipart POST uploads into directories
+	rejectTypes    []string // Upload content types to refuse
+	exclude        []string // Glob patterns of hidden, inaccessible paths
+	indexFiles     []string // Directory index file names, in order of preference
+	gitignore      []ignoreRule
+	cache          *fileCache // Small files kept in memory; nil when disabled
+	requests       requestCounter
+	bytes          byteCounter
+	tokens         *tokenGate // One-time links past the password and access code
+	resumable      resumableUploads
+	downloads      *downloadCounter
+	authFiles      authFiles     // Parsed .qrlocal-auth files
+	content        *Content      // Served at the root in place of a directory
+	rootInfo       fs.FileInfo   // The served directory when the server started
+	watchInterval  time.Duration // How often to check the served directory; zero disables
+	rootLost       chan error
+	rootLostOnce   sync.Once
 }
 
 // Config holds the server configuration.
 type Config struct {
-	Port          int
-	Directory     string
-	EnableUpload  bool
-	SPAMode       bool   // Enable SPA mode (fallback to index.html)
-	ShowListing   bool   // Show directory listing (default: false, serve index.html)
-	BasicAuthPass string // Basic auth password (empty = no auth)
+	Port         int
+	Directory    string
+	EnableUpload bool
+	SPAMode      bool // Enable SPA mode (fallback to index.html)
+	ShowListing  bool // Show directory listing (default: false, serve index.html)
+
+	// RenderMarkdown shows a directory's index.md or README.md, rendered
+	// to HTML, when it has no index.html. Without one, the listing (if
+	// enabled) is shown as usual; ?listing shows it regardless.
+	RenderMarkdown bool
+	BasicAuthPass  string // Basic auth password (empty = no auth)
+
+	// IndexFiles are the file names served for a directory, the first one
+	// found winning, e.g. "default.html". Empty means DefaultIndexFiles.
+	// SPA mode falls back to the first one found at the root of a mount.
+	IndexFiles []string
+
+	// ListingTemplate overrides the directory listing HTML. It is either a
+	// path to a template file or the template text itself, and is executed
+	// with a ListingData value.
+	ListingTemplate string
+
+	// TLSCertFile and TLSKeyFile enable HTTPS. HTTP/2 is negotiated
+	// automatically over TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Splash is an HTML page (or path to one) shown once per browser session
+	// before any content. It is a template executed with SplashData and
+	// should link to .ContinueURL.
+	Splash string
+
+	// H2C allows HTTP/2 over cleartext connections (prior knowledge or
+	// Upgrade: h2c) in addition to HTTP/1.1.
+	H2C bool
+
+	// KeepAlive is the TCP keepalive idle time and probe interval for
+	// accepted connections. Peers that miss DefaultKeepAliveCount probes
+	// in a row are dropped. Zero uses DefaultKeepAlive; negative disables
+	// keepalive.
+	KeepAlive time.Duration
+
+	// RejectContentTypes lists media types (or "type/*" patterns) that
+	// uploads may not have. Types are detected from the file content, not
+	// its name, e.g. "application/x-executable" or "application/x-msdownload".
+	RejectContentTypes []string
+
+	// TOTPSecret, when set, requires visitors to enter the current 6-digit
+	// time-based code for this base32 secret before seeing any content.
+	TOTPSecret string
+
+	// Exclude lists glob patterns for paths that are hidden from listings
+	// and answered with 404. Patterns without a slash match any path
+	// component (".git", "*.key"); patterns with one match the path
+	// relative to the served directory ("build/*.map").
+	Exclude []string
+
+	// RespectGitignore also excludes paths ignored by the .gitignore file
+	// at the root of the served directory, and the .git directory itself.
+	RespectGitignore bool
+
+	// CacheBytes enables an in-memory cache of small files (up to
+	// MaxCachedFileSize each) holding at most this many bytes, evicting
+	// the least recently used. Zero disables caching.
+	CacheBytes int64
+
+	// PortRange, when set, is an inclusive range of ports scanned for the
+	// first free one if Port is zero or taken, before falling back to a
+	// port chosen by the OS. Without it, a zero Port means 8080.
+	PortRange [2]int
+
+	// DownloadCounts are the download counts to start from, keyed by
+	// absolute file path, e.g. as saved by an earlier session from
+	// Server.DownloadCounts.
+	DownloadCounts map[string]uint64
+
+	// WatchRoot, when positive, is how often the server checks that the
+	// served directory still exists and is the same directory, reporting
+	// on Server.RootLost when it isn't. Requests notice a lost directory
+	// regardless and are answered with 503.
+	WatchRoot time.Duration
+
+	// Mounts serves more directories under URL path prefixes, keyed by
+	// prefix ("/docs"), alongside Directory at the root. Requests go to
+	// the mount with the longest matching prefix, and each mount serves
+	// only files inside its own directory.
+	Mounts map[string]string
+
+	// AccessLog, when set, receives a line per request with the time,
+	// client address, request line, status, response size and duration.
+	AccessLog io.Writer
+
+	// RequestIDHeader, when set along with AccessLog, names the header
+	// carrying a request ID, e.g. "X-Request-Id". An incoming ID is kept,
+	// otherwise one is generated; it is set on the response and added to
+	// the request's access log line.
+	RequestIDHeader string
+
+	// Content, when set, is served at the root in place of Directory, and
+	// every other path is not found. Directory options are ignored.
+	Content *Content
 }
 
+// DefaultIndexFiles are the directory index file names used when
+// Config.IndexFiles is empty.
+var DefaultIndexFiles = []string{"index.html"}
+
+// DefaultKeepAlive is the keepalive interval used when Config.KeepAlive is zero.
+const DefaultKeepAlive = 30 * time.Second
+
+// DefaultKeepAliveCount is the number of unanswered keepalive probes after
+// which a connection is considered dead.
+const DefaultKeepAliveCount = 4
+
 // FileInfo represents a file in directory listing.
 type FileInfo struct {
-	Name    string
-	Size    string
-	ModTime string
-	IsDir   bool
-	Path    string
+	Name      string    `json:"name"`
+	Size      string    `json:"-"`
+	ModTime   string    `json:"-"`
+	IsDir     bool      `json:"is_dir"`
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size"`
+	Modified  time.Time `json:"modified,omitzero"` // Zero when the stat timed out
+	Downloads uint64    `json:"downloads"`         // Complete downloads so far
+}
+
+// ListingData is the data passed to the directory listing template.
+type ListingData struct {
+	Title     string     // Base name of the directory
+	Path      string     // URL path of the directory
+	Files     []FileInfo // Entries, directories first
+	Directory string     // Absolute path of the directory on disk
+	Upload    bool       // Whether files can be uploaded to this directory
+	Since     string     // Active ?since= filter, empty when showing everything
 }
 
 // New creates a new HTTP file server.
 func New(cfg Config) (*Server, error) {
-	// Resolve directory path
-	dir := cfg.Directory
-	if dir == "" {
-		dir = "."
+	var absDir string
+	var info fs.FileInfo
+	var mounts []mount
+	var err error
+	if cfg.Content == nil {
+		absDir, info, err = resolveDirectory(cfg.Directory)
+		if err != nil {
+			return nil, err
+		}
+		mounts, err = newMounts(absDir, info, cfg.Mounts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	absDir, err := filepath.Abs(dir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve directory: %w", err)
+	if err := validatePatterns(cfg.Exclude); err != nil {
+		return nil, err
 	}
 
-	// Check if directory exists
-	info, err := os.Stat(absDir)
+	// Browsers send one set of basic auth credentials, so a directory
+	// needing both the password and an auth file's could never be opened
+	if cfg.BasicAuthPass != "" {
+		if path := findAuthFile(mounts); path != "" {
+			return nil, fmt.Errorf("%s can't be combined with a password: remove one of them", path)
+		}
+	}
+
+	indexFiles := cfg.IndexFiles
+	if len(indexFiles) == 0 {
+		indexFiles = DefaultIndexFiles
+	}
+	for _, name := range indexFiles {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("invalid index file %q: use a file name like index.html", name)
+		}
+		if isAuthFileName(name) {
+			return nil, fmt.Errorf("invalid index file %q: auth files are never served", name)
+		}
+	}
+
+	accessLog, err := newAccessLog(cfg.AccessLog, cfg.RequestIDHeader)
 	if err != nil {
-		return nil, fmt.Errorf("directory not found: %w", err)
+		return nil, err
 	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("path is not a directory: %s", absDir)
+
+	var gitignore []ignoreRule
+	if cfg.RespectGitignore && cfg.Content == nil {
+		gitignore, err = loadGitignore(absDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read .gitignore: %w", err)
+		}
+	}
+
+	listingTmpl := directoryTemplate
+	if cfg.ListingTemplate != "" {
+		listingTmpl, err = parseListingTemplate(cfg.ListingTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, fmt.Errorf("both a TLS certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
 	}
 
 	// Find available port
 	port := cfg.Port
-	if port == 0 {
+	if port == 0 && cfg.PortRange == [2]int{} {
 		port = 8080
 	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
+	lc := listenConfig(cfg.KeepAlive)
+	var listener net.Listener
+	if port != 0 {
+		listener, _ = lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", port))
+	}
+	if listener == nil && cfg.PortRange != [2]int{} {
+		listener = listenInRange(lc, cfg.PortRange)
+	}
+	if listener == nil {
 		// Try to find an available port
-		listener, err = net.Listen("tcp", ":0")
+		listener, err = lc.Listen(context.Background(), "tcp", ":0")
 		if err != nil {
 			return nil, fmt.Errorf("failed to find available port: %w", err)
 		}
-		port = listener.Addr().(*net.TCPAddr).Port
 	}
+	port = listener.Addr().(*net.TCPAddr).Port
 
 	s := &Server{
-		port:          port,
-		directory:     absDir,
-		listener:      listener,
-		done:          make(chan struct{}),
-		spaMode:       cfg.SPAMode,
-		showListing:   cfg.ShowListing,
-		basicAuthPass: cfg.BasicAuthPass,
+		port:           port,
+		directory:      absDir,
+		mounts:         mounts,
+		done:           make(chan struct{}),
+		spaMode:        cfg.SPAMode,
+		showListing:    cfg.ShowListing,
+		renderMarkdown: cfg.RenderMarkdown,
+		indexFiles:     indexFiles,
+		basicAuthPass:  cfg.BasicAuthPass,
+		listingTmpl:    listingTmpl,
+		tls:            tlsConfig != nil,
+		upload:         cfg.EnableUpload,
+		rejectTypes:    cfg.RejectContentTypes,
+		exclude:        cfg.Exclude,
+		gitignore:      gitignore,
+		downloads:      newDownloadCounter(cfg.DownloadCounts),
+		rootInfo:       info,
+		watchInterval:  cfg.WatchRoot,
+		rootLost:       make(chan error, 1),
+		content:        cfg.Content,
+	}
+	if s.content != nil {
+		// There is no directory to watch
+		s.watchInterval = 0
+	}
+	s.listener = s.bytes.listener(listener)
+	if cfg.CacheBytes > 0 {
+		s.cache = newFileCache(cfg.CacheBytes)
 	}
 
 	// Create HTTP handler
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleRequest)
+	var handler http.Handler
+	if s.content != nil {
+		mux.HandleFunc("/", s.handleContent)
+		handler = mux
+	} else {
+		mux.HandleFunc("/", s.handleRequest)
+
+		// Directories with an auth file need their own credentials,
+		// checked after everything else
+		handler = s.dirAuthMiddleware(mux)
+	}
+
+	// Show the splash page before content, but after authentication
+	if cfg.Splash != "" {
+		gate, err := newSplashGate(cfg.Splash)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		handler = gate.middleware(handler)
+	}
+	authenticated := handler
+
+	// The access code is checked before the splash page
+	if cfg.TOTPSecret != "" {
+		gate, err := newTOTPGate(cfg.TOTPSecret)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+		handler = gate.middleware(handler)
+	}
 
-	// Wrap with basic auth if password is set
-	var handler http.Handler = mux
 	if s.basicAuthPass != "" {
-		handler = s.basicAuthMiddleware(mux)
+		handler = s.basicAuthMiddleware(handler)
 	}
 
+	// A one-time token stands in for the password and access code
+	if s.basicAuthPass != "" || cfg.TOTPSecret != "" {
+		s.tokens = newTokenGate()
+		handler = s.tokens.middleware(authenticated, handler)
+	}
+	handler = s.requests.middleware(handler)
+	if accessLog != nil {
+		handler = accessLog.middleware(handler)
+	}
+
+	// HTTP/2 is enabled over TLS; cleartext HTTP/2 only when requested
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(cfg.H2C)
+
 	s.server = &http.Server{
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+		Protocols: protocols,
+
+		// Only headers have a fixed deadline; upload handlers give bodies
+		// their own with uploadDeadline, since large files take minutes
+		ReadHeaderTimeout: 15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
 	}
 
 	return s, nil
 }
 
+// resolveDirectory returns the absolute path of the directory to serve,
+// "." when dir is empty, and its file info.
+func resolveDirectory(dir string) (string, fs.FileInfo, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	// Check if directory exists
+	info, err := os.Stat(absDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("directory not found: %w", err)
+	}
+	if !info.IsDir() {
+		return "", nil, fmt.Errorf("path is not a directory: %s", absDir)
+	}
+	return absDir, info, nil
+}
+
+// listenInRange listens on the first free port in the inclusive range
+// ports, or returns nil if they are all taken.
+func listenInRange(lc net.ListenConfig, ports [2]int) net.Listener {
+	for port := ports[0]; port <= ports[1]; port++ {
+		listener, err := lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", port))
+		if err == nil {
+			return listener
+		}
+	}
+	return nil
+}
+
+// listenConfig returns a ListenConfig that enables TCP keepalive on
+// accepted connections so half-open peers are eventually reaped.
+func listenConfig(keepAlive time.Duration) net.ListenConfig {
+	if keepAlive == 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	if keepAlive < 0 {
+		return net.ListenConfig{KeepAlive: -1}
+	}
+	return net.ListenConfig{
+		KeepAliveConfig: net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     keepAlive,
+			Interval: keepAlive,
+			Count:    DefaultKeepAliveCount,
+		},
+	}
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
+	return s.StartContext(context.Background())
+}
+
+// StartContext starts the HTTP server and stops it when ctx is done.
+// Requests' contexts derive from ctx, so handlers see the cancellation.
+func (s *Server) StartContext(ctx context.Context) error {
+	s.started.Store(true)
+	s.server.BaseContext = func(net.Listener) context.Context { return ctx }
+
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.Stop()
+			case <-s.done:
+			}
+		}()
+	}
+
+	if s.watchInterval > 0 {
+		go s.watchRoot(s.watchInterval)
+	}
+
 	go func() {
-		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.tls {
+			// Certificates are already loaded into TLSConfig
+			err = s.server.ServeTLS(s.listener, "", "")
+		} else {
+			err = s.server.Serve(s.listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		}
 		close(s.done)
@@ -131,16 +499,30 @@ func (s *Server) Port() int {
 	return s.port
 }
 
-// Directory returns the directory being served.
+// Scheme returns the URL scheme the server is reachable with.
+func (s *Server) Scheme() string {
+	if s.tls {
+		return "https"
+	}
+	return "http"
+}
+
+// Directory returns the directory being served, or "" when serving
+// Content.
 func (s *Server) Directory() string {
 	return s.directory
 }
 
-// Stop gracefully stops the server.
+// Stop gracefully stops the server. A server that was never started just
+// releases its port.
 func (s *Server) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	defer s.resumable.cleanup()
+	if !s.started.Load() {
+		return s.listener.Close()
+	}
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server shutdown error: %w", err)
 	}
@@ -155,9 +537,15 @@ func (s *Server) Stop() error {
 
 // basicAuthMiddleware wraps a handler with basic authentication.
 func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
+	return basicAuth(s.basicAuthPass, next)
+}
+
+// basicAuth wraps next so only requests with password as the basic auth
+// password are served. The username is ignored.
+func basicAuth(password string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, pass, ok := r.BasicAuth()
-		if !ok || pass != s.basicAuthPass {
+		if !ok || subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
 			w.Header().Set("WWW-Authenticate", `Basic realm="qrlocal"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -173,30 +561,54 @@ func (s *Server) Wait() {
 
 // handleRequest handles all incoming HTTP requests.
 func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if s.upload && strings.HasPrefix(r.URL.Path, resumablePath) {
+		s.handleResumable(w, r)
+		return
+	}
+
 	// Clean the path to prevent directory traversal
 	urlPath := filepath.Clean(r.URL.Path)
 	if urlPath == "" {
 		urlPath = "/"
 	}
 
-	// Build the full file path
-	filePath := filepath.Join(s.directory, urlPath)
-
-	// Ensure the path is within the served directory
-	if !strings.HasPrefix(filePath, s.directory) {
+	// Build the full file path, ensuring it is within the directory of
+	// the mount serving it
+	filePath, ok := s.resolvePath(urlPath)
+	if !ok {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
+	if s.excluded(urlPath) || isAuthFile(filePath) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !s.upload {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Tus-Resumable") != "" {
+			s.createResumable(w, r, filePath)
+			return
+		}
+		s.handleUpload(w, r, filePath)
+		return
+	}
+
 	// Check if the file exists
 	info, err := os.Stat(filePath)
+	if err != nil && s.rootUnavailable(w) {
+		return
+	}
 	if os.IsNotExist(err) {
 		// File doesn't exist - check SPA mode
 		if s.spaMode {
-			// Serve index.html for SPA routing
-			indexPath := filepath.Join(s.directory, "index.html")
-			if _, err := os.Stat(indexPath); err == nil {
-				http.ServeFile(w, r, indexPath)
+			// Serve the mount's index file for SPA routing
+			if indexPath, indexInfo, ok := s.findIndex(s.mountFor(urlPath).dir); ok {
+				s.serveFile(w, r, indexPath, indexInfo)
 				return
 			}
 		}
@@ -210,14 +622,19 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Handle directories
 	if info.IsDir() {
-		// Try to serve index.html first
-		indexPath := filepath.Join(filePath, "index.html")
-		if _, err := os.Stat(indexPath); err == nil {
-			http.ServeFile(w, r, indexPath)
+		// Try to serve an index file first
+		if indexPath, indexInfo, ok := s.findIndex(filePath); ok {
+			s.serveFile(w, r, indexPath, indexInfo)
+			return
+		}
+
+		// Then a rendered README.md or index.md, unless the listing is
+		// explicitly asked for
+		if s.renderMarkdown && !wantsListing(r) && s.serveMarkdownIndex(w, r, filePath, urlPath) {
 			return
 		}
 
-		// If no index.html and listing is enabled, show directory listing
+		// If no index file and listing is enabled, show directory listing
 		if s.showListing {
 			s.serveDirectory(w, r, filePath, urlPath)
 			return
@@ -228,45 +645,96 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Serve the file
-	http.ServeFile(w, r, filePath)
+	// Serve the file. ServeFile redirects /index.html to the directory.
+	if strings.HasSuffix(r.URL.Path, "/index.html") {
+		http.ServeFile(w, r, filePath)
+		return
+	}
+	s.serveCountedFile(w, r, filePath, info)
 }
 
-// serveDirectory renders a directory listing.
-func (s *Server) serveDirectory(w http.ResponseWriter, r *http.Request, dirPath, urlPath string) {
+// findIndex returns the first of the index files that exists as a regular
+// file in dir.
+func (s *Server) findIndex(dir string) (string, fs.FileInfo, bool) {
+	for _, name := range s.indexFiles {
+		indexPath := filepath.Join(dir, name)
+		if info, err := os.Stat(indexPath); err == nil && info.Mode().IsRegular() {
+			return indexPath, info, true
+		}
+	}
+	return "", nil, false
+}
+
+// listDirectory returns the visible entries of a directory, directories first.
+func (s *Server) listDirectory(dirPath, urlPath string) ([]FileInfo, error) {
 	// Read directory contents
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
-		http.Error(w, "Failed to read directory", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
-	// Build file list
-	files := make([]FileInfo, 0, len(entries))
+	// Skip hidden files (starting with .) before stat'ing anything
+	visible := entries[:0]
 	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if s.excluded(path.Join(filepath.ToSlash(urlPath), entry.Name())) {
 			continue
 		}
+		visible = append(visible, entry)
+	}
 
-		// Skip hidden files (starting with .)
-		if strings.HasPrefix(entry.Name(), ".") {
+	// Directories mounted here are listed like subdirectories
+	for _, mounted := range s.mountEntries(urlPath) {
+		visible = slices.DeleteFunc(visible, func(e os.DirEntry) bool { return e.Name() == mounted.Name() })
+		visible = append(visible, mounted)
+	}
+
+	// Build file list. Entries on a slow mount whose metadata doesn't
+	// arrive in time are still listed, without size or date.
+	stats := statEntries(visible)
+	authInfo, _ := os.Stat(filepath.Join(dirPath, AuthFileName))
+	files := make([]FileInfo, 0, len(visible))
+	for i, entry := range visible {
+		stat := stats[i]
+		if stat.err != nil {
+			continue
+		}
+
+		// Other names for the auth file, such as hard links and
+		// symlinks, are left out too
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if isAuthFile(filepath.Join(dirPath, entry.Name())) {
+				continue
+			}
+		} else if authInfo != nil && !stat.timedOut && os.SameFile(stat.info, authInfo) {
 			continue
 		}
 
 		fi := FileInfo{
 			Name:    entry.Name(),
 			IsDir:   entry.IsDir(),
-			ModTime: info.ModTime().Format("Jan 02, 2006 15:04"),
+			ModTime: "—",
+		}
+		if !stat.timedOut {
+			fi.ModTime = stat.info.ModTime().Format("Jan 02, 2006 15:04")
+			fi.Modified = stat.info.ModTime()
 		}
 
-		if entry.IsDir() {
+		switch {
+		case entry.IsDir():
 			fi.Name += "/"
 			fi.Size = "-"
 			fi.Path = filepath.Join(urlPath, entry.Name()) + "/"
-		} else {
-			fi.Size = formatFileSize(info.Size())
+		case stat.timedOut:
+			fi.Size = "—"
 			fi.Path = filepath.Join(urlPath, entry.Name())
+		default:
+			fi.Size = FormatFileSize(stat.info.Size())
+			fi.SizeBytes = stat.info.Size()
+			fi.Path = filepath.Join(urlPath, entry.Name())
+			fi.Downloads = s.downloads.get(filepath.Join(dirPath, entry.Name()))
 		}
 
 		files = append(files, fi)
@@ -280,6 +748,100 @@ func (s *Server) serveDirectory(w http.ResponseWriter, r *http.Request, dirPath,
 		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
 	})
 
+	return files, nil
+}
+
+// wantsJSON reports whether a directory request asks for a JSON listing,
+// either with ?format=json or an Accept header preferring JSON.
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// maxSince is the longest window accepted by ?since=.
+const maxSince = 100 * 365 * 24 * time.Hour
+
+// parseSince parses the ?since= window: a Go duration such as "90m" or
+// "24h", or a whole number of days such as "7d".
+func parseSince(value string) (time.Duration, error) {
+	var since time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid since %q: use a duration like 30m, 24h or 7d", value)
+		}
+		since = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid since %q: use a duration like 30m, 24h or 7d", value)
+		}
+		since = d
+	}
+	if since <= 0 {
+		return 0, fmt.Errorf("invalid since %q: must be positive", value)
+	}
+	if since > maxSince {
+		return 0, fmt.Errorf("invalid since %q: too long", value)
+	}
+	return since, nil
+}
+
+// filterRecent returns the entries modified within since of now, newest
+// first.
+func filterRecent(files []FileInfo, since time.Duration, now time.Time) []FileInfo {
+	cutoff := now.Add(-since)
+	recent := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.Modified.After(cutoff) {
+			recent = append(recent, f)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].Modified.After(recent[j].Modified)
+	})
+	return recent
+}
+
+// serveDirectory renders a directory listing as HTML or JSON. With
+// ?since=<duration>, only entries modified within that window are listed;
+// ?stats=json returns the download counts instead.
+func (s *Server) serveDirectory(w http.ResponseWriter, r *http.Request, dirPath, urlPath string) {
+	if r.URL.Query().Get("stats") == "json" {
+		s.serveDownloadStats(w, dirPath)
+		return
+	}
+
+	files, err := s.listDirectory(dirPath, urlPath)
+	if err != nil {
+		if s.rootUnavailable(w) {
+			return
+		}
+		http.Error(w, "Failed to read directory", http.StatusInternalServerError)
+		return
+	}
+
+	sinceParam := r.URL.Query().Get("since")
+	if sinceParam != "" {
+		since, err := parseSince(sinceParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files = filterRecent(files, since, time.Now())
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			http.Error(w, "Failed to encode directory listing", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// Add parent directory link if not at root
 	if urlPath != "/" {
 		parent := filepath.Dir(urlPath)
@@ -295,26 +857,55 @@ func (s *Server) serveDirectory(w http.ResponseWriter, r *http.Request, dirPath,
 	}
 
 	// Render template
-	data := struct {
-		Title     string
-		Path      string
-		Files     []FileInfo
-		Directory string
-	}{
+	data := ListingData{
 		Title:     filepath.Base(dirPath),
 		Path:      urlPath,
 		Files:     files,
 		Directory: dirPath,
+		Upload:    s.upload,
+		Since:     sinceParam,
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := directoryTemplate.Execute(w, data); err != nil {
+	if err := s.listingTmpl.Execute(w, data); err != nil {
 		http.Error(w, "Failed to render directory listing", http.StatusInternalServerError)
 	}
 }
 
-// formatFileSize formats a file size in bytes to a human-readable string.
-func formatFileSize(size int64) string {
+// parseListingTemplate parses a custom listing template from a file path
+// or from the template text itself. The template is executed once against
+// sample data so references to unknown fields fail at startup.
+func parseListingTemplate(source string) (*template.Template, error) {
+	text := source
+	if data, err := os.ReadFile(source); err == nil {
+		text = string(data)
+	} else if !strings.Contains(source, "{{") {
+		return nil, fmt.Errorf("failed to read listing template: %w", err)
+	}
+
+	tmpl, err := template.New("listing").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listing template: %w", err)
+	}
+
+	sample := ListingData{
+		Title: "example",
+		Path:  "/",
+		Files: []FileInfo{
+			{Name: "docs/", Size: "-", ModTime: "Jan 02, 2006 15:04", IsDir: true, Path: "/docs/"},
+			{Name: "file.txt", Size: "1.0 KB", ModTime: "Jan 02, 2006 15:04", Path: "/file.txt"},
+		},
+		Directory: "/example",
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid listing template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// FormatFileSize formats a file size in bytes to a human-readable string.
+func FormatFileSize(size int64) string {
 	const (
 		KB = 1024
 		MB = KB * 1024
@@ -391,6 +982,13 @@ var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE
             margin-top: 4px;
             word-break: break-all;
         }
+        header .filter {
+            font-size: 0.85rem;
+            margin-top: 8px;
+        }
+        header .filter a {
+            color: white;
+        }
         .file-list {
             list-style: none;
         }
@@ -447,6 +1045,16 @@ var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE
             min-width: 140px;
             text-align: right;
         }
+        .upload {
+            display: flex;
+            gap: 12px;
+            align-items: center;
+            padding: 12px 24px;
+            border-top: 1px solid #eee;
+        }
+        .upload input[type=file] {
+            flex: 1;
+        }
         footer {
             padding: 16px 24px;
             background: #f9f9f9;
@@ -481,6 +1089,9 @@ var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE
         <header>
             <h1>📁 {{.Title}}</h1>
             <div class="path">{{.Path}}</div>
+            <div class="filter">
+                {{if .Since}}Modified in the last {{.Since}} · <a href="{{.Path}}">Show all</a>{{else}}<a href="{{.Path}}?since=24h">Recent (24h)</a>{{end}}
+            </div>
         </header>
         <ul class="file-list">
             {{range .Files}}
@@ -498,12 +1109,99 @@ var directoryTemplate = template.Must(template.New("directory").Parse(`<!DOCTYPE
                     <span class="name">{{.Name}}</span>
                 </a>
                 <div class="meta">
+                    {{if .Downloads}}<span class="downloads">{{.Downloads}} {{if eq .Downloads 1}}download{{else}}downloads{{end}}</span>{{end}}
                     <span class="size">{{.Size}}</span>
                     <span class="date">{{.ModTime}}</span>
                 </div>
             </li>
             {{end}}
         </ul>
+        {{if .Upload}}
+        <form class="upload" method="post" enctype="multipart/form-data" action="{{.Path}}">
+            <input type="file" name="file" multiple required>
+            <button type="submit">Upload</button>
+        </form>
+        <script>
+        // Upload in chunks that resume where they left off after a dropped
+        // connection, or a reload of the page
+        (function () {
+            var form = document.querySelector("form.upload");
+            if (!window.fetch || !window.localStorage) return;
+            var chunkSize = 4 << 20;
+
+            form.addEventListener("submit", function (e) {
+                e.preventDefault();
+                var files = form.querySelector("input[type=file]").files;
+                var button = form.querySelector("button");
+                button.disabled = true;
+                var i = 0;
+                (function next() {
+                    if (i >= files.length) return location.reload();
+                    upload(files[i++], button).then(next, function (err) {
+                        button.disabled = false;
+                        button.textContent = "Upload";
+                        alert(err.message);
+                    });
+                })();
+            });
+
+            function upload(file, button) {
+                var key = "qrlocal-upload:" + form.action + ":" + file.name + ":" + file.size + ":" + file.lastModified;
+                var url = localStorage.getItem(key);
+                var retries = 0;
+                var headers = function (h) { h["Tus-Resumable"] = "1.0.0"; return h; };
+                var fail = function (res) {
+                    localStorage.removeItem(key);
+                    return res.text().then(function (text) { throw new Error(text); });
+                };
+
+                // Ask how much arrived, or start the upload
+                function offset() {
+                    if (url) {
+                        return fetch(url, {method: "HEAD", headers: headers({})}).then(function (res) {
+                            if (res.ok) return Number(res.headers.get("Upload-Offset"));
+                            url = null;
+                            return offset();
+                        });
+                    }
+                    return fetch(form.action, {method: "POST", headers: headers({
+                        "Upload-Length": String(file.size),
+                        "Upload-Metadata": "filename " + btoa(unescape(encodeURIComponent(file.name)))
+                    })}).then(function (res) {
+                        if (!res.ok) return fail(res);
+                        url = res.headers.get("Location");
+                        localStorage.setItem(key, url);
+                        return 0;
+                    });
+                }
+
+                function send(from) {
+                    button.textContent = Math.floor(100 * from / Math.max(file.size, 1)) + "%";
+                    if (from >= file.size) return localStorage.removeItem(key);
+                    return fetch(url, {method: "PATCH", headers: headers({
+                        "Content-Type": "application/offset+octet-stream",
+                        "Upload-Offset": String(from)
+                    }), body: file.slice(from, from + chunkSize)}).then(function (res) {
+                        if (res.status === 409) return offset().then(send);
+                        if (!res.ok) return fail(res);
+                        retries = 0;
+                        return send(Number(res.headers.get("Upload-Offset")));
+                    });
+                }
+
+                // Network errors are retried with a growing delay
+                function attempt() {
+                    return offset().then(send).catch(function (err) {
+                        if (!(err instanceof TypeError) || ++retries > 10) throw err;
+                        button.textContent = "Reconnecting...";
+                        return new Promise(function (resolve) { setTimeout(resolve, 2000 * retries); }).then(attempt);
+                    });
+                }
+                return attempt();
+            }
+        })();
+        </script>
+        {{end}}
         <footer>
             Served by <a href="https://github.com/dendysatrya/qrlocal">qrlocal</a>
         </footer>
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a server for cfg on a free port, without starting
+// it, and closes its listener when the test ends.
+func newTestServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	if cfg.Port == 0 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Port = l.Addr().(*net.TCPAddr).Port
+		l.Close()
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.listener.Close() })
+	return s
+}
+
+// writeFiles creates the files, given by slash-separated path relative to
+// dir, with their contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// get sends a GET request for target to the server's handler.
+func get(s *Server, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestExcludeBlocksDirectRequests(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.txt":            "visible",
+		"secret.key":           "root key",
+		"nested/secret.key":    "nested key",
+		"nested/notes.txt":     "visible",
+		"build/app.js":         "visible",
+		"build/app.js.map":     "source map",
+		"other/build/x.map":    "not under the root's build",
+		"private/anything.txt": "hidden directory",
+	})
+
+	s := newTestServer(t, Config{
+		Directory:   dir,
+		ShowListing: true,
+		Exclude:     []string{"*.key", "build/*.map", "private"},
+	})
+
+	blocked := []string{
+		"/secret.key",
+		"/nested/secret.key",
+		"/build/app.js.map",
+		"/private/anything.txt",
+		"/private/",
+	}
+	for _, target := range blocked {
+		if code := get(s, target).Code; code != http.StatusNotFound && code != http.StatusForbidden {
+			t.Errorf("GET %s = %d, want 404 or 403", target, code)
+		}
+	}
+
+	allowed := []string{"/index.txt", "/nested/notes.txt", "/build/app.js", "/other/build/x.map"}
+	for _, target := range allowed {
+		if code := get(s, target).Code; code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", target, code)
+		}
+	}
+
+	listings := map[string][]string{
+		"/":        {"secret.key", "private"},
+		"/nested/": {"secret.key"},
+		"/build/":  {"app.js.map"},
+	}
+	for target, hidden := range listings {
+		rec := get(s, target+"?format=json")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s?format=json = %d", target, rec.Code)
+		}
+		var files []FileInfo
+		if err := json.Unmarshal(rec.Body.Bytes(), &files); err != nil {
+			t.Fatalf("listing %s: %v", target, err)
+		}
+		for _, f := range files {
+			for _, name := range hidden {
+				if f.Name == name || f.Name == name+"/" {
+					t.Errorf("listing of %s shows excluded %s", target, f.Name)
+				}
+			}
+		}
+	}
+}
+
+// writeTestCert writes a self-signed certificate for localhost and its key
+// to dir and returns their paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// startTestServer creates and starts a server for cfg, stopping it when
+// the test ends.
+func startTestServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	s := newTestServer(t, cfg)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+func TestNegotiatedProtocol(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"hello.txt": "hello"})
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	http1 := new(http.Protocols)
+	http1.SetHTTP1(true)
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		scheme    string
+		protocols *http.Protocols // Client protocols; nil is the default
+		want      string
+	}{
+		{
+			name:   "TLS negotiates HTTP/2",
+			cfg:    Config{Directory: dir, TLSCertFile: certFile, TLSKeyFile: keyFile},
+			scheme: "https",
+			want:   "HTTP/2.0",
+		},
+		{
+			name:   "cleartext stays HTTP/1.1",
+			cfg:    Config{Directory: dir},
+			scheme: "http",
+			want:   "HTTP/1.1",
+		},
+		{
+			name:      "h2c with prior knowledge",
+			cfg:       Config{Directory: dir, H2C: true},
+			scheme:    "http",
+			protocols: h2c,
+			want:      "HTTP/2.0",
+		},
+		{
+			name:      "h2c server still speaks HTTP/1.1",
+			cfg:       Config{Directory: dir, H2C: true},
+			scheme:    "http",
+			protocols: http1,
+			want:      "HTTP/1.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startTestServer(t, tt.cfg)
+
+			transport := &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				ForceAttemptHTTP2: true,
+				Protocols:         tt.protocols,
+			}
+			defer transport.CloseIdleConnections()
+			client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+			resp, err := client.Get(fmt.Sprintf("%s://127.0.0.1:%d/hello.txt", tt.scheme, s.Port()))
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.Proto != tt.want {
+				t.Errorf("Proto = %s, want %s", resp.Proto, tt.want)
+			}
+			if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+				t.Errorf("got %d %q, want 200 \"hello\"", resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestStopUnstarted(t *testing.T) {
+	s := newTestServer(t, Config{Directory: t.TempDir()})
+
+	start := time.Now()
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stop took %v on a server that never started", elapsed)
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port()))
+	if err != nil {
+		t.Fatalf("port %d not released: %v", s.Port(), err)
+	}
+	l.Close()
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// splashCookie holds the session token of a visitor who has seen the splash page.
+	splashCookie = "qrlocal_splash"
+
+	// splashContinuePath accepts the splash page and redirects to the content.
+	splashContinuePath = "/.qrlocal/continue"
+)
+
+// SplashData is the data passed to the splash page template.
+type SplashData struct {
+	ContinueURL string // Link that accepts the splash and shows the content
+}
+
+// splashGate shows a splash page once per browser session before any content.
+type splashGate struct {
+	tmpl     *template.Template
+	mu       sync.Mutex
+	sessions map[string]bool
+}
+
+// newSplashGate parses the splash page from a file path or HTML text.
+func newSplashGate(source string) (*splashGate, error) {
+	text := source
+	if data, err := os.ReadFile(source); err == nil {
+		text = string(data)
+	} else if !strings.Contains(source, "<") {
+		return nil, fmt.Errorf("failed to read splash page: %w", err)
+	}
+
+	tmpl, err := template.New("splash").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid splash page: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, SplashData{ContinueURL: splashContinuePath}); err != nil {
+		return nil, fmt.Errorf("invalid splash page: %w", err)
+	}
+
+	return &splashGate{tmpl: tmpl, sessions: make(map[string]bool)}, nil
+}
+
+// middleware wraps next so visitors without an accepted session see the splash page.
+func (g *splashGate) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == splashContinuePath {
+			g.accept(w, r)
+			return
+		}
+
+		if c, err := r.Cookie(splashCookie); err == nil && g.valid(c.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Only show the splash page to browsers navigating to a page
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		continueURL := splashContinuePath + "?next=" + url.QueryEscape(r.URL.RequestURI())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := g.tmpl.Execute(w, SplashData{ContinueURL: continueURL}); err != nil {
+			http.Error(w, "Failed to render splash page", http.StatusInternalServerError)
+		}
+	})
+}
+
+// accept starts a session and redirects back to the requested page.
+func (g *splashGate) accept(w http.ResponseWriter, r *http.Request) {
+	token, err := newSessionToken()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	g.mu.Lock()
+	g.sessions[token] = true
+	g.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     splashCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only redirect to local paths
+	next := r.URL.Query().Get("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// valid reports whether token belongs to an accepted session.
+func (g *splashGate) valid(token string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sessions[token]
+}
+
+// newSessionToken returns a random session token.
+func newSessionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
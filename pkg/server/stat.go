@@ -0,0 +1,67 @@
+package server
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+const (
+	// statWorkers bounds how many directory entries are stat'ed at once.
+	statWorkers = 16
+
+	// statTimeout is how long a listing waits for a single entry's
+	// metadata, so a slow network mount can't hang it.
+	statTimeout = 2 * time.Second
+)
+
+// statResult is the outcome of stat'ing one directory entry.
+type statResult struct {
+	info     fs.FileInfo
+	err      error
+	timedOut bool
+}
+
+// statEntries returns the metadata of each entry, in order, stat'ing them
+// concurrently. An entry whose stat takes longer than statTimeout is
+// reported as timed out; its stat carries on in the background.
+func statEntries(entries []fs.DirEntry) []statResult {
+	results := make([]statResult, len(entries))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(statWorkers, len(entries)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = statWithTimeout(entries[i])
+			}
+		}()
+	}
+	for i := range entries {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// statWithTimeout stats entry, giving up after statTimeout.
+func statWithTimeout(entry fs.DirEntry) statResult {
+	done := make(chan statResult, 1)
+	go func() {
+		info, err := entry.Info()
+		done <- statResult{info: info, err: err}
+	}()
+
+	timer := time.NewTimer(statTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+		return statResult{timedOut: true}
+	}
+}
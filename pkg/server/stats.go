@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// requestCounter counts the requests passing through its middleware,
+// including ones later rejected for a missing password or access code.
+type requestCounter struct {
+	n atomic.Uint64
+}
+
+func (c *requestCounter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.n.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Requests returns the number of requests the server has received.
+func (s *Server) Requests() uint64 {
+	return s.requests.n.Load()
+}
+
+// Requests returns the number of requests the proxy has received.
+func (p *AuthProxy) Requests() uint64 {
+	return p.requests.n.Load()
+}
+
+// byteCounter counts the bytes read from and written to the connections
+// accepted by its listener, including headers and TLS overhead.
+type byteCounter struct {
+	in, out atomic.Uint64
+}
+
+func (c *byteCounter) listener(l net.Listener) net.Listener {
+	return &countingListener{Listener: l, counter: c}
+}
+
+type countingListener struct {
+	net.Listener
+	counter *byteCounter
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, counter: l.counter}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	counter *byteCounter
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.counter.in.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.counter.out.Add(uint64(n))
+	return n, err
+}
+
+// BytesTransferred returns the number of bytes the server has received
+// from and sent to its clients.
+func (s *Server) BytesTransferred() (in, out uint64) {
+	return s.bytes.in.Load(), s.bytes.out.Load()
+}
+
+// BytesTransferred returns the number of bytes the proxy has received
+// from and sent to its clients. Traffic to the service behind it is not
+// counted again.
+func (p *AuthProxy) BytesTransferred() (in, out uint64) {
+	return p.bytes.in.Load(), p.bytes.out.Load()
+}
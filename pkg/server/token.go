@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenCookie holds the session of a visitor who opened a token link.
+	tokenCookie = "qrlocal_token"
+
+	// tokenParam is the query parameter carrying a one-time token.
+	tokenParam = "token"
+
+	// tokenSessionTTL is how long the session a token was exchanged for
+	// lasts, as long as an access code session.
+	tokenSessionTTL = totpSessionTTL
+)
+
+// ErrNoAuth is returned by IssueToken when the server has no password or
+// access code for a token to stand in for.
+var ErrNoAuth = errors.New("one-time tokens need a password or access code")
+
+// tokenGate lets visitors with a one-time token skip the password and
+// access code. Each token is burned on first use, valid or not, and
+// exchanged for a session cookie.
+type tokenGate struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time // Unused tokens and when they expire
+	sessions map[string]time.Time // Sessions and when they expire
+}
+
+// newTokenGate creates a gate with no tokens issued.
+func newTokenGate() *tokenGate {
+	return &tokenGate{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// middleware serves authenticated to visitors with a token session, and
+// gated, which asks for the password or code, to everyone else.
+func (g *tokenGate) middleware(authenticated, gated http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(tokenCookie); err == nil && g.valid(c.Value) {
+			authenticated.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		token := query.Get(tokenParam)
+		if token == "" || !g.redeem(token) {
+			gated.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := newSessionToken()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		g.start(session)
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     tokenCookie,
+			Value:    session,
+			Path:     "/",
+			MaxAge:   int(tokenSessionTTL / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		// Drop the spent token from the address bar and history
+		query.Del(tokenParam)
+		next := *r.URL
+		next.RawQuery = query.Encode()
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, next.RequestURI(), http.StatusSeeOther)
+	})
+}
+
+// issue creates a token that can be redeemed once within ttl.
+func (g *tokenGate) issue(ttl time.Duration) (string, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for t, expires := range g.tokens {
+		if now.After(expires) {
+			delete(g.tokens, t)
+		}
+	}
+	g.tokens[token] = now.Add(ttl)
+	return token, nil
+}
+
+// redeem burns token and reports whether it was unused and unexpired.
+func (g *tokenGate) redeem(token string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	expires, ok := g.tokens[token]
+	delete(g.tokens, token)
+	return ok && time.Now().Before(expires)
+}
+
+// start records a session lasting tokenSessionTTL, sweeping out expired
+// ones so they can't pile up over a long share.
+func (g *tokenGate) start(session string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for s, expires := range g.sessions {
+		if now.After(expires) {
+			delete(g.sessions, s)
+		}
+	}
+	g.sessions[session] = now.Add(tokenSessionTTL)
+}
+
+// valid reports whether session was started with a token and hasn't
+// expired.
+func (g *tokenGate) valid(session string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	expires, ok := g.sessions[session]
+	return ok && time.Now().Before(expires)
+}
+
+// IssueToken returns a one-time token that lets a visitor past the
+// password and access code when passed as ?token=. It expires after ttl
+// and is burned on first use.
+func (s *Server) IssueToken(ttl time.Duration) (string, error) {
+	if s.tokens == nil {
+		return "", ErrNoAuth
+	}
+	return s.tokens.issue(ttl)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenSessionExpiry(t *testing.T) {
+	gate := newTokenGate()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	denied := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+	handler := gate.middleware(ok, denied)
+
+	gate.sessions["stale"] = time.Now().Add(-time.Second)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: tokenCookie, Value: "stale"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expired session = %d, want 401", rec.Code)
+	}
+
+	token, err := gate.issue(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?token="+token, nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusSeeOther || len(cookies) != 1 {
+		t.Fatalf("redeem = %d with %d cookies, want 303 with a session cookie", rec.Code, len(cookies))
+	}
+	if want := int(tokenSessionTTL / time.Second); cookies[0].MaxAge != want {
+		t.Errorf("cookie MaxAge = %d, want %d", cookies[0].MaxAge, want)
+	}
+	if !gate.valid(cookies[0].Value) {
+		t.Error("new session isn't valid")
+	}
+	if _, ok := gate.sessions["stale"]; ok {
+		t.Error("expired session wasn't swept when a new one started")
+	}
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/totp"
+)
+
+const (
+	// totpCookie holds the session token of a visitor who entered a valid code.
+	totpCookie = "qrlocal_totp"
+
+	// totpVerifyPath accepts the access code form.
+	totpVerifyPath = "/.qrlocal/verify"
+
+	// totpMaxFailures is the number of wrong codes a client may enter
+	// within totpFailureWindow before it must wait between attempts. The
+	// wait starts at totpBackoff and doubles with each further failure,
+	// up to totpMaxBackoff. Verified sessions are never affected.
+	totpMaxFailures   = 5
+	totpFailureWindow = 15 * time.Minute
+	totpBackoff       = time.Second
+	totpMaxBackoff    = time.Minute
+
+	// totpSessionTTL is how long a verified session lasts before the
+	// visitor must enter a new code.
+	totpSessionTTL = 12 * time.Hour
+)
+
+// totpPageData is the data passed to the access code page.
+type totpPageData struct {
+	Next  string
+	Error string
+}
+
+// totpGate requires a valid time-based access code before serving content.
+type totpGate struct {
+	secret   string
+	mu       sync.Mutex
+	sessions map[string]time.Time   // Verified sessions and when they expire
+	failures map[string][]time.Time // Recent failed attempts by client
+}
+
+// newTOTPGate creates a gate that validates codes against secret.
+func newTOTPGate(secret string) (*totpGate, error) {
+	if err := totp.ValidateSecret(secret); err != nil {
+		return nil, err
+	}
+	return &totpGate{
+		secret:   secret,
+		sessions: make(map[string]time.Time),
+		failures: make(map[string][]time.Time),
+	}, nil
+}
+
+// middleware wraps next so visitors without a verified session are asked
+// for the access code.
+func (g *totpGate) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == totpVerifyPath && r.Method == http.MethodPost {
+			g.verify(w, r)
+			return
+		}
+
+		if c, err := r.Cookie(totpCookie); err == nil && g.valid(c.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		g.render(w, http.StatusUnauthorized, totpPageData{Next: r.URL.RequestURI()})
+	})
+}
+
+// verify checks a submitted code, starting a session if it is valid.
+func (g *totpGate) verify(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		next = "/"
+	}
+
+	client := totpClient(r)
+	if wait := g.retryAfter(client, time.Now()); wait > 0 {
+		seconds := int((wait + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		g.render(w, http.StatusTooManyRequests, totpPageData{Next: next, Error: fmt.Sprintf("Too many attempts. Try again in %s.", time.Duration(seconds)*time.Second)})
+		return
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+	if !totp.Validate(g.secret, code, time.Now()) {
+		g.recordFailure(client)
+		g.render(w, http.StatusUnauthorized, totpPageData{Next: next, Error: "Invalid or expired code."})
+		return
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Expired sessions are swept as new ones start, so they can't pile
+	// up over a long share
+	now := time.Now()
+	g.mu.Lock()
+	for t, expires := range g.sessions {
+		if now.After(expires) {
+			delete(g.sessions, t)
+		}
+	}
+	g.sessions[token] = now.Add(totpSessionTTL)
+	delete(g.failures, client)
+	g.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     totpCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(totpSessionTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// valid reports whether token belongs to an unexpired verified session.
+func (g *totpGate) valid(token string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	expires, ok := g.sessions[token]
+	return ok && time.Now().Before(expires)
+}
+
+// retryAfter returns how long client must wait at now before entering
+// another code, or zero if it may try now.
+func (g *totpGate) retryAfter(client string, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cutoff := now.Add(-totpFailureWindow)
+	recent := g.failures[client][:0]
+	for _, t := range g.failures[client] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) == 0 {
+		delete(g.failures, client)
+		return 0
+	}
+	g.failures[client] = recent
+	if len(recent) < totpMaxFailures {
+		return 0
+	}
+
+	// The shift is capped so it can't overflow
+	backoff := min(totpBackoff<<min(len(recent)-totpMaxFailures, 16), totpMaxBackoff)
+	return max(recent[len(recent)-1].Add(backoff).Sub(now), 0)
+}
+
+// recordFailure notes a failed attempt by client.
+func (g *totpGate) recordFailure(client string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.failures[client] = append(g.failures[client], time.Now())
+}
+
+// render writes the access code page.
+func (g *totpGate) render(w http.ResponseWriter, status int, data totpPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	totpTemplate.Execute(w, data)
+}
+
+// totpClient returns the key failed attempts from r are counted under:
+// the client's IP address. Visitors coming through a tunnel or the auth
+// proxy all arrive from loopback and can't be told apart, so they share
+// one key; the backoff then slows guessing down for all of them without
+// shutting anyone out for long.
+func totpClient(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsLoopback() {
+		return "loopback"
+	}
+	return host
+}
+
+// Access code page HTML template
+var totpTemplate = template.Must(template.New("totp").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1">
+    <title>Access code - qrlocal</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
+            background: #f5f5f5;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            min-height: 100vh;
+            margin: 0;
+        }
+        form {
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+            padding: 24px;
+            text-align: center;
+        }
+        input {
+            font-size: 2rem;
+            letter-spacing: 0.3em;
+            width: 8ch;
+            text-align: center;
+            margin: 16px 0;
+        }
+        .error {
+            color: #c62828;
+        }
+    </style>
+</head>
+<body>
+    <form method="post" action="/.qrlocal/verify">
+        <h1>Enter access code</h1>
+        <p>Ask the person sharing this link for the current 6-digit code.</p>
+        {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+        <input type="hidden" name="next" value="{{.Next}}">
+        <input name="code" inputmode="numeric" autocomplete="one-time-code" pattern="[0-9]{6}" maxlength="6" required autofocus>
+        <div><button type="submit">Continue</button></div>
+    </form>
+</body>
+</html>
+`))
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hash/qrlocal/pkg/totp"
+)
+
+func TestTOTPSessionExpiry(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	gate, err := newTOTPGate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := gate.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	gate.sessions["stale"] = time.Now().Add(-time.Second)
+	if gate.valid("stale") {
+		t.Error("expired session is still valid")
+	}
+
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, totpVerifyPath, strings.NewReader(url.Values{"code": {code}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusSeeOther || len(cookies) != 1 {
+		t.Fatalf("verify = %d with %d cookies, want 303 with a session cookie", rec.Code, len(cookies))
+	}
+	if want := int(totpSessionTTL / time.Second); cookies[0].MaxAge != want {
+		t.Errorf("cookie MaxAge = %d, want %d", cookies[0].MaxAge, want)
+	}
+	if !gate.valid(cookies[0].Value) {
+		t.Error("new session isn't valid")
+	}
+	if _, ok := gate.sessions["stale"]; ok {
+		t.Error("expired session wasn't swept when a new one started")
+	}
+}
+
+func TestTOTPBackoff(t *testing.T) {
+	gate, err := newTOTPGate("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := gate.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	gate.sessions["verified"] = time.Now().Add(time.Hour)
+
+	guess := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, totpVerifyPath, strings.NewReader("code=000000"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Tunnel visitors all arrive from loopback and share one count
+	for i := range totpMaxFailures {
+		addr := []string{"127.0.0.1:40000", "[::1]:40001"}[i%2]
+		if code := guess(addr); code != http.StatusUnauthorized {
+			t.Fatalf("guess %d = %d, want 401", i+1, code)
+		}
+	}
+	if code := guess("127.0.0.1:40002"); code != http.StatusTooManyRequests {
+		t.Errorf("guess after %d failures = %d, want 429", totpMaxFailures, code)
+	}
+	if code := guess("192.168.1.20:40000"); code != http.StatusUnauthorized {
+		t.Errorf("guess from another LAN client = %d, want 401", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:40003"
+	req.AddCookie(&http.Cookie{Name: totpCookie, Value: "verified"})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("verified session during backoff = %d, want 200", rec.Code)
+	}
+
+	// The wait is short at first, and grows to at most totpMaxBackoff
+	now := time.Now()
+	if wait := gate.retryAfter("loopback", now.Add(totpBackoff)); wait != 0 {
+		t.Errorf("wait after %v = %v, want none", totpBackoff, wait)
+	}
+	gate.mu.Lock()
+	for range 40 {
+		gate.failures["loopback"] = append(gate.failures["loopback"], now)
+	}
+	gate.mu.Unlock()
+	if wait := gate.retryAfter("loopback", now); wait != totpMaxBackoff {
+		t.Errorf("wait after many failures = %v, want %v", wait, totpMaxBackoff)
+	}
+	if wait := gate.retryAfter("loopback", now.Add(totpFailureWindow+time.Second)); wait != 0 {
+		t.Errorf("wait once the failures are old = %v, want none", wait)
+	}
+}
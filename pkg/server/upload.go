@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxUploadSize is the largest request body accepted by an upload.
+const MaxUploadSize = 1 << 30 // 1 GB
+
+// uploadIdleTimeout is how long an upload can go without receiving any
+// data before the connection is dropped. A variable so tests can shorten
+// it.
+var uploadIdleTimeout = time.Minute
+
+// uploadDeadline lets an upload's body take as long as it needs, as long
+// as data keeps arriving: the read deadline is pushed uploadIdleTimeout
+// ahead on every read. The write deadline is lifted so the response can
+// still be sent when the body is done.
+func uploadDeadline(w http.ResponseWriter, body io.Reader) io.Reader {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	return &idleReader{r: body, rc: rc}
+}
+
+// idleReader extends the connection's read deadline before each read.
+type idleReader struct {
+	r  io.Reader
+	rc *http.ResponseController
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	ir.rc.SetReadDeadline(time.Now().Add(uploadIdleTimeout))
+	return ir.r.Read(p)
+}
+
+// sniffLen is the number of bytes inspected to detect a file's type,
+// matching http.DetectContentType.
+const sniffLen = 512
+
+// executableSignatures maps magic numbers that http.DetectContentType
+// reports as application/octet-stream to more specific types, so they
+// can be rejected by name.
+var executableSignatures = []struct {
+	magic       []byte
+	contentType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte{0xfe, 0xed, 0xfa, 0xce}, "application/x-mach-binary"},
+	{[]byte{0xfe, 0xed, 0xfa, 0xcf}, "application/x-mach-binary"},
+	{[]byte{0xce, 0xfa, 0xed, 0xfe}, "application/x-mach-binary"},
+	{[]byte{0xcf, 0xfa, 0xed, 0xfe}, "application/x-mach-binary"},
+	{[]byte{0xca, 0xfe, 0xba, 0xbe}, "application/x-mach-binary"},
+	{[]byte("#!"), "text/x-shellscript"},
+}
+
+// detectContentType returns the media type of a file from its first bytes.
+// Executables are recognized in addition to the types known to
+// http.DetectContentType.
+func detectContentType(head []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.contentType
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// rejectsContentType reports whether contentType matches one of the
+// rejected types. Entries may be exact media types or "type/*".
+func (s *Server) rejectsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for _, reject := range s.rejectTypes {
+		reject = strings.ToLower(strings.TrimSpace(reject))
+		if prefix, ok := strings.CutSuffix(reject, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if mediaType == reject {
+			return true
+		}
+	}
+	return false
+}
+
+// errRejectedType is returned by saveUpload when a file's detected type is
+// in the reject list.
+type errRejectedType struct {
+	name        string
+	contentType string
+}
+
+func (e *errRejectedType) Error() string {
+	return fmt.Sprintf("%s: file type %s is not allowed", e.name, e.contentType)
+}
+
+// handleUpload saves the files of a multipart POST into dirPath and
+// redirects back to the directory.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, dirPath string) {
+	info, err := os.Stat(dirPath)
+	if err != nil || !info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, io.NopCloser(uploadDeadline(w, r.Body)), MaxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart/form-data upload", http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Failed to read upload", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		err = s.saveUpload(dirPath, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+}
+
+// saveUpload writes an uploaded file into dirPath, refusing to overwrite
+// existing files. The content type is sniffed before anything is written.
+func (s *Server) saveUpload(dirPath, fileName string, src io.Reader) error {
+	name := filepath.Base(filepath.Clean("/" + filepath.ToSlash(fileName)))
+	if name == "/" || name == "." || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid file name %q", fileName)
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+
+	if contentType := detectContentType(head); s.rejectsContentType(contentType) {
+		return &errRejectedType{name: name, contentType: contentType}
+	}
+
+	path := filepath.Join(dirPath, name)
+	if urlPath, ok := s.urlPathOf(path); !ok || s.excluded(urlPath) || isAuthFile(path) {
+		return fmt.Errorf("%s is excluded: %w", name, os.ErrPermission)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists: %w", name, os.ErrExist)
+		}
+		return err
+	}
+
+	if _, err := io.Copy(f, io.MultiReader(bytes.NewReader(head), src)); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowUpload posts a multipart upload of name to the server in pieces,
+// pausing gap between them, and returns the response status.
+func slowUpload(t *testing.T, port int, name string, contents []byte, pieces int, gap time.Duration) (int, error) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(contents)
+	mw.Close()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", mw.FormDataContentType(), body.Len())
+
+	data := body.Bytes()
+	size := (len(data) + pieces - 1) / pieces
+	for len(data) > 0 {
+		time.Sleep(gap)
+		n := min(size, len(data))
+		if _, err := conn.Write(data[:n]); err != nil {
+			return 0, err
+		}
+		data = data[n:]
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func TestUploadDeadline(t *testing.T) {
+	old := uploadIdleTimeout
+	uploadIdleTimeout = 300 * time.Millisecond
+	t.Cleanup(func() { uploadIdleTimeout = old })
+
+	dir := t.TempDir()
+	s := startTestServer(t, Config{Directory: dir, EnableUpload: true})
+	if s.server.ReadTimeout != 0 || s.server.ReadHeaderTimeout == 0 {
+		t.Errorf("ReadTimeout = %v, ReadHeaderTimeout = %v; want only a header timeout", s.server.ReadTimeout, s.server.ReadHeaderTimeout)
+	}
+	contents := bytes.Repeat([]byte("qrlocal "), 4096)
+
+	t.Run("slow but steady", func(t *testing.T) {
+		// Takes several idle timeouts in total, but never stalls for one
+		status, err := slowUpload(t, s.Port(), "steady.txt", contents, 10, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+		if status != http.StatusSeeOther {
+			t.Fatalf("status = %d, want 303", status)
+		}
+		saved, err := os.ReadFile(filepath.Join(dir, "steady.txt"))
+		if err != nil || !bytes.Equal(saved, contents) {
+			t.Errorf("saved file doesn't match the upload (%v)", err)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		status, err := slowUpload(t, s.Port(), "stalled.txt", contents, 2, time.Second)
+		if err == nil && status < 400 {
+			t.Errorf("stalled upload succeeded with status %d", status)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "stalled.txt")); err == nil {
+			t.Error("stalled upload was saved")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrRootUnavailable is reported when the served directory disappears or
+// is replaced, e.g. when the USB drive or network share it lives on is
+// unmounted.
+var ErrRootUnavailable = errors.New("served directory is unavailable")
+
+// checkRoot returns an error wrapping ErrRootUnavailable if the served
+// directory is gone. A directory that is no longer the one the server
+// started with counts as gone too, since that is what an unmounted drive
+// leaves behind: the empty mount point.
+func (s *Server) checkRoot() error {
+	info, err := os.Stat(s.directory)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRootUnavailable, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is no longer a directory", ErrRootUnavailable, s.directory)
+	}
+	if !os.SameFile(info, s.rootInfo) {
+		return fmt.Errorf("%w: %s was unmounted or replaced", ErrRootUnavailable, s.directory)
+	}
+	return nil
+}
+
+// RootLost returns a channel that receives an error wrapping
+// ErrRootUnavailable the first time the served directory is found to be
+// gone, by a request or by the Config.WatchRoot check. It receives at
+// most one error.
+func (s *Server) RootLost() <-chan error {
+	return s.rootLost
+}
+
+// reportRootLost sends err on the RootLost channel the first time it is
+// called.
+func (s *Server) reportRootLost(err error) {
+	s.rootLostOnce.Do(func() {
+		s.rootLost <- err
+	})
+}
+
+// rootUnavailable answers a request whose file or directory couldn't be
+// read with 503 and reports true if the cause is the served directory
+// being gone, rather than a single missing file.
+func (s *Server) rootUnavailable(w http.ResponseWriter) bool {
+	err := s.checkRoot()
+	if err == nil {
+		return false
+	}
+	s.reportRootLost(err)
+	http.Error(w, "The shared directory is no longer available", http.StatusServiceUnavailable)
+	return true
+}
+
+// watchRoot checks the served directory every interval until the server
+// stops, reporting when it is gone.
+func (s *Server) watchRoot(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.checkRoot(); err != nil {
+				s.reportRootLost(err)
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with
+// the defaults used by authenticator apps: HMAC-SHA1, 6 digits, 30 seconds.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Digits is the length of a generated code.
+	Digits = 6
+
+	// Period is how long each code is valid.
+	Period = 30 * time.Second
+
+	// Skew is the number of periods before and after the current one whose
+	// codes are also accepted, to allow for clock drift and typing time.
+	Skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32-encoded secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// decodeSecret parses a base32 secret, ignoring case, spaces and padding.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	key, err := encoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid TOTP secret: must be base32")
+	}
+	return key, nil
+}
+
+// ValidateSecret checks that secret can be used to generate codes.
+func ValidateSecret(secret string) error {
+	_, err := decodeSecret(secret)
+	return err
+}
+
+// Code returns the code for secret at time t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, counter(t)), nil
+}
+
+// Remaining returns how long the code for time t stays valid.
+func Remaining(t time.Time) time.Duration {
+	elapsed := time.Duration(t.UnixNano()) % Period
+	return Period - elapsed
+}
+
+// Validate reports whether code is valid for secret at time t, allowing
+// Skew periods of drift in either direction.
+func Validate(secret, code string, t time.Time) bool {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != Digits {
+		return false
+	}
+
+	now := counter(t)
+	for i := -Skew; i <= Skew; i++ {
+		want := codeFor(key, now, i)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// codeFor returns the code offset periods away from counter c.
+func codeFor(key []byte, c uint64, offset int) string {
+	return code(key, uint64(int64(c)+int64(offset)))
+}
+
+// counter returns the RFC 6238 time step for t.
+func counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period/time.Second))
+}
+
+// code computes the HOTP value (RFC 4226) for key and counter c.
+func code(key []byte, c uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], c)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
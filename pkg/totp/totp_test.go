@@ -0,0 +1,52 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC's 8-digit codes, cut to their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	for steps := -3; steps <= 3; steps++ {
+		code, err := Code(rfcSecret, now.Add(time.Duration(steps)*Period))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := steps >= -Skew && steps <= Skew
+		if got := Validate(rfcSecret, code, now); got != want {
+			t.Errorf("code from %d steps away: Validate = %t, want %t", steps, got, want)
+		}
+	}
+
+	if Validate(rfcSecret, "", now) || Validate("not base32!", "050471", now) {
+		t.Error("Validate accepted an empty code or an invalid secret")
+	}
+}
@@ -0,0 +1,78 @@
+package tunnel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability is a set of optional features a provider supports, so the CLI
+// can refuse unsupported flags up front instead of building a forward the
+// provider will reject.
+type Capability uint
+
+// Provider capabilities.
+const (
+	CapHTTPS     Capability = 1 << iota // Public URLs are served over HTTPS
+	CapTCP                              // Raw TCP forwarding (--tcp)
+	CapSubdomain                        // A custom subdomain can be requested
+	CapToken                            // An access token can be supplied
+)
+
+// capabilityNames lists the config names of the capabilities in order.
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapHTTPS, "https"},
+	{CapTCP, "tcp"},
+	{CapSubdomain, "subdomain"},
+	{CapToken, "token"},
+}
+
+// ParseCapabilities parses capability names such as "https" or "tcp".
+func ParseCapabilities(names []string) (Capability, error) {
+	var caps Capability
+	for _, name := range names {
+		found := false
+		for _, c := range capabilityNames {
+			if strings.EqualFold(strings.TrimSpace(name), c.name) {
+				caps |= c.cap
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown capability %q (use https, tcp, subdomain or token)", name)
+		}
+	}
+	return caps, nil
+}
+
+// Has reports whether c includes every capability in other.
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+// Names returns the config names of the capabilities in c.
+func (c Capability) Names() []string {
+	var names []string
+	for _, n := range capabilityNames {
+		if c.Has(n.cap) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// String returns the capability names separated by commas.
+func (c Capability) String() string {
+	return strings.Join(c.Names(), ", ")
+}
+
+// Require returns an error naming feature when the provider lacks cap.
+func (p Provider) Require(cap Capability, feature string) error {
+	if !p.Capabilities.Has(cap) {
+		return fmt.Errorf("provider %s does not support %s", p.Name, feature)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package tunnel
+
+import (
+	"fmt"
+	"time"
+)
+
+// Command is the ssh command line of a tunnel, for running the tunnel
+// outside qrlocal.
+type Command struct {
+	// Args is the ssh program followed by its arguments.
+	Args []string
+
+	// KnownHosts is the known_hosts entry for a provider's pinned HostKey,
+	// which Args expect in the file given to SSHCommand. It is empty when
+	// Args need no such file.
+	KnownHosts string
+
+	// URLNote says how qrlocal finds the public URL in the provider's
+	// output.
+	URLNote string
+}
+
+// SSHCommand returns the ssh command that a tunnel with cfg runs, without
+// running it. Only the provider, local host and port, timeout and TCP mode
+// of cfg are used. qrlocal writes a pinned HostKey to a temporary file
+// while the tunnel is open; the returned Args refer to knownHostsPath
+// instead, where the caller should write Command.KnownHosts.
+func SSHCommand(cfg Config, knownHostsPath string) (Command, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.LocalHost == "" {
+		cfg.LocalHost = DefaultLocalHost
+	}
+	provider := cfg.Provider
+	if cfg.TCP {
+		var err error
+		if provider, err = provider.tcpMode(); err != nil {
+			return Command{}, err
+		}
+	}
+
+	var cmd Command
+	var hostKeyArgs []string
+	if provider.HostKey != "" && provider.KnownHostsFile == "" {
+		hostKeyArgs = strictHostKeyArgs(knownHostsPath)
+		cmd.KnownHosts = provider.knownHostsEntry()
+	} else {
+		// Nothing to clean up without a pinned HostKey
+		var err error
+		if hostKeyArgs, _, err = provider.hostKeyArgs(); err != nil {
+			return Command{}, err
+		}
+	}
+
+	args, err := buildSSHArgs(provider, cfg.LocalHost, cfg.LocalPort, cfg.Timeout, hostKeyArgs)
+	if err != nil {
+		return Command{}, err
+	}
+	cmd.Args = append([]string{"ssh"}, args...)
+	cmd.URLNote = provider.urlNote()
+	return cmd, nil
+}
+
+// urlNote describes how findURL reads the public URL from the provider's
+// output.
+func (p Provider) urlNote() string {
+	match := fmt.Sprintf("the first output line matching %s, using its first capture group if it has one", p.URLRegex)
+	if p.JSONOutput {
+		return "qrlocal takes the public URL from the address of the first successful JSON event, or else from " + match
+	}
+	return "qrlocal takes the public URL from " + match
+}
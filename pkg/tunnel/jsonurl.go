@@ -0,0 +1,65 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// jsonOutputCommand is the remote command that makes localhost.run print
+// one JSON object per event instead of its text banner.
+var jsonOutputCommand = []string{"--", "--output", "json"}
+
+// jsonEvent is the part of a localhost.run JSON event qrlocal reads.
+type jsonEvent struct {
+	Event   string `json:"event"`
+	Status  string `json:"status"`
+	Address string `json:"address"`
+}
+
+// urlFromJSON returns the public URL from a JSON event line such as
+//
+//	{"event":"tunnel","status":"success","address":"abc123.lhr.life",...}
+//
+// It reports false for lines that aren't JSON, events without an address
+// and unsuccessful events, so the caller can fall back to the URL regex.
+func urlFromJSON(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return "", false
+	}
+
+	var event jsonEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return "", false
+	}
+	if event.Address == "" || (event.Status != "" && event.Status != "success") {
+		return "", false
+	}
+
+	address := event.Address
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	return address, true
+}
+
+// findURL returns the public URL in a line of ssh output. Providers with
+// JSONOutput prefer the structured event and fall back to URLRegex, in
+// case the provider ignores the request for JSON.
+func (p Provider) findURL(line string) (string, bool) {
+	if p.JSONOutput {
+		if url, ok := urlFromJSON(line); ok {
+			return url, true
+		}
+	}
+
+	matches := p.URLRegex.FindStringSubmatch(line)
+	if len(matches) == 0 {
+		return "", false
+	}
+	// Use first capture group if exists, otherwise full match
+	if len(matches) > 1 && matches[1] != "" {
+		return matches[1], true
+	}
+	return matches[0], true
+}
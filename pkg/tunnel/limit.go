@@ -0,0 +1,53 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrProviderLimit is returned by NewTunnel when a provider already has
+// MaxConcurrent open tunnels and none closed within the timeout.
+var ErrProviderLimit = errors.New("provider connection limit reached")
+
+// providerSlots limits the number of open tunnels per provider name. Each
+// provider with a limit gets a buffered channel used as a semaphore.
+var providerSlots = struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}{slots: make(map[string]chan struct{})}
+
+// acquireSlot reserves a tunnel slot for p, waiting up to timeout for one
+// to be released, or until ctx is done. It returns a func that releases
+// the slot. Providers without a limit always succeed.
+func acquireSlot(ctx context.Context, p Provider, timeout time.Duration) (func(), error) {
+	if p.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	providerSlots.mu.Lock()
+	sem, ok := providerSlots.slots[p.Name]
+	if !ok || cap(sem) != p.MaxConcurrent {
+		sem = make(chan struct{}, p.MaxConcurrent)
+		providerSlots.slots[p.Name] = sem
+	}
+	providerSlots.mu.Unlock()
+
+	release := func() { <-sem }
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%w: %s allows %d simultaneous tunnels", ErrProviderLimit, p.Name, p.MaxConcurrent)
+	case <-ctx.Done():
+		return nil, cancelledError(ctx)
+	}
+}
@@ -0,0 +1,130 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProbeForwards are the remote forward specs ProbeRemoteForward tries, in
+// order: the fixed port 80 most providers expect, a port the provider
+// picks, and 443.
+var ProbeForwards = []string{
+	"80:{{.Host}}:{{.Port}}",
+	"0:{{.Host}}:{{.Port}}",
+	"443:{{.Host}}:{{.Port}}",
+}
+
+// probeTimeout bounds each probe: the wait for the URL, then the wait for
+// the URL to reach the probe server.
+const probeTimeout = 20 * time.Second
+
+// ProbeResult is the outcome of trying one remote forward spec.
+type ProbeResult struct {
+	RemoteForward string
+	URL           string // Public URL the provider printed, if any
+	Err           error  // Why the spec didn't work; nil if it did
+}
+
+// ProbeRemoteForward finds out which of ProbeForwards work with provider.
+// It serves a random token on a local port, opens a tunnel to it with
+// each spec in turn, and checks that the public URL the provider prints
+// answers with the token. progress, when non-nil, is called before each
+// attempt.
+func ProbeRemoteForward(ctx context.Context, provider Provider, progress func(spec string)) ([]ProbeResult, error) {
+	token, err := probeToken()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(DefaultLocalHost, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start probe server: %w", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, token)
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	results := make([]ProbeResult, 0, len(ProbeForwards))
+	for _, spec := range ProbeForwards {
+		if progress != nil {
+			progress(spec)
+		}
+		result := ProbeResult{RemoteForward: spec}
+		result.URL, result.Err = probeForward(ctx, provider, spec, listener.Addr().(*net.TCPAddr).Port, token)
+		results = append(results, result)
+		if ctx.Err() != nil {
+			return results, cancelledError(ctx)
+		}
+	}
+	return results, nil
+}
+
+// probeForward opens a tunnel to port with the remote forward spec and
+// checks that its URL answers with token.
+func probeForward(ctx context.Context, provider Provider, spec string, port int, token string) (string, error) {
+	provider.RemoteForward = spec
+	t, err := NewTunnelContext(ctx, Config{
+		LocalPort:      port,
+		Provider:       provider,
+		Timeout:        probeTimeout,
+		ConnectRetries: -1,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer t.Close()
+
+	url := t.PublicURL()
+	return url, waitForToken(ctx, url, token)
+}
+
+// waitForToken requests url until it answers with token, for up to
+// probeTimeout. Providers answer with an error page while the forward
+// isn't working.
+func waitForToken(ctx context.Context, url, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	lastErr := fmt.Errorf("no answer from %s", url)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+			resp.Body.Close()
+			if strings.TrimSpace(string(body)) == token {
+				return nil
+			}
+			lastErr = fmt.Errorf("%s answered HTTP %s without reaching the local port", url, resp.Status)
+		} else if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(verifyInterval):
+		}
+	}
+}
+
+// probeToken returns a random token for the probe server to answer with.
+func probeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "qrlocal-probe-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,103 @@
+//go:build unix
+
+package tunnel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeSSH is an ssh stand-in that starts a child, as a ProxyCommand
+// would, records its pid, prints a URL and waits.
+const fakeSSH = `#!/bin/sh
+sleep 300 &
+echo $! > "$FAKE_SSH_CHILD"
+echo "https://fake.example.test"
+wait
+`
+
+func TestCloseKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(fakeSSH), 0755); err != nil {
+		t.Fatal(err)
+	}
+	childFile := filepath.Join(dir, "child")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_SSH_CHILD", childFile)
+
+	tun, err := NewTunnel(Config{
+		LocalPort: 8080,
+		Timeout:   10 * time.Second,
+		Provider: Provider{
+			Name:     "fake",
+			Host:     "fake.example.test",
+			Port:     "22",
+			User:     "nokey",
+			URLRegex: regexp.MustCompile(`https://\S+`),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTunnel: %v", err)
+	}
+	if got := tun.PublicURL(); got != "https://fake.example.test" {
+		t.Fatalf("PublicURL = %q", got)
+	}
+
+	pgid := tun.cmd.Process.Pid
+	child := waitForPid(t, childFile)
+	if err := syscall.Kill(child, 0); err != nil {
+		t.Fatalf("child %d not running before Close: %v", child, err)
+	}
+
+	if err := tun.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	waitGone(t, "ssh process", pgid)
+	waitGone(t, "child process", child)
+	waitGone(t, "process group", -pgid)
+}
+
+// waitForPid reads the pid the fake ssh wrote to path.
+func waitForPid(t *testing.T, path string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(path)
+		if err == nil && strings.HasSuffix(string(data), "\n") {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatalf("bad child pid %q", data)
+			}
+			return pid
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("fake ssh didn't record its child")
+	return 0
+}
+
+// waitGone waits until signalling pid (or a group, when negative) fails
+// with ESRCH. Killed processes that nobody has reaped yet still count as
+// running, so this allows a moment for init to collect them.
+func waitGone(t *testing.T, what string, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := syscall.Kill(pid, 0)
+		if errors.Is(err, syscall.ESRCH) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s %d still exists after Close (kill: %v)", what, pid, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
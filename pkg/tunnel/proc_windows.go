@@ -0,0 +1,23 @@
+//go:build windows
+
+package tunnel
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; killProcessTree walks the tree instead.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessTree kills cmd and all of its child processes.
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+	if err := kill.Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package tunnel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sshOptionsWithValue are the ssh options that take an argument, which may
+// follow as the next argument.
+const sshOptionsWithValue = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// ValidateSSHArgs checks that args are ssh options, each optionally
+// followed by its value, so they can't be mistaken for the destination
+// or a remote command. The options themselves are passed to ssh verbatim.
+func ValidateSSHArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			return fmt.Errorf("invalid ssh option %q: options must start with '-'", arg)
+		}
+
+		// A value-taking option such as -o or -J without an attached
+		// value consumes the next argument
+		if len(arg) == 2 && strings.IndexByte(sshOptionsWithValue, arg[1]) >= 0 {
+			if i+1 == len(args) {
+				return fmt.Errorf("ssh option %s needs a value", arg)
+			}
+			i++
+		}
+	}
+	return nil
+}
+
+// ParseSSHOption turns one --ssh-opt value into ssh arguments. "Key=Value"
+// becomes "-o Key=Value"; values starting with '-' are an option and its
+// value, separated by the first space, e.g. "-J bastion".
+func ParseSSHOption(opt string) ([]string, error) {
+	opt = strings.TrimSpace(opt)
+
+	var args []string
+	if strings.HasPrefix(opt, "-") {
+		flag, value, ok := strings.Cut(opt, " ")
+		args = []string{flag}
+		if value = strings.TrimSpace(value); ok && value != "" {
+			args = append(args, value)
+		}
+	} else {
+		args = []string{"-o", opt}
+	}
+
+	if err := ValidateSSHArgs(args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
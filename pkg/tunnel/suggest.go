@@ -0,0 +1,56 @@
+package tunnel
+
+import (
+	"strings"
+
+	"github.com/hash/qrlocal/pkg/config"
+)
+
+// SuggestProvider returns the known provider name closest to a misspelled
+// one, for a "did you mean" hint. Built-in, custom and shared providers
+// are considered; names more than a couple of edits away aren't
+// suggested. It reports false when nothing is close enough.
+func SuggestProvider(name string, cfg *config.Config) (string, bool) {
+	candidates := ListBuiltinProviders()
+	if cfg != nil {
+		for _, info := range cfg.ProviderInfos() {
+			candidates = append(candidates, info.Name)
+		}
+	}
+
+	// Allow one edit per three characters, at most two
+	limit := min(2, max(1, len(name)/3))
+
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, best != ""
+}
+
+// levenshtein returns the number of single-character insertions,
+// deletions and substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
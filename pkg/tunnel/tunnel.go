@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"os/exec"
 	"regexp"
@@ -14,9 +15,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/hash/qrlocal/pkg/config"
+	"github.com/hash/qrlocal/pkg/network"
 )
 
 // Provider represents a tunneling service provider.
@@ -26,6 +29,111 @@ type Provider struct {
 	Port     string
 	User     string
 	URLRegex *regexp.Regexp
+
+	// ClientLabel identifies this client to the provider. It is sent as the
+	// QRLOCAL_CLIENT environment variable, which providers may log or show.
+	ClientLabel string
+
+	// RemoteForward is a text/template for the ssh -R forward spec, executed
+	// with ForwardData. Empty means DefaultRemoteForward.
+	RemoteForward string
+
+	// TCPURLRegex matches the host:port assigned in raw TCP mode; nil means
+	// the provider only supports HTTP tunnels. TCPRemoteForward replaces
+	// RemoteForward in TCP mode when set.
+	TCPURLRegex      *regexp.Regexp
+	TCPRemoteForward string
+
+	// Capabilities lists the optional features the provider supports.
+	Capabilities Capability
+
+	// MaxConcurrent caps the number of tunnels open to this provider at
+	// once; zero means unlimited. Extra tunnels wait for a free slot until
+	// their timeout and then fail with ErrProviderLimit.
+	MaxConcurrent int
+
+	// ExtraArgs are ssh options passed verbatim before the destination,
+	// e.g. "-J", "bastion". See ValidateSSHArgs.
+	ExtraArgs []string
+
+	// HostKey pins the provider's SSH host key, given as in a known_hosts
+	// file without the host names, e.g. "ssh-ed25519 AAAAC3Nz...".
+	// KnownHostsFile instead names a known_hosts file listing the host.
+	// Either makes ssh refuse a host with another key; without them any
+	// host key is accepted.
+	HostKey        string
+	KnownHostsFile string
+
+	// JSONOutput asks the provider for localhost.run-style JSON events and
+	// reads the URL from their address field, falling back to URLRegex
+	// for lines that aren't JSON.
+	JSONOutput bool
+}
+
+// DefaultRemoteForward forwards remote port 80 to the local port.
+const DefaultRemoteForward = "80:{{.Host}}:{{.Port}}"
+
+// DefaultLocalHost is the host the tunnel forwards to when none is given.
+const DefaultLocalHost = "localhost"
+
+// ForwardData is the data passed to a provider's RemoteForward template.
+type ForwardData struct {
+	Host string // Host the tunnel forwards to, usually localhost
+	Port int    // Local port being shared
+}
+
+// remoteForwardSpec renders the provider's remote forward spec for
+// localHost:localPort. IPv6 addresses are bracketed, as ssh's -R syntax
+// requires.
+func (p Provider) remoteForwardSpec(localHost string, localPort int) (string, error) {
+	if strings.Contains(localHost, ":") && !strings.HasPrefix(localHost, "[") {
+		localHost = "[" + localHost + "]"
+	}
+	text := p.RemoteForward
+	if text == "" {
+		text = DefaultRemoteForward
+	}
+
+	tmpl, err := template.New("remote_forward").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote forward for provider %s: %w", p.Name, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, ForwardData{Host: localHost, Port: localPort}); err != nil {
+		return "", fmt.Errorf("invalid remote forward for provider %s: %w", p.Name, err)
+	}
+	return sb.String(), nil
+}
+
+// tcpMode returns a copy of p configured for raw TCP forwarding.
+func (p Provider) tcpMode() (Provider, error) {
+	if !p.SupportsTCP() {
+		return Provider{}, fmt.Errorf("provider %s does not support TCP tunnels", p.Name)
+	}
+	p.URLRegex = p.TCPURLRegex
+	p.JSONOutput = false
+	if p.TCPRemoteForward != "" {
+		p.RemoteForward = p.TCPRemoteForward
+	}
+	return p, nil
+}
+
+// SupportsTCP reports whether the provider can forward raw TCP.
+func (p Provider) SupportsTCP() bool {
+	return p.Capabilities.Has(CapTCP) && p.TCPURLRegex != nil
+}
+
+// clientLabelRegex restricts client labels to characters that are safe to
+// pass through an ssh option.
+var clientLabelRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidateClientLabel checks that label can be used as a client label.
+func ValidateClientLabel(label string) error {
+	if !clientLabelRegex.MatchString(label) {
+		return fmt.Errorf("invalid client label %q: use up to 64 letters, digits, '.', '_' or '-'", label)
+	}
+	return nil
 }
 
 // Common tunneling providers (defaults, can be overridden by config)
@@ -36,6 +144,10 @@ var (
 		Port:     "22",
 		User:     "nokey",
 		URLRegex: regexp.MustCompile(`https://[a-zA-Z0-9]+\.lhr\.life`),
+		// The banner text changes now and then; the JSON events don't
+		JSONOutput: true,
+
+		Capabilities: CapHTTPS,
 	}
 
 	Pinggy = Provider{
@@ -44,6 +156,10 @@ var (
 		Port:     "443",
 		User:     "a",
 		URLRegex: regexp.MustCompile(`https://[a-zA-Z0-9-]+\.a\.free\.pinggy\.link`),
+		// Pinggy requires port 0 for dynamic allocation
+		RemoteForward: "0:{{.Host}}:{{.Port}}",
+
+		Capabilities: CapHTTPS | CapToken,
 	}
 
 	Serveo = Provider{
@@ -53,6 +169,11 @@ var (
 		User: "serveo",
 		// Match the "Forwarding HTTP traffic from https://..." line
 		URLRegex: regexp.MustCompile(`Forwarding HTTP traffic from (https://[a-zA-Z0-9-]+\.(?:serveo\.net|serveousercontent\.com))`),
+		// Match the "Forwarding TCP connect from serveo.net:PORT" line
+		TCPURLRegex:      regexp.MustCompile(`Forwarding TCP connect from ([a-zA-Z0-9.-]+:\d+)`),
+		TCPRemoteForward: "0:{{.Host}}:{{.Port}}",
+
+		Capabilities: CapHTTPS | CapTCP | CapSubdomain,
 	}
 
 	TunnelTo = Provider{
@@ -61,6 +182,8 @@ var (
 		Port:     "22",
 		User:     "tunnel",
 		URLRegex: regexp.MustCompile(`https://[a-zA-Z0-9-]+\.tunnel\.to`),
+
+		Capabilities: CapHTTPS | CapSubdomain | CapToken,
 	}
 )
 
@@ -71,27 +194,110 @@ func ProviderFromConfig(name string, cfg config.ProviderConfig) (Provider, error
 		return Provider{}, fmt.Errorf("invalid URL regex for provider %s: %w", name, err)
 	}
 
-	return Provider{
-		Name:     name,
-		Host:     cfg.Host,
-		Port:     strconv.Itoa(cfg.Port),
-		User:     cfg.User,
-		URLRegex: regex,
-	}, nil
+	if cfg.MaxConcurrent < 0 {
+		return Provider{}, fmt.Errorf("provider %s: max_concurrent must not be negative", name)
+	}
+
+	caps, err := ParseCapabilities(cfg.Capabilities)
+	if err != nil {
+		return Provider{}, fmt.Errorf("provider %s: %w", name, err)
+	}
+
+	if cfg.ClientLabel != "" {
+		if err := ValidateClientLabel(cfg.ClientLabel); err != nil {
+			return Provider{}, fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+
+	if err := ValidateSSHArgs(cfg.ExtraArgs); err != nil {
+		return Provider{}, fmt.Errorf("provider %s: extra_args: %w", name, err)
+	}
+
+	// ssh and DNS need internationalized domain names in punycode
+	host, err := network.NormalizeHost(cfg.Host)
+	if err != nil {
+		return Provider{}, fmt.Errorf("provider %s: %w", name, err)
+	}
+
+	p := Provider{
+		Name:             name,
+		Host:             host,
+		Port:             strconv.Itoa(cfg.Port),
+		User:             cfg.User,
+		URLRegex:         regex,
+		ClientLabel:      cfg.ClientLabel,
+		RemoteForward:    cfg.RemoteForward,
+		TCPRemoteForward: cfg.TCPRemoteForward,
+		MaxConcurrent:    cfg.MaxConcurrent,
+		Capabilities:     caps,
+		ExtraArgs:        cfg.ExtraArgs,
+		HostKey:          cfg.HostKey,
+		KnownHostsFile:   cfg.KnownHosts,
+		JSONOutput:       cfg.JSONOutput,
+	}
+
+	if p.HostKey != "" && p.KnownHostsFile != "" {
+		return Provider{}, fmt.Errorf("provider %s: set host_key or known_hosts, not both", name)
+	}
+	if p.HostKey != "" {
+		if err := ValidateHostKey(p.HostKey); err != nil {
+			return Provider{}, fmt.Errorf("provider %s: %w", name, err)
+		}
+	}
+
+	if cfg.TCPURLRegex != "" {
+		p.TCPURLRegex, err = regexp.Compile(cfg.TCPURLRegex)
+		if err != nil {
+			return Provider{}, fmt.Errorf("invalid TCP URL regex for provider %s: %w", name, err)
+		}
+		// A TCP URL regex is all TCP forwarding needs
+		p.Capabilities |= CapTCP
+	} else if p.Capabilities.Has(CapTCP) {
+		return Provider{}, fmt.Errorf("provider %s: the tcp capability requires tcp_url_regex", name)
+	}
+
+	// Catch template mistakes before connecting
+	if _, err := p.remoteForwardSpec(DefaultLocalHost, 1); err != nil {
+		return Provider{}, err
+	}
+	if p.TCPURLRegex != nil {
+		tcp, _ := p.tcpMode()
+		if _, err := tcp.remoteForwardSpec(DefaultLocalHost, 1); err != nil {
+			return Provider{}, err
+		}
+	}
+
+	return p, nil
 }
 
-// GetProvider returns a Provider by name, checking built-in defaults first then config overrides.
+// ErrUnknownProvider is returned by GetProvider when no built-in or
+// configured provider has the given name.
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// GetProvider returns a Provider by name. Built-in providers use the
+// settings from cfg when present, so config overrides take effect, and
+// fall back to the compiled-in defaults otherwise.
 func GetProvider(name string, cfg *config.Config) (Provider, error) {
 	// First check built-in providers
+	var builtin *Provider
 	switch strings.ToLower(name) {
 	case "localhost.run", "localhostrun":
-		return LocalhostRun, nil
+		builtin = &LocalhostRun
 	case "pinggy", "pinggy.io":
-		return Pinggy, nil
+		builtin = &Pinggy
 	case "serveo", "serveo.net":
-		return Serveo, nil
+		builtin = &Serveo
 	case "tunnelto", "tunnel.to":
-		return TunnelTo, nil
+		builtin = &TunnelTo
+	}
+
+	if builtin != nil {
+		if cfg != nil {
+			if provCfg, ok := cfg.Providers[builtin.Name]; ok {
+				return ProviderFromConfig(builtin.Name, provCfg)
+			}
+		}
+		return *builtin, nil
 	}
 
 	// Check config for custom providers
@@ -101,7 +307,7 @@ func GetProvider(name string, cfg *config.Config) (Provider, error) {
 		}
 	}
 
-	return Provider{}, fmt.Errorf("unknown provider: %s", name)
+	return Provider{}, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
 }
 
 // ListBuiltinProviders returns the names of all built-in providers.
@@ -109,76 +315,230 @@ func ListBuiltinProviders() []string {
 	return []string{"localhost.run", "pinggy", "serveo", "tunnelto"}
 }
 
+// EventType identifies the kind of lifecycle event emitted by a tunnel.
+type EventType int
+
+const (
+	// EventEstablished is emitted once the public URL has been received.
+	EventEstablished EventType = iota
+	// EventDropped is emitted when the SSH process exits without Close being called.
+	EventDropped
+)
+
+// String returns a human-readable name for the event type.
+func (e EventType) String() string {
+	switch e {
+	case EventEstablished:
+		return "established"
+	case EventDropped:
+		return "dropped"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change in the tunnel's lifecycle.
+type Event struct {
+	Type     EventType
+	URL      string
+	Provider string
+	Time     time.Time
+	Err      error
+}
+
 // Tunnel represents an active SSH tunnel.
 type Tunnel struct {
 	cmd       *exec.Cmd
 	publicURL string
+	connected time.Time
+	localHost string
 	localPort int
+	tcp       bool
 	ctx       context.Context
 	cancel    context.CancelFunc
 	provider  Provider
 	mu        sync.RWMutex
 	done      chan struct{}
+	events    chan Event
+	log       io.Writer // Provider output sink, nil to discard
 }
 
 // Config holds tunnel configuration.
 type Config struct {
+	LocalHost string // Host to forward to (default DefaultLocalHost)
 	LocalPort int
 	Provider  Provider
 	Timeout   time.Duration
+
+	// TCP forwards raw TCP instead of HTTP. The public URL is then a
+	// tcp://host:port address.
+	TCP bool
+
+	// ConnectRetries is how many more times to connect when ssh exits or
+	// times out without printing a URL (default DefaultConnectRetries,
+	// negative disables). Hard errors such as an unknown host aren't retried.
+	ConnectRetries int
+
+	// Log, when set, receives every line the provider prints, prefixed
+	// with its name, for as long as the tunnel is up. Lines after the URL
+	// are otherwise discarded.
+	Log io.Writer
+}
+
+// DefaultConnectRetries is the number of retries when Config.ConnectRetries
+// is zero.
+const DefaultConnectRetries = 2
+
+// Errors from a connection attempt that may succeed when retried, since
+// some providers print the URL only after a slow auth handshake.
+var (
+	errNoURL      = errors.New("SSH connection closed without providing URL")
+	errURLTimeout = errors.New("timeout waiting for tunnel URL")
+)
+
+// hardFailures are ssh messages that a retry won't fix.
+var hardFailures = []string{
+	"Could not resolve hostname",
+	"Permission denied",
+	"Host key verification failed",
+	"Connection refused",
+}
+
+// retryDelay returns the backoff before retry attempt (starting at 1):
+// one second doubled per attempt, plus up to 50% jitter so several tunnels
+// don't retry in lockstep.
+func retryDelay(attempt int) time.Duration {
+	delay := time.Second << (attempt - 1)
+	return delay + time.Duration(rand.Int63n(int64(delay/2)+1))
 }
 
 // NewTunnel creates a new SSH tunnel to the specified provider.
 func NewTunnel(cfg Config) (*Tunnel, error) {
+	return NewTunnelContext(context.Background(), cfg)
+}
+
+// NewTunnelContext is like NewTunnel, but the tunnel lives within ctx:
+// cancelling it aborts the connection attempt, or closes the tunnel once
+// it is established. Errors from a cancelled attempt wrap ctx.Err().
+func NewTunnelContext(ctx context.Context, cfg Config) (*Tunnel, error) {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = 30 * time.Second
 	}
+	if cfg.LocalHost == "" {
+		cfg.LocalHost = DefaultLocalHost
+	}
+	if cfg.ConnectRetries == 0 {
+		cfg.ConnectRetries = DefaultConnectRetries
+	}
+	if cfg.TCP {
+		provider, err := cfg.Provider.tcpMode()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Provider = provider
+	}
+
+	// Wait for a free slot on providers that limit concurrent sessions, so
+	// extra tunnels fail clearly instead of being rejected silently
+	release, err := acquireSlot(ctx, cfg.Provider, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 
 	tunnel := &Tunnel{
+		localHost: cfg.LocalHost,
 		localPort: cfg.LocalPort,
+		tcp:       cfg.TCP,
 		provider:  cfg.Provider,
+		log:       cfg.Log,
 		ctx:       ctx,
 		cancel:    cancel,
 		done:      make(chan struct{}),
+		events:    make(chan Event, 8),
 	}
 
-	if err := tunnel.connect(cfg.Timeout); err != nil {
-		cancel()
-		return nil, err
+	for attempt := 1; ; attempt++ {
+		err = tunnel.connect(cfg.Timeout)
+		if err == nil {
+			break
+		}
+		retryable := errors.Is(err, errNoURL) || errors.Is(err, errURLTimeout)
+		if !retryable || attempt > cfg.ConnectRetries {
+			cancel()
+			release()
+			return nil, err
+		}
+
+		select {
+		case <-time.After(retryDelay(attempt)):
+		case <-ctx.Done():
+			err := cancelledError(ctx)
+			cancel()
+			release()
+			return nil, err
+		}
 	}
 
+	go func() {
+		<-tunnel.done
+		release()
+	}()
+
 	return tunnel, nil
 }
 
-// connect establishes the SSH tunnel using the system's ssh command.
-func (t *Tunnel) connect(timeout time.Duration) error {
-	// Build SSH command arguments
-	// Format: -R remotePort:localhost:localPort
-	// Some providers (like pinggy) require port 0 for dynamic allocation
-	// while others use port 80 for standard HTTP forwarding
-	var remoteForward string
-	switch t.provider.Name {
-	case "pinggy":
-		remoteForward = fmt.Sprintf("0:localhost:%d", t.localPort)
-	default:
-		remoteForward = fmt.Sprintf("80:localhost:%d", t.localPort)
+// buildSSHArgs returns the ssh arguments for forwarding localHost:localPort
+// through provider. hostKeyArgs are the host key checking options from
+// Provider.hostKeyArgs.
+func buildSSHArgs(provider Provider, localHost string, localPort int, timeout time.Duration, hostKeyArgs []string) ([]string, error) {
+	// Format: -R remotePort:localHost:localPort
+	remoteForward, err := provider.remoteForwardSpec(localHost, localPort)
+	if err != nil {
+		return nil, err
 	}
-	userHost := fmt.Sprintf("%s@%s", t.provider.User, t.provider.Host)
+	userHost := fmt.Sprintf("%s@%s", provider.User, provider.Host)
 
-	args := []string{
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
+	args := append([]string(nil), hostKeyArgs...)
+	args = append(args,
 		"-o", "LogLevel=ERROR",
 		"-o", fmt.Sprintf("ConnectTimeout=%d", int(timeout.Seconds())),
+	)
+
+	if provider.ClientLabel != "" {
+		args = append(args, "-o", "SetEnv=QRLOCAL_CLIENT="+provider.ClientLabel)
+	}
+
+	if provider.Port != "22" {
+		args = append(args, "-p", provider.Port)
+	}
+
+	// User-supplied options go last, right before the forward and destination
+	args = append(args, provider.ExtraArgs...)
+
+	// "--" keeps a user or host starting with '-' from being read as an
+	// option
+	args = append(args, "-R", remoteForward, "--", userHost)
+	if provider.JSONOutput {
+		args = append(args, jsonOutputCommand...)
 	}
+	return args, nil
+}
 
-	if t.provider.Port != "22" {
-		args = append(args, "-p", t.provider.Port)
+// connect establishes the SSH tunnel using the system's ssh command.
+func (t *Tunnel) connect(timeout time.Duration) error {
+	// ssh has checked the host key by the time connect returns
+	hostKeyArgs, cleanup, err := t.provider.hostKeyArgs()
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 
-	args = append(args, "-R", remoteForward, userHost)
+	args, err := buildSSHArgs(t.provider, t.localHost, t.localPort, timeout, hostKeyArgs)
+	if err != nil {
+		return err
+	}
 
 	sshCmd := "ssh"
 	if runtime.GOOS == "windows" {
@@ -186,6 +546,11 @@ func (t *Tunnel) connect(timeout time.Duration) error {
 	}
 
 	t.cmd = exec.CommandContext(t.ctx, sshCmd, args...)
+	setProcessGroup(t.cmd)
+	cmd := t.cmd
+	cmd.Cancel = func() error {
+		return killProcessTree(cmd)
+	}
 
 	stdout, err := t.cmd.StdoutPipe()
 	if err != nil {
@@ -211,15 +576,17 @@ func (t *Tunnel) connect(timeout time.Duration) error {
 		combined := io.MultiReader(stdout, stderr)
 		reader := bufio.NewReader(combined)
 
+		var lastLine string
 		for {
 			line, err := reader.ReadString('\n')
+			t.logLine(line)
 			if len(line) > 0 {
-				// Try to find URL, using capture group if available
-				if matches := t.provider.URLRegex.FindStringSubmatch(line); len(matches) > 0 {
-					// Use first capture group if exists, otherwise full match
-					url := matches[0]
-					if len(matches) > 1 && matches[1] != "" {
-						url = matches[1]
+				if trimmed := strings.TrimSpace(line); trimmed != "" {
+					lastLine = trimmed
+				}
+				if url, ok := t.provider.findURL(line); ok {
+					if t.tcp && !strings.Contains(url, "://") {
+						url = "tcp://" + url
 					}
 					urlChan <- url
 					break
@@ -229,14 +596,25 @@ func (t *Tunnel) connect(timeout time.Duration) error {
 				if err != io.EOF {
 					errChan <- fmt.Errorf("error reading output: %w", err)
 				} else {
-					errChan <- errors.New("SSH connection closed without providing URL")
+					errChan <- t.provider.closedError(lastLine)
 				}
 				break
 			}
 		}
 
+		// Keep reading so ssh never blocks on a full pipe
 		go func() {
-			io.Copy(io.Discard, combined)
+			if t.log == nil {
+				io.Copy(io.Discard, reader)
+				return
+			}
+			for {
+				line, err := reader.ReadString('\n')
+				t.logLine(line)
+				if err != nil {
+					return
+				}
+			}
 		}()
 	}()
 
@@ -244,24 +622,69 @@ func (t *Tunnel) connect(timeout time.Duration) error {
 	case url := <-urlChan:
 		t.mu.Lock()
 		t.publicURL = url
+		t.connected = time.Now()
 		t.mu.Unlock()
 
+		t.emit(Event{Type: EventEstablished, URL: url})
+
 		go func() {
-			t.cmd.Wait()
+			err := t.cmd.Wait()
+			// Only report a drop if the tunnel wasn't closed deliberately
+			if t.ctx.Err() == nil {
+				t.emit(Event{Type: EventDropped, URL: url, Err: err})
+			}
+			close(t.events)
 			close(t.done)
 		}()
 
 		return nil
 	case err := <-errChan:
-		t.cmd.Process.Kill()
+		t.stop()
 		return err
 	case <-time.After(timeout):
-		t.cmd.Process.Kill()
-		return errors.New("timeout waiting for tunnel URL")
+		t.stop()
+		return errURLTimeout
 	case <-t.ctx.Done():
-		t.cmd.Process.Kill()
-		return errors.New("tunnel cancelled")
+		t.stop()
+		return cancelledError(t.ctx)
+	}
+}
+
+// logLine writes a line of provider output to the log, if there is one.
+func (t *Tunnel) logLine(line string) {
+	line = strings.TrimRight(line, "\r\n")
+	if t.log == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	fmt.Fprintf(t.log, "%s: %s\n", t.provider.Name, line)
+}
+
+// cancelledError returns the error for a connection attempt abandoned
+// because ctx is done.
+func cancelledError(ctx context.Context) error {
+	return fmt.Errorf("tunnel cancelled: %w", ctx.Err())
+}
+
+// stop kills a failed ssh process and reaps it, so nothing is left running
+// before a retry.
+func (t *Tunnel) stop() {
+	killProcessTree(t.cmd)
+	t.cmd.Wait()
+}
+
+// closedError returns the error for ssh exiting without a URL. Exits
+// caused by a hard failure report ssh's last message and aren't retried.
+func (p Provider) closedError(lastLine string) error {
+	for _, failure := range hardFailures {
+		if strings.Contains(lastLine, failure) {
+			message := strings.TrimPrefix(lastLine, "ssh: ")
+			if failure == "Host key verification failed" && p.pinsHostKey() {
+				return fmt.Errorf("ssh: %s (%s's host key doesn't match its host_key or known_hosts)", strings.TrimSuffix(message, "."), p.Name)
+			}
+			return fmt.Errorf("ssh: %s", message)
+		}
 	}
+	return errNoURL
 }
 
 // PublicURL returns the public URL of the tunnel.
@@ -271,12 +694,43 @@ func (t *Tunnel) PublicURL() string {
 	return t.publicURL
 }
 
+// ConnectedAt returns when the public URL was received.
+func (t *Tunnel) ConnectedAt() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.connected
+}
+
+// ProviderName returns the name of the tunnel's provider.
+func (t *Tunnel) ProviderName() string {
+	return t.provider.Name
+}
+
+// Events returns a channel of lifecycle events for the tunnel.
+// The channel is buffered and closed once the tunnel has shut down.
+// Events are dropped if the buffer is full, so callers should drain it promptly.
+func (t *Tunnel) Events() <-chan Event {
+	return t.events
+}
+
+// emit sends an event without blocking the tunnel.
+func (t *Tunnel) emit(e Event) {
+	e.Provider = t.provider.Name
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	select {
+	case t.events <- e:
+	default:
+	}
+}
+
 // Close gracefully shuts down the tunnel.
 func (t *Tunnel) Close() error {
 	t.cancel()
 
-	if t.cmd != nil && t.cmd.Process != nil {
-		t.cmd.Process.Kill()
+	if t.cmd != nil {
+		killProcessTree(t.cmd)
 	}
 
 	select {
@@ -347,3 +801,50 @@ func HasSSH() bool {
 	_, err := exec.LookPath(sshCmd)
 	return err == nil
 }
+
+// linuxSSHPackages maps package managers to the command that installs an
+// ssh client, in order of preference.
+var linuxSSHPackages = []struct {
+	manager string
+	install string
+}{
+	{"apt-get", "sudo apt install openssh-client"},
+	{"dnf", "sudo dnf install openssh-clients"},
+	{"yum", "sudo yum install openssh-clients"},
+	{"pacman", "sudo pacman -S openssh"},
+	{"zypper", "sudo zypper install openssh-clients"},
+	{"apk", "apk add openssh-client"},
+}
+
+// SSHInstallHint returns a platform-specific suggestion for installing an
+// ssh client.
+func SSHInstallHint() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "Enable the \"OpenSSH Client\" optional feature in Settings > System > Optional features, " +
+			"or run: Add-WindowsCapability -Online -Name OpenSSH.Client~~~~0.0.1.0"
+	case "darwin":
+		return "macOS includes ssh; check that /usr/bin is on your PATH"
+	case "linux":
+		for _, p := range linuxSSHPackages {
+			if _, err := exec.LookPath(p.manager); err == nil {
+				return "Install it with: " + p.install
+			}
+		}
+		return "Install the OpenSSH client with your distribution's package manager"
+	default:
+		return "Install an OpenSSH client and make sure ssh is on your PATH"
+	}
+}
+
+// AlternativeTunnelTools returns the non-SSH tunneling tools found on the
+// system, which can share a port when ssh isn't available.
+func AlternativeTunnelTools() []string {
+	var found []string
+	for _, tool := range []string{"cloudflared", "ngrok"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			found = append(found, tool)
+		}
+	}
+	return found
+}
@@ -0,0 +1,24 @@
+package tunnel
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildSSHArgsEndsOptions(t *testing.T) {
+	provider := Provider{
+		Host:          "tunnel.example",
+		Port:          "22",
+		User:          "-oProxyCommand=x",
+		RemoteForward: "80:{{.Host}}:{{.Port}}",
+	}
+	args, err := buildSSHArgs(provider, "localhost", 3000, 10*time.Second, nil)
+	if err != nil {
+		t.Fatalf("buildSSHArgs: %v", err)
+	}
+	i := slices.Index(args, "--")
+	if i < 0 || i+1 >= len(args) || args[i+1] != "-oProxyCommand=x@tunnel.example" {
+		t.Errorf("args = %q, want \"--\" right before the destination", args)
+	}
+}
@@ -0,0 +1,53 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// verifyInterval is the pause between WaitHealthy attempts.
+const verifyInterval = 2 * time.Second
+
+// WaitHealthy requests url until it answers with a status below 500,
+// retrying for up to timeout. Providers answer 502 or 504 while the
+// service behind the tunnel isn't responding yet. The error describes
+// the last failed attempt, such as the HTTP status seen.
+func WaitHealthy(ctx context.Context, url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// A redirect is a healthy answer; don't chase it
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			lastErr = fmt.Errorf("HTTP %s", resp.Status)
+		} else if lastErr == nil || ctx.Err() == nil {
+			// Keep a status seen earlier over the timeout that ends the wait
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			return lastErr
+		case <-time.After(verifyInterval):
+		}
+	}
+}